@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"github.com/nikitakuchur/testpoint/internal/bench"
+	"github.com/nikitakuchur/testpoint/internal/filter"
+	"github.com/nikitakuchur/testpoint/internal/io/readers/reqreader"
+	"github.com/nikitakuchur/testpoint/internal/secrets"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"github.com/nikitakuchur/testpoint/internal/transformer"
+	"github.com/spf13/cobra"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+type benchConfig struct {
+	sendConfig
+	duration             time.Duration
+	warmup               time.Duration
+	maxLatencyRegression float64
+	maxErrorRateIncrease float64
+	minSamples           int
+}
+
+func (c benchConfig) String() string {
+	numRequests := "all"
+	if c.numRequests > 0 {
+		numRequests = strconv.Itoa(c.numRequests)
+	}
+	str := fmt.Sprintf(
+		"input: %v, numRequests: %v, noHeader: %v, urls: %v, transformation: %v, concurrency: %v, rps: %v, "+
+			"%v, sessionColumn: %v, "+
+			"duration: %v, warmup: %v, maxLatencyRegression: %v%%, maxErrorRateIncrease: %vpp, minSamples: %v",
+		c.input, numRequests, c.noHeader, redactUrls(c.urls), c.transformationName(), c.workers, c.rateLimit,
+		c.targetFlagsString(c.urls), c.sessionColumn,
+		c.duration, c.warmup, c.maxLatencyRegression, c.maxErrorRateIncrease, c.minSamples,
+	)
+	return secrets.MaskSecrets(str)
+}
+
+func newBenchCmd() *cobra.Command {
+	var conf benchConfig
+
+	cmd := &cobra.Command{
+		Use:   "bench [flags] <input> <url>...",
+		Short: "Load the specified REST endpoints with prepared requests and compare the latencies",
+		Long: "Replay the requests from the given input (CSV file or directory of CSV files) against each of the specified URLs " +
+			"for a fixed time, report the latencies, error rates and throughput, and compare every URL with the first one.",
+		Args: cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			conf.input = args[0]
+			conf.urls = args[1:]
+			// the requests are not retried, since the retries would hide the errors and skew the latencies
+			conf.maxAttempts = 1
+
+			log.Printf("configuration: {%v}\n", conf)
+
+			// the requests in flight are canceled right away on interruption, since the results are incomplete anyway
+			sd := newShutdown(0)
+			defer sd.stop()
+
+			requests := loadBenchRequests(sd, conf)
+
+			var summaries []bench.Summary
+			for _, url := range conf.urls {
+				if sd.interrupted() {
+					break
+				}
+				log.Printf("benchmarking %v with %v requests...", redactUrl(url), len(requests[url]))
+
+				s := sender.NewSender(
+					sender.WithRetryPolicy(sender.RetryPolicy{MaxAttempts: 1}),
+					sender.WithTargetConfig(url, createTargetConfig(conf.sendConfig, url)),
+				)
+				samples, elapsed := bench.Run(sd.input, s, requests[url], bench.Config{
+					Duration:    conf.duration,
+					Warmup:      conf.warmup,
+					Concurrency: conf.workers,
+				})
+				summary := bench.Summarize(redactUrl(url), samples, elapsed)
+				summaries = append(summaries, summary)
+				if err := bench.WriteSummary(os.Stdout, summary); err != nil {
+					log.Fatalln(err)
+				}
+			}
+			if sd.interrupted() {
+				log.Fatalln("interrupted: the benchmark was not finished")
+			}
+
+			thresholds := bench.Thresholds{
+				Latency:    conf.maxLatencyRegression / 100,
+				ErrorRate:  conf.maxErrorRateIncrease / 100,
+				MinSamples: conf.minSamples,
+			}
+			regressions := false
+			for _, candidate := range summaries[1:] {
+				comparisons := bench.Compare(summaries[0], candidate, thresholds)
+				if err := bench.WriteComparison(os.Stdout, summaries[0].Target, candidate.Target, comparisons); err != nil {
+					log.Fatalln(err)
+				}
+				regressions = regressions || bench.HasRegressions(comparisons)
+			}
+			if regressions {
+				log.Fatalln("the regression thresholds were exceeded")
+			}
+			log.Println("completed")
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.IntVarP(&conf.numRequests, "num-requests", "n", 0, "number of requests to replay")
+	flags.BoolVar(&conf.noHeader, "no-header", false, "enable this flag if your CSV file has no header")
+	flags.StringVarP(&conf.transformation, "transformation", "t", "", "JavaScript file with a request transformation")
+	flags.BoolVar(&conf.graphql, "graphql", false, "build GraphQL requests from the query, operationName, and variables columns")
+	flags.IntVarP(&conf.workers, "concurrency", "c", 1, "number of requests sent at the same time")
+	flags.StringArrayVar(&conf.rateLimit, "rps", nil, "target number of requests per second, use <url>=<value> to set it for a specific target")
+	flags.DurationVar(&conf.duration, "duration", time.Minute, "how long to load each target")
+	flags.DurationVar(&conf.warmup, "warmup", 0, "how long to load each target before the measurement")
+	flags.Float64Var(&conf.maxLatencyRegression, "max-latency-regression", 10, "maximum increase of p50, p90 or p99 in percent")
+	flags.Float64Var(&conf.maxErrorRateIncrease, "max-error-rate-increase", 1, "maximum increase of the error rate in percentage points")
+	flags.IntVar(&conf.minSamples, "min-samples", 100, "minimum number of requests in an endpoint group to check it for regressions")
+
+	addTargetFlags(flags, &conf.sendConfig)
+	flags.StringVar(&conf.sessionColumn, "session-column", "", "column with the session ID (or its index if there's no header), each session gets its own cookies")
+
+	return cmd
+}
+
+// loadBenchRequests reads and transforms all the requests upfront, so the reading doesn't affect the latencies.
+func loadBenchRequests(sd *shutdown, conf benchConfig) map[string][]sender.Request {
+	records := reqreader.ReadRequests(sd.input, conf.input, !conf.noHeader, conf.numRequests)
+	records = filter.Filter(sd.input, records)
+	transformed := transformer.TransformRequests(sd.input, conf.urls, records, createReqTransformation(conf.sendConfig))
+
+	requests := make(map[string][]sender.Request)
+	for req := range transformed {
+		requests[req.UserUrl] = append(requests[req.UserUrl], req)
+	}
+	if sd.interrupted() {
+		log.Fatalln("interrupted: the requests were not loaded")
+	}
+	for _, url := range conf.urls {
+		if len(requests[url]) == 0 {
+			log.Fatalf("there are no requests for %v", redactUrl(url))
+		}
+	}
+	return requests
+}
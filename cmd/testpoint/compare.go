@@ -5,25 +5,36 @@ import (
 	"github.com/nikitakuchur/testpoint/internal/comparator"
 	"github.com/nikitakuchur/testpoint/internal/io/readers/respreader"
 	"github.com/nikitakuchur/testpoint/internal/io/writers/reporter"
+	"github.com/nikitakuchur/testpoint/internal/noise"
+	"github.com/nikitakuchur/testpoint/internal/progress"
+	"github.com/nikitakuchur/testpoint/internal/repeats"
 	"github.com/spf13/cobra"
 	"log"
+	"maps"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 )
 
 type compareConfig struct {
-	file1          string
-	file2          string
-	numComparisons int
-	comparator     string
-	workers        int
-	ignoreOrder    bool
-	csvReport      string
+	file1            string
+	file2            string
+	secondary        string
+	numComparisons   int
+	comparator       string
+	graphql          bool
+	workers          int
+	ignoreOrder      bool
+	csvReport        string
+	nonDeterministic string
 }
 
 func (c compareConfig) String() string {
 	comp := c.comparator
-	if comp == "" {
+	if c.graphql {
+		comp = "graphql"
+	} else if comp == "" {
 		comp = "default"
 	}
 	numComparisons := "all"
@@ -31,8 +42,10 @@ func (c compareConfig) String() string {
 		numComparisons = strconv.Itoa(c.numComparisons)
 	}
 	return fmt.Sprintf(
-		"file1: %v, file2: %v, numComparisons: %v, comparator: %v, workers: %v, ignoreOrder: %v, csvReport: %v",
-		c.file1, c.file2, numComparisons, comp, c.workers, c.ignoreOrder, c.csvReport,
+		"file1: %v, file2: %v, secondary: %v, numComparisons: %v, comparator: %v, workers: %v, ignoreOrder: %v, csvReport: %v, "+
+			"nonDeterministic: %v",
+		c.file1, c.file2, c.secondary, numComparisons, comp, c.workers, c.ignoreOrder, c.csvReport,
+		c.nonDeterministic,
 	)
 }
 
@@ -48,27 +61,88 @@ func newCompareCmd() *cobra.Command {
 			conf.file1 = args[0]
 			conf.file2 = args[1]
 
+			if conf.nonDeterministic != "" && conf.nonDeterministic != nonDeterministicExclude && conf.nonDeterministic != nonDeterministicAnnotate {
+				log.Fatalf("invalid --non-deterministic value '%v', must be %v or %v", conf.nonDeterministic, nonDeterministicExclude, nonDeterministicAnnotate)
+			}
+
 			log.Printf("configuration: {%v}\n", conf)
+
+			comp := createComparator(conf)
+
+			var nonDet *repeats.NonDeterministic
+			if conf.nonDeterministic != "" {
+				log.Println("checking the repeated responses for consistency...")
+				nonDet = checkRepeats(comp, conf.file1, conf.file2)
+			}
+
+			var n *noise.Noise
+			if conf.secondary != "" {
+				log.Println("detecting the noise between the responses of the reference...")
+				n = noise.Detect(respreader.ReadResponses(conf.file1), respreader.ReadResponses(conf.secondary))
+			}
+
 			log.Println("starting to compare the responses...")
 
-			records1 := respreader.ReadResponses(conf.file1)
-			records2 := respreader.ReadResponses(conf.file2)
+			prog := progress.New("records", progress.WithMismatches())
+			go func() {
+				total := respreader.CountResponses(conf.file1)
+				if conf.numComparisons > 0 {
+					total = min(total, conf.numComparisons)
+				}
+				prog.SetTotal(total)
+			}()
+
+			// the records are counted once they have been compared, since the comparison can't keep up with the reading
+			records1 := progress.Tap(respreader.ReadResponses(conf.file1), func(rec respreader.RespRecord) {
+				prog.Result(conf.file1, rec.RespStatus, rec.RespError)
+				if nonDet != nil && rec.ReqRepeat > 0 {
+					// the repeats aren't compared between the targets, so there's nothing to wait for
+					prog.Add(1)
+				}
+			})
+			records2 := progress.Tap(respreader.ReadResponses(conf.file2), func(rec respreader.RespRecord) {
+				prog.Result(conf.file2, rec.RespStatus, rec.RespError)
+			})
+			// the repeats of the deterministic requests are the same, so only the first ones are compared between the targets
+			if nonDet != nil {
+				records1, records2 = repeats.FirstOnly(records1), repeats.FirstOnly(records2)
+			}
 			diffs := comparator.CompareResponses(
 				records1,
 				records2,
 				conf.numComparisons,
-				createComparator(conf.comparator, conf.ignoreOrder),
+				comp,
 				conf.workers,
+				comparator.WithCompared(func(_, _ respreader.RespRecord) {
+					prog.Add(1)
+				}),
 			)
 
-			reporters := []reporter.Reporter{reporter.NewLogReporter(log.Default())}
-
-			if conf.csvReport != "" {
-				reporters = append(reporters, reporter.NewCsvReporter(conf.csvReport))
+			reporters := createReporters(conf.csvReport)
+			if n != nil {
+				diffs = n.Filter(diffs, comp)
+				reporters = append(reporters, reporter.NewNoiseReporter(log.Default(), n.Paths()))
+			}
+			if nonDet != nil {
+				diffs = nonDet.Filter(diffs, conf.nonDeterministic == nonDeterministicAnnotate)
 			}
 
+			diffs = progress.Tap(diffs, func(comparator.RespDiff) {
+				prog.Mismatch()
+			})
+
+			prog.Start()
 			reporter.GenerateReport(diffs, reporters...)
+			// the records left are the ones without a pair, they can't be compared
+			prog.Finish()
+			prog.Stop()
 
+			if nonDet != nil && conf.nonDeterministic == nonDeterministicExclude {
+				log.Printf("%v mismatches of the non-deterministic requests have been excluded", nonDet.Excluded())
+			}
+			if n != nil {
+				log.Printf("%v mismatches have been classified as noise", n.Suppressed())
+			}
 			log.Println("completed")
 		},
 	}
@@ -76,25 +150,83 @@ func newCompareCmd() *cobra.Command {
 	flags := cmd.Flags()
 	flags.IntVarP(&conf.numComparisons, "num-comparisons", "n", 0, "number of comparisons to perform")
 	flags.StringVarP(&conf.comparator, "comparator", "c", "", "JavaScript file with a response comparator")
+	flags.BoolVar(&conf.graphql, "graphql", false, "compare the data and the errors of GraphQL responses separately")
 	flags.IntVarP(&conf.workers, "workers", "w", 8, "number of workers to compare responses")
 	flags.BoolVar(&conf.ignoreOrder, "ignore-order", false, "enable this flag if you want to ignore array order during comparison")
 	flags.StringVar(&conf.csvReport, "csv-report", "", "output a comparison report to a CSV file")
+	flags.StringVar(&conf.nonDeterministic, "non-deterministic", "", "check the repeated responses for consistency, and exclude or annotate the mismatches of the requests whose responses differ across the repeats")
+	flags.StringVar(&conf.secondary, "secondary", "", "responses of a second instance of the reference, the differences between the two instances are ignored as noise")
 
 	return cmd
 }
 
-func createComparator(filepath string, ignoreOrder bool) comparator.Comparator {
-	if filepath == "" {
-		return comparator.NewDefaultComparator(ignoreOrder)
+const (
+	nonDeterministicExclude  = "exclude"
+	nonDeterministicAnnotate = "annotate"
+)
+
+// checkRepeats finds the requests whose responses differ across the repeats in the given files, and logs them per endpoint.
+func checkRepeats(comp comparator.Comparator, files ...string) *repeats.NonDeterministic {
+	nonDet := repeats.New()
+	for _, file := range files {
+		repeated, found := nonDet.Check(respreader.ReadResponses(file), comp)
+		if !repeated {
+			log.Printf("there are no repeated responses in %v, use the --repeat flag of the send command to get them", file)
+			continue
+		}
+		log.Printf("%v requests have different responses across the repeats in %v", found, file)
+	}
+
+	groups := nonDet.Groups()
+	if len(groups) == 0 {
+		return nonDet
+	}
+	names := slices.Collect(maps.Keys(groups))
+	// the endpoints with the most non-deterministic requests go first
+	slices.SortFunc(names, func(a, b string) int {
+		if groups[a] != groups[b] {
+			return groups[b] - groups[a]
+		}
+		return strings.Compare(a, b)
+	})
+	sb := strings.Builder{}
+	for _, name := range names {
+		sb.WriteString(fmt.Sprintf("\t%d\t%s\n", groups[name], name))
+	}
+	log.Print("non-deterministic requests per endpoint:\n", sb.String())
+	return nonDet
+}
+
+func createComparator(conf compareConfig) comparator.Comparator {
+	if conf.graphql && conf.comparator != "" {
+		log.Fatalln("the --graphql flag cannot be used together with a comparator script")
+	}
+	if conf.graphql {
+		return comparator.NewGraphQLComparator(conf.ignoreOrder)
 	}
-	script := readComparatorScript(filepath)
-	comp, err := comparator.NewScriptComparator(script, ignoreOrder)
+	if conf.comparator == "" {
+		return comparator.NewDefaultComparator(conf.ignoreOrder)
+	}
+	script := readComparatorScript(conf.comparator)
+	comp, err := comparator.NewScriptComparator(script, conf.ignoreOrder)
 	if err != nil {
 		log.Fatalln(err)
 	}
 	return &comp
 }
 
+// createReporters creates the reporters that log the mismatches, count them per endpoint, and write them to a CSV file if it's specified.
+func createReporters(csvReport string) []reporter.Reporter {
+	reporters := []reporter.Reporter{
+		reporter.NewLogReporter(log.Default()),
+		reporter.NewGroupReporter(log.Default()),
+	}
+	if csvReport != "" {
+		reporters = append(reporters, reporter.NewCsvReporter(csvReport))
+	}
+	return reporters
+}
+
 func readComparatorScript(filename string) string {
 	script, err := os.ReadFile(filename)
 	if err != nil {
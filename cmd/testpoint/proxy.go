@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/nikitakuchur/testpoint/internal/io/writers/reporter"
+	"github.com/nikitakuchur/testpoint/internal/proxy"
+	"github.com/nikitakuchur/testpoint/internal/secrets"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"github.com/spf13/cobra"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+type proxyConfig struct {
+	sendConfig
+	listen      string
+	queueSize   int
+	comparator  string
+	graphql     bool
+	ignoreOrder bool
+	csvReport   string
+}
+
+func (c proxyConfig) String() string {
+	comp := c.comparator
+	if c.graphql {
+		comp = "graphql"
+	} else if comp == "" {
+		comp = "default"
+	}
+	str := fmt.Sprintf(
+		"urls: %v, listen: %v, workers: %v, queueSize: %v, shutdownTimeout: %v, rateLimit: %v, maxInFlight: %v, "+
+			"%v, comparator: %v, ignoreOrder: %v, csvReport: %v",
+		redactUrls(c.urls), c.listen, c.workers, c.queueSize, c.shutdownTimeout, c.rateLimit, c.maxInFlight,
+		c.targetFlagsString(c.urls), comp, c.ignoreOrder, c.csvReport,
+	)
+	return secrets.MaskSecrets(str)
+}
+
+func newProxyCmd() *cobra.Command {
+	var conf proxyConfig
+
+	cmd := &cobra.Command{
+		Use:   "proxy [flags] <primary-url> <candidate-url>...",
+		Short: "Mirror live traffic to the candidate endpoints and compare the responses",
+		Long: "Listen as a reverse proxy, forward every request to the primary URL and return its response, " +
+			"send the same request to the candidate URLs in the background, and report the mismatches as they come.",
+		Args: cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			conf.urls = args
+			// the requests aren't retried, since the callers expect them to be sent once
+			conf.maxAttempts = 1
+			if len(conf.cookies) != 0 || len(conf.cookieFile) != 0 {
+				log.Fatalln("the cookies of the callers are passed through, so the --cookies and --cookie-file flags cannot be used")
+			}
+			if len(conf.redirects) != 0 {
+				log.Fatalln("the redirects are returned to the callers, so the --redirects flag cannot be used")
+			}
+
+			log.Printf("configuration: {%v}\n", conf)
+
+			sd := newShutdown(conf.shutdownTimeout)
+			defer sd.stop()
+
+			opts := []sender.Option{sender.WithRetryPolicy(sender.RetryPolicy{MaxAttempts: 1})}
+			for i, url := range conf.urls {
+				targetConf := createTargetConfig(conf.sendConfig, url)
+				targetConf.MaxRedirects = sender.NoRedirects
+				// the callers get the whole response of the primary target, the limit only applies to the candidates
+				if i == 0 {
+					targetConf.MaxBodySize = 0
+				}
+				opts = append(opts, sender.WithTargetConfig(url, targetConf))
+			}
+
+			p := proxy.New(sender.NewSender(opts...), proxy.Config{
+				Primary:    conf.urls[0],
+				Candidates: conf.urls[1:],
+				QueueSize:  conf.queueSize,
+			})
+			comp := createComparator(compareConfig{comparator: conf.comparator, graphql: conf.graphql, ignoreOrder: conf.ignoreOrder})
+			diffs := p.Compare(sd.requests, comp, conf.workers)
+
+			reported := make(chan struct{})
+			go func() {
+				defer close(reported)
+				reporter.GenerateReport(diffs, createReporters(conf.csvReport)...)
+			}()
+
+			serve(sd, conf.listen, p, fmt.Sprintf("the responses of %v are compared with %v", redactUrl(conf.urls[0]), redactUrls(conf.urls[1:])))
+			p.Close()
+			<-reported
+
+			if dropped := p.Dropped(); dropped != 0 {
+				log.Printf("%v requests were not sent to the candidates, since the queue was full", dropped)
+			}
+			log.Println("completed")
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&conf.listen, "listen", "l", ":8080", "address the proxy listens on")
+	flags.IntVarP(&conf.workers, "workers", "w", 8, "number of workers to send requests to the candidates and compare the responses")
+	flags.IntVar(&conf.queueSize, "queue-size", 1000, "maximum number of requests waiting to be sent to the candidates, the rest are not compared")
+	flags.DurationVar(&conf.shutdownTimeout, "shutdown-timeout", 30*time.Second, "time to wait for the requests in flight after an interruption")
+
+	flags.StringVarP(&conf.comparator, "comparator", "c", "", "JavaScript file with a response comparator")
+	flags.BoolVar(&conf.graphql, "graphql", false, "compare the data and the errors of GraphQL responses separately")
+	flags.BoolVar(&conf.ignoreOrder, "ignore-order", false, "enable this flag if you want to ignore array order during comparison")
+	flags.StringVar(&conf.csvReport, "csv-report", "", "output a comparison report to a CSV file")
+
+	flags.StringArrayVar(&conf.rateLimit, "rate-limit", nil, "maximum number of requests per second, use <url>=<value> to set it for a specific target")
+	flags.StringArrayVar(&conf.maxInFlight, "max-in-flight", nil, "maximum number of requests in flight, use <url>=<value> to set it for a specific target")
+
+	addTargetFlags(flags, &conf.sendConfig)
+
+	return cmd
+}
+
+// serve handles the requests with the given handler until the process is interrupted.
+// It returns after the requests in flight have been handled, or the shutdown timeout is over.
+func serve(sd *shutdown, addr string, handler http.Handler, description string) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalln("cannot start listening:", err)
+	}
+	server := &http.Server{
+		Handler: handler,
+		// the requests in flight are canceled when the shutdown timeout is over
+		BaseContext: func(net.Listener) context.Context { return sd.requests },
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-sd.input.Done()
+		log.Println("stopping...")
+		_ = server.Shutdown(sd.requests)
+	}()
+
+	log.Printf("listening on %v, %v", lis.Addr(), description)
+	if err := server.Serve(lis); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalln("the server has failed:", err)
+	}
+	<-stopped
+}
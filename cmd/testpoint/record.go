@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"github.com/nikitakuchur/testpoint/internal/io/writers/reqwriter"
+	"github.com/nikitakuchur/testpoint/internal/io/writers/respwriter"
+	"github.com/nikitakuchur/testpoint/internal/proxy"
+	"github.com/nikitakuchur/testpoint/internal/secrets"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"github.com/spf13/cobra"
+	"log"
+	"os"
+	"regexp"
+	"time"
+)
+
+type recordConfig struct {
+	sendConfig
+	output        string
+	upstream      string
+	listen        string
+	redactHeaders []string
+	methods       []string
+	paths         []string
+	baselineDir   string
+}
+
+func (c recordConfig) String() string {
+	upstream := "none"
+	var urls []string
+	if c.upstream != "" {
+		upstream = redactUrl(c.upstream)
+		urls = []string{c.upstream}
+	}
+	str := fmt.Sprintf(
+		"output: %v, upstream: %v, listen: %v, redactHeaders: %v, methods: %v, paths: %v, baselineDir: %v, "+
+			"shutdownTimeout: %v, rateLimit: %v, maxInFlight: %v, %v",
+		c.output, upstream, c.listen, c.redactHeaders, c.methods, c.paths, c.baselineDir,
+		c.shutdownTimeout, c.rateLimit, c.maxInFlight, c.targetFlagsString(urls),
+	)
+	return secrets.MaskSecrets(str)
+}
+
+func newRecordCmd() *cobra.Command {
+	var conf recordConfig
+
+	cmd := &cobra.Command{
+		Use:   "record [flags] <output-file>",
+		Short: "Record live traffic into a request file",
+		Long: "Listen as a proxy, forward every request to the upstream URL and return its response, " +
+			"and write the requests to a CSV file (or a JSON Lines file if it has the .jsonl extension) that can be sent later. " +
+			"Without the upstream URL, it works as a forward proxy for plain HTTP requests.",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			conf.output = args[0]
+			// the requests aren't retried, since the callers expect them to be sent once
+			conf.maxAttempts = 1
+			if len(conf.cookies) != 0 || len(conf.cookieFile) != 0 {
+				log.Fatalln("the cookies of the callers are passed through, so the --cookies and --cookie-file flags cannot be used")
+			}
+			if len(conf.redirects) != 0 {
+				log.Fatalln("the redirects are returned to the callers, so the --redirects flag cannot be used")
+			}
+			paths := make([]*regexp.Regexp, 0, len(conf.paths))
+			for _, p := range conf.paths {
+				re, err := regexp.Compile(p)
+				if err != nil {
+					log.Fatalf("invalid path pattern '%v': %v", p, err)
+				}
+				paths = append(paths, re)
+			}
+
+			log.Printf("configuration: {%v}\n", conf)
+
+			sd := newShutdown(conf.shutdownTimeout)
+			defer sd.stop()
+
+			opt := sender.WithDefaultTargetConfig(recordTargetConfig(conf.sendConfig, ""))
+			if conf.upstream != "" {
+				conf.urls = []string{conf.upstream}
+				opt = sender.WithTargetConfig(conf.upstream, recordTargetConfig(conf.sendConfig, conf.upstream))
+			}
+			s := sender.NewSender(sender.WithRetryPolicy(sender.RetryPolicy{MaxAttempts: 1}), opt)
+
+			writer, err := reqwriter.NewWriter(conf.output, proxy.RecordFields)
+			if err != nil {
+				log.Fatalln("cannot create the output file:", err)
+			}
+
+			var responses chan sender.RequestResponse
+			written := make(chan struct{})
+			if conf.baselineDir != "" {
+				if err := os.MkdirAll(conf.baselineDir, 0755); err != nil {
+					log.Fatalln("cannot create the baseline directory:", err)
+				}
+				responses = make(chan sender.RequestResponse)
+				go func() {
+					defer close(written)
+					respwriter.WriteResponses(responses, conf.baselineDir)
+				}()
+			} else {
+				close(written)
+			}
+
+			rec := proxy.NewRecorder(s, proxy.RecorderConfig{
+				Upstream: conf.upstream,
+				Redact:   conf.redactHeaders,
+				Methods:  conf.methods,
+				Paths:    paths,
+			}, writer, responses)
+
+			description := "working as a forward proxy"
+			if conf.upstream != "" {
+				description = fmt.Sprintf("the requests are forwarded to %v", redactUrl(conf.upstream))
+			}
+			serve(sd, conf.listen, rec, description)
+			rec.Close()
+			<-written
+
+			if err := writer.Close(); err != nil {
+				log.Fatalln("cannot close the output file:", err)
+			}
+			log.Printf("%v requests have been recorded to %v", rec.Count(), conf.output)
+			log.Println("completed")
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&conf.upstream, "upstream", "u", "", "URL the requests are forwarded to, without it the recorder works as a forward proxy")
+	flags.StringVarP(&conf.listen, "listen", "l", ":8080", "address the recorder listens on")
+	flags.StringArrayVar(&conf.redactHeaders, "redact-header", nil, "header whose value is replaced in the recorded requests, can be used multiple times")
+	flags.StringSliceVar(&conf.methods, "method", nil, "record only the requests with the given methods, e.g. GET,POST")
+	flags.StringArrayVar(&conf.paths, "path", nil, "record only the requests whose path matches the given regular expression, can be used multiple times")
+	flags.StringVar(&conf.baselineDir, "baseline-dir", "", "output directory for the responses of the upstream, they can be compared with the responses collected later")
+	flags.DurationVar(&conf.shutdownTimeout, "shutdown-timeout", 30*time.Second, "time to wait for the requests in flight after an interruption")
+
+	flags.StringArrayVar(&conf.rateLimit, "rate-limit", nil, "maximum number of requests per second, use <url>=<value> to set it for a specific target")
+	flags.StringArrayVar(&conf.maxInFlight, "max-in-flight", nil, "maximum number of requests in flight, use <url>=<value> to set it for a specific target")
+
+	addTargetFlags(flags, &conf.sendConfig)
+
+	return cmd
+}
+
+// recordTargetConfig creates the config of the upstream, the redirects and the whole responses are returned to the callers.
+func recordTargetConfig(conf sendConfig, url string) sender.TargetConfig {
+	targetConf := createTargetConfig(conf, url)
+	targetConf.MaxRedirects = sender.NoRedirects
+	targetConf.MaxBodySize = 0
+	return targetConf
+}
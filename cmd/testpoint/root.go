@@ -32,6 +32,10 @@ func newRootCmd() *cobra.Command {
 	cmd.AddCommand(
 		newSendCmd(),
 		newCompareCmd(),
+		newBenchCmd(),
+		newProxyCmd(),
+		newRecordCmd(),
+		newServeCmd(),
 	)
 
 	return cmd
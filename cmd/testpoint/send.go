@@ -1,41 +1,149 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"github.com/nikitakuchur/testpoint/internal/checkpoint"
 	"github.com/nikitakuchur/testpoint/internal/filter"
 	"github.com/nikitakuchur/testpoint/internal/io/readers/reqreader"
 	"github.com/nikitakuchur/testpoint/internal/io/writers/respwriter"
+	"github.com/nikitakuchur/testpoint/internal/progress"
+	"github.com/nikitakuchur/testpoint/internal/secrets"
 	"github.com/nikitakuchur/testpoint/internal/sender"
 	"github.com/nikitakuchur/testpoint/internal/transformer"
 	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"io/fs"
 	"log"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type sendConfig struct {
-	input          string
-	numRequests    int
-	noHeader       bool
-	urls           []string
-	transformation string
-	workers        int
-	outputDir      string
+	input             string
+	numRequests       int
+	noHeader          bool
+	urls              []string
+	transformation    string
+	graphql           bool
+	workers           int
+	outputDir         string
+	maxAttempts       int
+	retryDelay        time.Duration
+	maxRetryDelay     time.Duration
+	retryStatuses     []int
+	rateLimit         []string
+	maxInFlight       []string
+	connectTimeout    []string
+	tlsTimeout        []string
+	headerTimeout     []string
+	timeout           []string
+	caCert            []string
+	clientCert        []string
+	clientKey         []string
+	serverName        []string
+	tlsMinVersion     []string
+	insecure          []string
+	protocol          []string
+	proxy             []string
+	noProxy           []string
+	auth              []string
+	cookies           []string
+	cookieFile        []string
+	sessionColumn     string
+	redirects         []string
+	lockStep          bool
+	maxBodySize       []string
+	descriptorSet     []string
+	unixSocket        []string
+	resolve           []string
+	bodyFileThreshold string
+	shutdownTimeout   time.Duration
+	resume            bool
+	repeat            int
+	repeatDelay       time.Duration
 }
 
 func (c sendConfig) String() string {
-	transformation := c.transformation
-	if transformation == "" {
-		transformation = "default"
-	}
 	numRequests := "all"
 	if c.numRequests > 0 {
 		numRequests = strconv.Itoa(c.numRequests)
 	}
-	return fmt.Sprintf(
-		"input: %v, numRequests: %v, noHeader: %v, urls: %v, transformation: %v, workers: %v, outputDir: %v",
-		c.input, numRequests, c.noHeader, c.urls, transformation, c.workers, c.outputDir,
+	str := fmt.Sprintf(
+		"input: %v, numRequests: %v, noHeader: %v, urls: %v, transformation: %v, workers: %v, outputDir: %v, "+
+			"maxAttempts: %v, retryDelay: %v, maxRetryDelay: %v, retryStatuses: %v, rateLimit: %v, maxInFlight: %v, "+
+			"%v, sessionColumn: %v, lockStep: %v, bodyFileThreshold: %v, shutdownTimeout: %v, resume: %v, "+
+			"repeat: %v, repeatDelay: %v",
+		c.input, numRequests, c.noHeader, redactUrls(c.urls), c.transformationName(), c.workers, c.outputDir,
+		c.maxAttempts, c.retryDelay, c.maxRetryDelay, c.retryStatuses, c.rateLimit, c.maxInFlight,
+		c.targetFlagsString(c.urls), c.sessionColumn, c.lockStep, c.bodyFileThreshold, c.shutdownTimeout, c.resume,
+		c.repeat, c.repeatDelay,
 	)
+	return secrets.MaskSecrets(str)
+}
+
+// transformationName describes the request transformation for the log.
+func (c sendConfig) transformationName() string {
+	switch {
+	case c.transformation != "":
+		return c.transformation
+	case c.graphql:
+		return "graphql"
+	default:
+		return "default"
+	}
+}
+
+// redactUrls hides passwords in the given URLs, so they don't appear in the log.
+func redactUrls(urls []string) []string {
+	var result []string
+	for _, u := range urls {
+		result = append(result, redactUrl(u))
+	}
+	return result
+}
+
+// redactTargetUrls hides passwords in the values of a per-target flag that contains URLs.
+func redactTargetUrls(values []string, urls []string) []string {
+	var result []string
+	for _, v := range values {
+		prefix, value, ok := strings.Cut(v, "=")
+		if ok && slices.Contains(urls, prefix) {
+			result = append(result, prefix+"="+redactUrl(value))
+			continue
+		}
+		result = append(result, redactUrl(v))
+	}
+	return result
+}
+
+// authTypes hides the credentials in the values of the --auth flag and leaves only the auth types.
+func authTypes(values []string, urls []string) []string {
+	var result []string
+	for _, v := range values {
+		prefix, value, ok := strings.Cut(v, "=")
+		if ok && slices.Contains(urls, prefix) {
+			kind, _, _ := strings.Cut(value, ":")
+			result = append(result, prefix+"="+kind)
+			continue
+		}
+		kind, _, _ := strings.Cut(v, ":")
+		result = append(result, kind)
+	}
+	return result
+}
+
+func redactUrl(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	return parsed.Redacted()
 }
 
 func newSendCmd() *cobra.Command {
@@ -49,20 +157,77 @@ func newSendCmd() *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			conf.input = args[0]
 			conf.urls = args[1:]
+			if conf.repeat < 1 {
+				log.Fatalln("the --repeat flag must be at least 1")
+			}
+			// the previous run can't tell which repeats have been sent, so the requests would be repeated fewer times
+			if conf.repeat > 1 && conf.resume {
+				log.Fatalln("the --repeat flag cannot be used together with --resume")
+			}
 
 			log.Printf("configuration: {%v}\n", conf)
 			log.Println("starting to process the requests...")
 
-			records := reqreader.ReadRequests(conf.input, !conf.noHeader, conf.numRequests)
-			records = filter.Filter(records)
-			requests := transformer.TransformRequests(conf.urls, records, createReqTransformation(conf.transformation))
+			sd := newShutdown(conf.shutdownTimeout)
+			defer sd.stop()
+
+			start, completed := resume(conf)
+			tracker := checkpoint.NewTracker(filepath.Join(conf.outputDir, checkpoint.Filename), start, completed)
+			// the old checkpoint must be replaced right away, since it doesn't match the output files anymore
+			if err := tracker.Save(); err != nil {
+				log.Fatalln("cannot save the checkpoint:", err)
+			}
+
+			prog := progress.New("records")
+			// the input is counted in the background, so the sending doesn't wait for it
+			go func() {
+				prog.SetTotal(reqreader.CountRequests(sd.input, conf.input, !conf.noHeader, conf.numRequests, reqreader.WithStart(start.Position)))
+			}()
+			progRecords := progress.NewRecords(prog)
+
+			onRead := func(rec reqreader.ReqRecord, pos reqreader.Position) {
+				tracker.Read(rec, pos)
+				progRecords.Read(rec.Hash)
+			}
+			records := reqreader.ReadRequests(
+				sd.input, conf.input, !conf.noHeader, conf.numRequests,
+				reqreader.WithStart(start.Position), reqreader.WithOnRead(onRead),
+			)
+			records = filter.Filter(sd.input, records)
+			transformation := createReqTransformation(conf)
 
-			s := sender.NewSender()
-			responses := s.SendRequests(requests, conf.workers)
+			s := sender.NewSender(createSenderOptions(conf)...)
+			groups := transformer.TransformRequestGroups(sd.input, conf.urls, records, transformation)
+			groups = filter.SkipCompletedGroups(sd.input, groups, completed)
+			groups = countRequests(groups, progRecords, conf.repeat)
+			var responses <-chan sender.RequestResponse
+			if conf.lockStep {
+				responses = s.SendGroups(sd.requests, groups, conf.workers)
+			} else {
+				responses = s.SendRequests(sd.requests, transformer.Flatten(sd.input, groups), conf.workers)
+			}
 
-			respwriter.WriteResponses(responses, conf.outputDir)
+			// a record is processed when all the targets have responded, so the progress doesn't run ahead of them
+			responses = progress.Tap(responses, func(rr sender.RequestResponse) {
+				prog.Result(rr.Request.UserUrl, rr.Response.Status, rr.Response.Error)
+				progRecords.Received(rr.Request.Hash)
+			})
+
+			writerOpts := []respwriter.Option{
+				respwriter.WithBodyFileThreshold(parseTargetSize("body-file-threshold", conf.bodyFileThreshold)),
+				respwriter.WithCheckpoint(tracker),
+			}
+			if conf.resume {
+				writerOpts = append(writerOpts, respwriter.WithAppend())
+			}
+			prog.Start()
+			written := respwriter.WriteResponses(responses, conf.outputDir, writerOpts...)
+			prog.Stop()
 
 			log.Printf("the result was saved in %v", conf.outputDir)
+			if sd.interrupted() {
+				log.Fatalf("interrupted: %v responses were saved, the rest of the requests were not sent", written)
+			}
 			log.Println("completed")
 		},
 	}
@@ -71,20 +236,186 @@ func newSendCmd() *cobra.Command {
 	flags.IntVarP(&conf.numRequests, "num-requests", "n", 0, "number of requests to process")
 	flags.BoolVar(&conf.noHeader, "no-header", false, "enable this flag if your CSV file has no header")
 	flags.StringVarP(&conf.transformation, "transformation", "t", "", "JavaScript file with a request transformation")
-	flags.IntVarP(&conf.workers, "workers", "w", 1, "number of workers to send requests")
+	flags.BoolVar(&conf.graphql, "graphql", false, "build GraphQL requests from the query, operationName, and variables columns")
+	flags.IntVarP(&conf.workers, "workers", "w", 1, "number of workers to send requests to each target, or the number of records sent at the same time in lock-step mode")
+	flags.BoolVar(&conf.lockStep, "lock-step", false, "send the requests created from the same record to all the targets at the same time")
 	flags.StringVar(&conf.outputDir, "output-dir", "./", "directory where the output files need to be saved")
+	flags.BoolVar(&conf.resume, "resume", false, "continue the previous run: skip the requests that already have responses in the output directory and append to the files")
+	flags.DurationVar(&conf.shutdownTimeout, "shutdown-timeout", 30*time.Second, "time to wait for the requests in flight after an interruption")
+	flags.IntVar(&conf.repeat, "repeat", 1, "number of times each request is sent to each target, so the responses can be checked for consistency")
+	flags.DurationVar(&conf.repeatDelay, "repeat-delay", 0, "delay between the repeats of the same request")
+
+	retryPolicy := sender.DefaultRetryPolicy()
+	flags.IntVar(&conf.maxAttempts, "max-attempts", retryPolicy.MaxAttempts, "maximum number of attempts to send a request")
+	flags.DurationVar(&conf.retryDelay, "retry-delay", retryPolicy.BaseDelay, "delay before the first retry, it doubles with every next retry")
+	flags.DurationVar(&conf.maxRetryDelay, "max-retry-delay", retryPolicy.MaxDelay, "maximum delay between two attempts")
+	flags.IntSliceVar(&conf.retryStatuses, "retry-statuses", retryPolicy.RetryStatuses, "response status codes that need to be retried")
+
+	flags.StringArrayVar(&conf.rateLimit, "rate-limit", nil, "maximum number of requests per second, use <url>=<value> to set it for a specific target")
+	flags.StringArrayVar(&conf.maxInFlight, "max-in-flight", nil, "maximum number of requests in flight, use <url>=<value> to set it for a specific target")
+
+	addTargetFlags(flags, &conf)
+	flags.StringVar(&conf.sessionColumn, "session-column", "", "column with the session ID (or its index if there's no header), each session gets its own cookies")
+	flags.StringVar(&conf.bodyFileThreshold, "body-file-threshold", "", "store the response bodies larger than the given size in separate files, e.g. 1MB")
 
 	return cmd
 }
 
-func createReqTransformation(filepath string) transformer.ReqTransformation {
-	if filepath == "" {
-		return transformer.DefaultReqTransformation
+// countRequests tells the progress how many responses each record is waiting for.
+func countRequests(input <-chan []sender.Request, records *progress.Records, repeat int) <-chan []sender.Request {
+	output := make(chan []sender.Request)
+
+	go func() {
+		defer close(output)
+		defer records.Finish()
+
+		for group := range input {
+			records.Requested(group[0].Hash, len(group)*repeat)
+			output <- group
+		}
+	}()
+
+	return output
+}
+
+// resume finds out where the previous run has stopped: the checkpoint in the input
+// and the requests that already have their responses in the output files.
+func resume(conf sendConfig) (checkpoint.Checkpoint, map[string]map[uint64]bool) {
+	start := checkpoint.Checkpoint{Input: conf.input, Urls: conf.urls}
+	if !conf.resume {
+		return start, nil
 	}
-	script := readTransformationScript(filepath)
-	transformation, err := transformer.NewReqTransformation(script)
+
+	completed, err := respwriter.Resume(conf.outputDir, conf.urls)
 	if err != nil {
-		log.Fatalln(err)
+		log.Fatalln("cannot resume the previous run:", err)
+	}
+	collected := 0
+	for _, hashes := range completed {
+		collected += len(hashes)
+	}
+
+	saved, err := checkpoint.Load(filepath.Join(conf.outputDir, checkpoint.Filename))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		log.Println("there's no checkpoint, the input is read from the beginning")
+	case err != nil:
+		log.Println("cannot read the checkpoint, the input is read from the beginning:", err)
+	case !saved.Matches(conf.input, conf.urls):
+		log.Println("the checkpoint was made for a different input or URLs, the input is read from the beginning")
+	case saved.Position.File == "":
+		log.Println("the checkpoint is at the beginning of the input")
+	default:
+		start.Position = saved.Position
+		log.Printf("resuming from %v at offset %v", start.Position.File, start.Position.Offset)
+	}
+	log.Printf("%v responses have already been collected", collected)
+	return start, completed
+}
+
+func createSenderOptions(conf sendConfig) []sender.Option {
+	opts := []sender.Option{
+		sender.WithRetryPolicy(sender.RetryPolicy{
+			MaxAttempts:   conf.maxAttempts,
+			BaseDelay:     conf.retryDelay,
+			MaxDelay:      conf.maxRetryDelay,
+			RetryStatuses: conf.retryStatuses,
+		}),
+		sender.WithRepeat(conf.repeat, conf.repeatDelay),
+	}
+	for _, url := range conf.urls {
+		opts = append(opts, sender.WithTargetConfig(url, createTargetConfig(conf, url)))
+	}
+	return opts
+}
+
+func createTargetConfig(conf sendConfig, url string) sender.TargetConfig {
+	tlsConfig, err := sender.NewTLSConfig(sender.TLSOptions{
+		CACertFile:         targetValue(conf.caCert, conf.urls, url),
+		ClientCertFile:     targetValue(conf.clientCert, conf.urls, url),
+		ClientKeyFile:      targetValue(conf.clientKey, conf.urls, url),
+		ServerName:         targetValue(conf.serverName, conf.urls, url),
+		MinVersion:         targetValue(conf.tlsMinVersion, conf.urls, url),
+		InsecureSkipVerify: parseTargetBool("insecure", targetValue(conf.insecure, conf.urls, url)),
+	})
+	if err != nil {
+		log.Fatalf("%v: %v", url, err)
+	}
+
+	protocol, err := sender.ParseProtocol(targetValue(conf.protocol, conf.urls, url))
+	if err != nil {
+		log.Fatalf("invalid value for the --protocol flag: %v", err)
+	}
+
+	maxRedirects, err := sender.ParseRedirects(targetValue(conf.redirects, conf.urls, url))
+	if err != nil {
+		log.Fatalf("invalid value for the --redirects flag: %v", err)
+	}
+
+	var cookies []sender.Cookie
+	cookieFile := targetValue(conf.cookieFile, conf.urls, url)
+	if cookieFile != "" {
+		cookies, err = sender.LoadCookieFile(cookieFile)
+		if err != nil {
+			log.Fatalf("%v: %v", url, err)
+		}
+	}
+
+	var descriptors *protoregistry.Files
+	if descriptorSet := targetValue(conf.descriptorSet, conf.urls, url); descriptorSet != "" {
+		descriptors, err = sender.LoadDescriptorSet(descriptorSet)
+		if err != nil {
+			log.Fatalf("%v: %v", url, err)
+		}
+	}
+
+	resolve, err := sender.ParseResolve(conf.resolve)
+	if err != nil {
+		log.Fatalf("invalid value for the --resolve flag: %v", err)
+	}
+
+	return sender.TargetConfig{
+		RateLimit:   parseTargetFloat("rate-limit", targetValue(conf.rateLimit, conf.urls, url)),
+		MaxInFlight: parseTargetInt("max-in-flight", targetValue(conf.maxInFlight, conf.urls, url)),
+		Timeouts: sender.Timeouts{
+			Connect:        parseTargetDuration("connect-timeout", targetValue(conf.connectTimeout, conf.urls, url)),
+			TLSHandshake:   parseTargetDuration("tls-timeout", targetValue(conf.tlsTimeout, conf.urls, url)),
+			ResponseHeader: parseTargetDuration("header-timeout", targetValue(conf.headerTimeout, conf.urls, url)),
+			Total:          parseTargetDuration("timeout", targetValue(conf.timeout, conf.urls, url)),
+		},
+		TLSConfig:      tlsConfig,
+		Protocol:       protocol,
+		Proxy:          parseTargetUrl("proxy", targetValue(conf.proxy, conf.urls, url)),
+		NoProxy:        targetValue(conf.noProxy, conf.urls, url),
+		Auth:           parseTargetAuth(targetValue(conf.auth, conf.urls, url)),
+		Cookies:        cookieFile != "" || parseTargetBool("cookies", targetValue(conf.cookies, conf.urls, url)),
+		InitialCookies: cookies,
+		MaxRedirects:   maxRedirects,
+		MaxBodySize:    parseTargetSize("max-body-size", targetValue(conf.maxBodySize, conf.urls, url)),
+		Descriptors:    descriptors,
+		UnixSocket:     targetValue(conf.unixSocket, conf.urls, url),
+		Resolve:        resolve,
+	}
+}
+
+func createReqTransformation(conf sendConfig) transformer.ReqTransformation {
+	if conf.graphql && conf.transformation != "" {
+		log.Fatalln("the --graphql flag cannot be used together with a transformation script")
+	}
+	transformation := transformer.DefaultReqTransformation
+	if conf.graphql {
+		transformation = transformer.GraphqlReqTransformation
+	}
+	if conf.transformation != "" {
+		script := readTransformationScript(conf.transformation)
+		var err error
+		transformation, err = transformer.NewReqTransformation(script)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if conf.sessionColumn != "" {
+		transformation = transformer.SessionTransformation(transformation, conf.sessionColumn)
 	}
 	return transformation
 }
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"github.com/nikitakuchur/testpoint/internal/io/readers/respreader"
+	"github.com/nikitakuchur/testpoint/internal/mock"
+	"github.com/spf13/cobra"
+	"log"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+type serveConfig struct {
+	files           []string
+	listen          string
+	matchBody       bool
+	fallbackStatus  int
+	fallbackBody    string
+	shutdownTimeout time.Duration
+}
+
+func (c serveConfig) String() string {
+	return fmt.Sprintf(
+		"files: %v, listen: %v, matchBody: %v, fallbackStatus: %v, fallbackBody: %v, shutdownTimeout: %v",
+		c.files, c.listen, c.matchBody, c.fallbackStatus, c.fallbackBody, c.shutdownTimeout,
+	)
+}
+
+func newServeCmd() *cobra.Command {
+	var conf serveConfig
+
+	cmd := &cobra.Command{
+		Use:   "serve [flags] <response-file>...",
+		Short: "Serve the recorded responses as a mock server",
+		Long: "Load the response files produced by the send command and start an HTTP server that answers the requests " +
+			"with the recorded status, headers and body. The requests are matched by the method, the path, the query, " +
+			"and optionally the body.",
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			conf.files = args
+			if http.StatusText(conf.fallbackStatus) == "" {
+				log.Fatalf("invalid fallback status: %v", conf.fallbackStatus)
+			}
+
+			log.Printf("configuration: {%v}\n", conf)
+
+			server := mock.New(mock.Config{
+				MatchBody:      conf.matchBody,
+				FallbackStatus: conf.fallbackStatus,
+				FallbackBody:   conf.fallbackBody,
+			})
+			for _, file := range conf.files {
+				// the body files are stored next to the response file
+				loaded, skipped, err := server.Load(respreader.ReadResponses(file), filepath.Dir(file))
+				if err != nil {
+					log.Fatalf("cannot load %v: %v", file, err)
+				}
+				log.Printf("%v responses have been loaded from %v", loaded, file)
+				if skipped != 0 {
+					log.Printf("%v records from %v have been skipped, since they don't have a complete HTTP response", skipped, file)
+				}
+			}
+
+			sd := newShutdown(conf.shutdownTimeout)
+			defer sd.stop()
+
+			serve(sd, conf.listen, server, "serving the recorded responses")
+
+			if unmatched := server.Unmatched(); unmatched != 0 {
+				log.Printf("%v requests haven't been recorded and got the fallback response", unmatched)
+			}
+			log.Println("completed")
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&conf.listen, "listen", "l", ":8080", "address the server listens on")
+	flags.BoolVar(&conf.matchBody, "match-body", false, "match the requests by the body as well, the JSON bodies are compared regardless of formatting")
+	flags.IntVar(&conf.fallbackStatus, "fallback-status", http.StatusNotFound, "status of the responses to the requests that haven't been recorded")
+	flags.StringVar(&conf.fallbackBody, "fallback-body", "", "body of the responses to the requests that haven't been recorded")
+	flags.DurationVar(&conf.shutdownTimeout, "shutdown-timeout", 30*time.Second, "time to wait for the requests in flight after an interruption")
+
+	return cmd
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// shutdown handles SIGINT and SIGTERM in two steps. The first signal cancels the input context,
+// so the pipeline stops reading new requests, while the requests in flight are given the timeout to complete.
+// After the timeout, the requests context is canceled too. Another signal kills the process right away.
+type shutdown struct {
+	input          context.Context
+	requests       context.Context
+	stopSignals    context.CancelFunc
+	cancelRequests context.CancelFunc
+}
+
+func newShutdown(timeout time.Duration) *shutdown {
+	input, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	requests, cancelRequests := context.WithCancel(context.Background())
+	s := &shutdown{input, requests, stopSignals, cancelRequests}
+
+	go func() {
+		select {
+		case <-input.Done():
+		case <-requests.Done():
+			return
+		}
+		// the requests context is canceled before the input one when the work is done, so it isn't an interruption
+		if requests.Err() != nil {
+			return
+		}
+		// the default behavior is restored, so the next signal terminates the process
+		stopSignals()
+
+		log.Printf("interrupted, waiting up to %v for the requests in flight to complete...", timeout)
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			log.Println("the requests in flight didn't complete in time, canceling them...")
+			cancelRequests()
+		case <-requests.Done():
+		}
+	}()
+
+	return s
+}
+
+// interrupted checks whether the process has received a signal.
+func (s *shutdown) interrupted() bool {
+	return s.input.Err() != nil
+}
+
+// stop releases the resources, it must be called when the work is done.
+func (s *shutdown) stop() {
+	s.cancelRequests()
+	s.stopSignals()
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"github.com/nikitakuchur/testpoint/internal/auth"
+	"github.com/nikitakuchur/testpoint/internal/secrets"
+	"github.com/spf13/pflag"
+	"log"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// addTargetFlags adds the flags that configure how the requests are sent to each target.
+// They are shared by the commands that send requests.
+func addTargetFlags(flags *pflag.FlagSet, conf *sendConfig) {
+	flags.StringArrayVar(&conf.connectTimeout, "connect-timeout", nil, "timeout for establishing a connection (default 30s)")
+	flags.StringArrayVar(&conf.tlsTimeout, "tls-timeout", nil, "timeout for the TLS handshake (default 10s)")
+	flags.StringArrayVar(&conf.headerTimeout, "header-timeout", nil, "timeout for receiving the response headers after the request is written")
+	flags.StringArrayVar(&conf.timeout, "timeout", nil, "timeout for the whole request, including reading the response body")
+
+	flags.StringArrayVar(&conf.caCert, "ca-cert", nil, "PEM file with the trusted CA certificates")
+	flags.StringArrayVar(&conf.clientCert, "client-cert", nil, "PEM file with the client certificate for mutual TLS")
+	flags.StringArrayVar(&conf.clientKey, "client-key", nil, "PEM file with the client key for mutual TLS")
+	flags.StringArrayVar(&conf.serverName, "server-name", nil, "server name used to verify the certificate and sent via SNI")
+	flags.StringArrayVar(&conf.tlsMinVersion, "tls-min-version", nil, "minimum TLS version: 1.0, 1.1, 1.2 or 1.3")
+	flags.StringArrayVar(&conf.insecure, "insecure", nil, "skip the server certificate verification (don't use it in production!), "+
+		"use --insecure=<url>=true to set it for a specific target, the equals sign is required")
+	flags.Lookup("insecure").NoOptDefVal = "true"
+
+	flags.StringArrayVar(&conf.protocol, "protocol", nil, "HTTP protocol: h1, h2 (HTTP/2 over TLS) or h2c (cleartext HTTP/2), negotiated by default")
+	flags.StringArrayVar(&conf.proxy, "proxy", nil, "URL of the proxy the requests are sent through (default from HTTP_PROXY and HTTPS_PROXY)")
+	flags.StringArrayVar(&conf.noProxy, "no-proxy", nil, "comma-separated list of hosts that bypass the proxy (default from NO_PROXY)")
+	flags.StringArrayVar(&conf.unixSocket, "unix-socket", nil, "path of the Unix socket the connections are made to instead of the host from the URL")
+	flags.StringArrayVar(&conf.resolve, "resolve", nil, "connect to the given IP address instead of resolving the host, in the <host>:<port>:<address> format")
+
+	flags.StringArrayVar(&conf.cookies, "cookies", nil, "keep the cookies set by the responses and send them with the following requests, "+
+		"use --cookies=<url>=true to set it for a specific target, the equals sign is required")
+	flags.Lookup("cookies").NoOptDefVal = "true"
+	flags.StringArrayVar(&conf.cookieFile, "cookie-file", nil, "cookie file in the Netscape format to start with, it enables the cookies")
+
+	flags.StringArrayVar(&conf.redirects, "redirects", nil, "redirect policy: follow (up to 10 redirects), none, or the maximum number of redirects")
+
+	flags.StringArrayVar(&conf.maxBodySize, "max-body-size", nil, "maximum size of a response body to keep, e.g. 512KB or 10MB, the rest is truncated")
+
+	flags.StringArrayVar(&conf.descriptorSet, "descriptor-set", nil, "file descriptor set for the gRPC targets, the server reflection is used by default")
+
+	flags.StringArrayVar(&conf.auth, "auth", nil, "authentication in the <type>:<params> format, where the type is bearer, basic, api-key, or oauth2")
+}
+
+// targetFlagsString describes the flags added by addTargetFlags for the log, the credentials are hidden.
+// The URLs are the targets the flag values can be set for.
+func (c sendConfig) targetFlagsString(urls []string) string {
+	return fmt.Sprintf(
+		"connectTimeout: %v, tlsTimeout: %v, headerTimeout: %v, timeout: %v, "+
+			"caCert: %v, clientCert: %v, clientKey: %v, serverName: %v, tlsMinVersion: %v, insecure: %v, "+
+			"protocol: %v, proxy: %v, noProxy: %v, unixSocket: %v, resolve: %v, "+
+			"cookies: %v, cookieFile: %v, redirects: %v, maxBodySize: %v, descriptorSet: %v, auth: %v",
+		c.connectTimeout, c.tlsTimeout, c.headerTimeout, c.timeout,
+		c.caCert, c.clientCert, c.clientKey, c.serverName, c.tlsMinVersion, c.insecure,
+		c.protocol, redactTargetUrls(c.proxy, urls), c.noProxy, c.unixSocket, c.resolve,
+		c.cookies, c.cookieFile, c.redirects, c.maxBodySize, c.descriptorSet, authTypes(c.auth, urls),
+	)
+}
+
+// targetValue finds the value of a per-target flag for the given target URL.
+// Each flag value is either a plain value that applies to all targets or a value
+// for a specific target in the <url>=<value> format. The target-specific values take precedence.
+func targetValue(values []string, urls []string, url string) string {
+	var result, targetResult string
+	var found bool
+	for _, v := range values {
+		prefix, value, ok := strings.Cut(v, "=")
+		if ok && slices.Contains(urls, prefix) {
+			if prefix == url {
+				targetResult, found = value, true
+			}
+			continue
+		}
+		result = v
+	}
+	if found {
+		return targetResult
+	}
+	return result
+}
+
+func parseTargetFloat(flag string, value string) float64 {
+	if value == "" {
+		return 0
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Fatalf("invalid value '%v' for the --%v flag: %v", value, flag, err)
+	}
+	return v
+}
+
+func parseTargetInt(flag string, value string) int {
+	if value == "" {
+		return 0
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("invalid value '%v' for the --%v flag: %v", value, flag, err)
+	}
+	return v
+}
+
+func parseTargetDuration(flag string, value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	v, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("invalid value '%v' for the --%v flag: %v", value, flag, err)
+	}
+	return v
+}
+
+func parseTargetBool(flag string, value string) bool {
+	if value == "" {
+		return false
+	}
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatalf("invalid value '%v' for the --%v flag: %v", value, flag, err)
+	}
+	return v
+}
+
+// parseTargetSize parses a size in bytes, which can have a KB, MB or GB suffix.
+func parseTargetSize(flag string, value string) int {
+	if value == "" {
+		return 0
+	}
+	multiplier := 1
+	number := strings.ToUpper(value)
+	for suffix, m := range map[string]int{"KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30} {
+		if strings.HasSuffix(number, suffix) {
+			number, multiplier = strings.TrimSuffix(number, suffix), m
+			break
+		}
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(number))
+	if err != nil || v < 0 {
+		log.Fatalf("invalid value '%v' for the --%v flag: expected a number of bytes, KB, MB or GB", value, flag)
+	}
+	return v * multiplier
+}
+
+func parseTargetUrl(flag string, value string) *url.URL {
+	if value == "" {
+		return nil
+	}
+	v, err := url.Parse(value)
+	if err != nil {
+		log.Fatalf("invalid value for the --%v flag: %v", flag, err)
+	}
+	return v
+}
+
+// parseTargetAuth creates an auth provider, the credentials can be passed as secret placeholders.
+func parseTargetAuth(value string) auth.Provider {
+	expanded, err := secrets.Expand(value)
+	if err != nil {
+		log.Fatalln("invalid value for the --auth flag:", err)
+	}
+	provider, err := auth.Parse(expanded)
+	if err != nil {
+		// the error may contain the credentials
+		log.Fatalln("invalid value for the --auth flag:", secrets.MaskSecrets(err.Error()))
+	}
+	return provider
+}
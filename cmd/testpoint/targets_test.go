@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"github.com/spf13/pflag"
+	"strings"
+	"testing"
+)
+
+func TestTargetFlagsWithoutValue(t *testing.T) {
+	var conf sendConfig
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	addTargetFlags(flags, &conf)
+
+	urls := []string{"https://a.test", "https://b.test"}
+	err := flags.Parse([]string{"--insecure=https://a.test=true", "--cookies", "--cookies=https://b.test=false", "./requests.csv"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		flag     string
+		values   []string
+		url      string
+		expected bool
+	}{
+		{flag: "insecure", values: conf.insecure, url: "https://a.test", expected: true},
+		{flag: "insecure", values: conf.insecure, url: "https://b.test", expected: false},
+		{flag: "cookies", values: conf.cookies, url: "https://a.test", expected: true},
+		{flag: "cookies", values: conf.cookies, url: "https://b.test", expected: false},
+	}
+	for _, test := range tests {
+		if actual := parseTargetBool(test.flag, targetValue(test.values, urls, test.url)); actual != test.expected {
+			t.Errorf("incorrect value of --%v for %v: expected %v, got %v", test.flag, test.url, test.expected, actual)
+		}
+	}
+	if args := flags.Args(); len(args) != 1 || args[0] != "./requests.csv" {
+		t.Errorf("incorrect arguments: %v", args)
+	}
+}
+
+func TestConfigStrings(t *testing.T) {
+	send := sendConfig{urls: []string{"http://a.test"}, auth: []string{"bearer:secret"}}
+
+	tests := []struct {
+		name   string
+		config fmt.Stringer
+	}{
+		{name: "proxy", config: proxyConfig{sendConfig: send}},
+		{name: "record", config: recordConfig{sendConfig: send, upstream: "http://a.test"}},
+		{name: "bench", config: benchConfig{sendConfig: send}},
+	}
+	// the fields of the send command that the other commands don't have flags for
+	sendOnly := []string{"outputDir:", "maxAttempts:", "lockStep:", "resume:", "repeat:"}
+	for _, test := range tests {
+		actual := test.config.String()
+		for _, field := range sendOnly {
+			if strings.Contains(actual, field) {
+				t.Errorf("%v: unexpected field %v in %v", test.name, field, actual)
+			}
+		}
+		if !strings.Contains(actual, "auth: [bearer]") || strings.Contains(actual, "secret") {
+			t.Errorf("%v: incorrect target flags in %v", test.name, actual)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Provider adds credentials to the requests sent to a target.
+type Provider interface {
+	// Apply adds the credentials to the given request.
+	Apply(req *http.Request) error
+}
+
+// Refresher is implemented by the providers whose credentials can be renewed, for example, when they expire.
+type Refresher interface {
+	// Invalidate is called when the target rejects the credentials the given request was sent with.
+	// The next call to Apply should use new credentials.
+	Invalidate(req *http.Request)
+}
+
+// Bearer sends a static token in the Authorization header.
+type Bearer struct {
+	Token string
+}
+
+func (a Bearer) Apply(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+	return nil
+}
+
+// Basic uses the HTTP basic authentication.
+type Basic struct {
+	Username string
+	Password string
+}
+
+func (a Basic) Apply(req *http.Request) error {
+	req.SetBasicAuth(a.Username, a.Password)
+	return nil
+}
+
+// The places where an API key can be sent.
+const (
+	InHeader = "header"
+	InQuery  = "query"
+)
+
+// APIKey sends a key in a header or a query parameter.
+type APIKey struct {
+	// In is either InHeader or InQuery.
+	In    string
+	Name  string
+	Value string
+}
+
+func (a APIKey) Apply(req *http.Request) error {
+	switch a.In {
+	case InHeader:
+		req.Header.Set(a.Name, a.Value)
+	case InQuery:
+		query := req.URL.Query()
+		query.Set(a.Name, a.Value)
+		req.URL.RawQuery = query.Encode()
+	default:
+		return fmt.Errorf("unknown API key location '%v'", a.In)
+	}
+	return nil
+}
+
+// Parse creates a provider from its specification in the <type>:<params> format. The supported types are:
+//
+//	bearer:<token>
+//	basic:<username>:<password>
+//	api-key:<header|query>:<name>:<value>
+//	oauth2:token_url=<url>,client_id=<id>,client_secret=<secret>[,scope=<scope>]
+//
+// An empty specification means no authentication, in which case it returns nil.
+func Parse(spec string) (Provider, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	kind, params, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "bearer":
+		if params == "" {
+			return nil, errors.New("the bearer token is empty")
+		}
+		return Bearer{params}, nil
+	case "basic":
+		username, password, ok := strings.Cut(params, ":")
+		if !ok {
+			return nil, errors.New("basic auth must be in the basic:<username>:<password> format")
+		}
+		return Basic{username, password}, nil
+	case "api-key":
+		parts := strings.SplitN(params, ":", 3)
+		if len(parts) != 3 || (parts[0] != InHeader && parts[0] != InQuery) || parts[1] == "" {
+			return nil, errors.New("API key must be in the api-key:<header|query>:<name>:<value> format")
+		}
+		return APIKey{parts[0], parts[1], parts[2]}, nil
+	case "oauth2":
+		conf, err := parseOAuth2Config(params)
+		if err != nil {
+			return nil, err
+		}
+		return NewOAuth2(conf), nil
+	}
+	return nil, fmt.Errorf("unknown auth type '%v', must be bearer, basic, api-key, or oauth2", kind)
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// expiryDelta is how long before the expiration we consider a token expired,
+// so it doesn't expire while the request is on its way.
+const expiryDelta = 10 * time.Second
+
+// OAuth2Config describes how to get a token with the OAuth2 client credentials grant.
+type OAuth2Config struct {
+	TokenUrl     string
+	ClientId     string
+	ClientSecret string
+	// Scope is a space-separated list of scopes, it's optional.
+	Scope string
+	// Client is used to request tokens, nil means the default client.
+	Client *http.Client
+}
+
+// OAuth2 gets tokens from the token endpoint and sends them in the Authorization header.
+// The token is cached until it expires or the target rejects it.
+type OAuth2 struct {
+	conf OAuth2Config
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func NewOAuth2(conf OAuth2Config) *OAuth2 {
+	if conf.Client == nil {
+		conf.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &OAuth2{conf: conf}
+}
+
+func (a *OAuth2) Apply(req *http.Request) error {
+	token, err := a.currentToken()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (a *OAuth2) Invalidate(req *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// other requests may have already got a new token, and we don't want to throw it away
+	if req.Header.Get("Authorization") == "Bearer "+a.token {
+		a.token = ""
+	}
+}
+
+// currentToken returns the cached token or fetches a new one if there's no valid token.
+// The lock is held while the token is being fetched, so the concurrent requests wait for it instead of fetching their own.
+func (a *OAuth2) currentToken() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && (a.expiry.IsZero() || time.Now().Before(a.expiry)) {
+		return a.token, nil
+	}
+
+	token, expiresIn, err := a.fetchToken()
+	if err != nil {
+		return "", err
+	}
+	a.token = token
+	a.expiry = time.Time{}
+	if expiresIn > 0 {
+		a.expiry = time.Now().Add(time.Duration(expiresIn)*time.Second - expiryDelta)
+	}
+	return a.token, nil
+}
+
+func (a *OAuth2) fetchToken() (string, int, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if a.conf.Scope != "" {
+		form.Set("scope", a.conf.Scope)
+	}
+
+	req, err := http.NewRequest("POST", a.conf.TokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, fmt.Errorf("cannot create a token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(a.conf.ClientId), url.QueryEscape(a.conf.ClientSecret))
+
+	resp, err := a.conf.Client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("cannot get a token: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", 0, fmt.Errorf("cannot read the token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("cannot get a token: the token endpoint responded with status %v", resp.StatusCode)
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", 0, fmt.Errorf("cannot parse the token response: %w", err)
+	}
+	if result.AccessToken == "" {
+		return "", 0, errors.New("the token response doesn't contain an access token")
+	}
+	return result.AccessToken, result.ExpiresIn, nil
+}
+
+func parseOAuth2Config(params string) (OAuth2Config, error) {
+	var conf OAuth2Config
+	for _, param := range strings.Split(params, ",") {
+		key, value, _ := strings.Cut(param, "=")
+		switch key {
+		case "token_url":
+			conf.TokenUrl = value
+		case "client_id":
+			conf.ClientId = value
+		case "client_secret":
+			conf.ClientSecret = value
+		case "scope":
+			conf.Scope = value
+		default:
+			return OAuth2Config{}, fmt.Errorf("unknown OAuth2 parameter '%v'", key)
+		}
+	}
+	if conf.TokenUrl == "" || conf.ClientId == "" {
+		return OAuth2Config{}, errors.New("OAuth2 requires at least the token_url and client_id parameters")
+	}
+	return conf, nil
+}
@@ -0,0 +1,89 @@
+package auth_test
+
+import (
+	"fmt"
+	"github.com/nikitakuchur/testpoint/internal/auth"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// newTokenServer starts a token endpoint that issues a new token on every request.
+func newTokenServer(t *testing.T, expiresIn int) (*httptest.Server, *atomic.Int32) {
+	var count atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		id, secret, _ := req.BasicAuth()
+		if req.Method != "POST" || req.FormValue("grant_type") != "client_credentials" || id != "id" || secret != "secret" {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		n := count.Add(1)
+		_, _ = fmt.Fprintf(rw, `{"access_token":"token-%v","token_type":"Bearer","expires_in":%v}`, n, expiresIn)
+	}))
+	t.Cleanup(server.Close)
+	return server, &count
+}
+
+func TestOAuth2(t *testing.T) {
+	server, count := newTokenServer(t, 3600)
+	provider := auth.NewOAuth2(auth.OAuth2Config{TokenUrl: server.URL, ClientId: "id", ClientSecret: "secret"})
+
+	for i := 0; i < 3; i++ {
+		req := applyOAuth2(t, provider)
+		if req.Header.Get("Authorization") != "Bearer token-1" {
+			t.Errorf("incorrect result: expected the cached token, got '%v'", req.Header.Get("Authorization"))
+		}
+	}
+	if count.Load() != 1 {
+		t.Errorf("incorrect result: expected 1 token request, got %v", count.Load())
+	}
+}
+
+func TestOAuth2WithExpiredToken(t *testing.T) {
+	// the token expires sooner than the expiry delta, so it's never reused
+	server, count := newTokenServer(t, 1)
+	provider := auth.NewOAuth2(auth.OAuth2Config{TokenUrl: server.URL, ClientId: "id", ClientSecret: "secret"})
+
+	applyOAuth2(t, provider)
+	req := applyOAuth2(t, provider)
+
+	if req.Header.Get("Authorization") != "Bearer token-2" || count.Load() != 2 {
+		t.Errorf("incorrect result: expected a new token, got '%v'", req.Header.Get("Authorization"))
+	}
+}
+
+func TestOAuth2WithInvalidatedToken(t *testing.T) {
+	server, _ := newTokenServer(t, 3600)
+	provider := auth.NewOAuth2(auth.OAuth2Config{TokenUrl: server.URL, ClientId: "id", ClientSecret: "secret"})
+
+	first := applyOAuth2(t, provider)
+	second := applyOAuth2(t, provider)
+	provider.Invalidate(first)
+	third := applyOAuth2(t, provider)
+	// the token has already been replaced, so invalidating it again changes nothing
+	provider.Invalidate(second)
+	fourth := applyOAuth2(t, provider)
+
+	if third.Header.Get("Authorization") != "Bearer token-2" || fourth.Header.Get("Authorization") != "Bearer token-2" {
+		t.Errorf("incorrect result: expected token-2, got '%v' and '%v'", third.Header.Get("Authorization"), fourth.Header.Get("Authorization"))
+	}
+}
+
+func TestOAuth2WithIncorrectCredentials(t *testing.T) {
+	server, _ := newTokenServer(t, 3600)
+	provider := auth.NewOAuth2(auth.OAuth2Config{TokenUrl: server.URL, ClientId: "id", ClientSecret: "wrong"})
+
+	req, _ := http.NewRequest("GET", "http://test.com/api", nil)
+	if err := provider.Apply(req); err == nil {
+		t.Error("incorrect result: expected an error")
+	}
+}
+
+func applyOAuth2(t *testing.T, provider *auth.OAuth2) *http.Request {
+	req, _ := http.NewRequest("GET", "http://test.com/api", nil)
+	if err := provider.Apply(req); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
@@ -0,0 +1,81 @@
+package bench
+
+import (
+	"context"
+	"github.com/nikitakuchur/testpoint/internal/endpoint"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"strconv"
+	"time"
+)
+
+// Config describes the load that is put on a target.
+type Config struct {
+	// Duration is how long the requests are sent for.
+	Duration time.Duration
+	// Warmup is how long the requests are sent for before the measurement, so the connections and caches are warmed up.
+	Warmup time.Duration
+	// Concurrency is the number of requests sent at the same time.
+	// If the sender has a rate limit for the target, it's the maximum number of requests that wait for the response.
+	Concurrency int
+}
+
+// Sample is the outcome of one request.
+type Sample struct {
+	Group   string
+	Latency time.Duration
+	Failed  bool
+}
+
+// Run replays the requests in a loop for the configured duration and returns the samples along with the actual
+// duration of the measurement. Once the time is up, no more requests are sent,
+// and the requests that are still in flight are waited for and counted, since they were started in time.
+// If the context is canceled, the requests in flight are canceled too.
+func Run(ctx context.Context, s sender.Sender, requests []sender.Request, conf Config) ([]Sample, time.Duration) {
+	if len(requests) == 0 {
+		return nil, 0
+	}
+	if conf.Warmup > 0 {
+		run(ctx, s, requests, conf.Concurrency, conf.Warmup)
+	}
+	return run(ctx, s, requests, conf.Concurrency, conf.Duration)
+}
+
+func run(ctx context.Context, s sender.Sender, requests []sender.Request, concurrency int, duration time.Duration) ([]Sample, time.Duration) {
+	start := time.Now()
+	deadline := start.Add(duration)
+	input, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	reqs := make(chan sender.Request)
+	go func() {
+		defer close(reqs)
+
+		for i := 0; ; i = (i + 1) % len(requests) {
+			select {
+			case reqs <- requests[i]:
+			case <-input.Done():
+				return
+			}
+		}
+	}()
+
+	var samples []Sample
+	for rr := range s.SendRequests(ctx, reqs, concurrency) {
+		// the requests are counted by their start, otherwise the slowest requests would be left out at the end of each run
+		if rr.Response.Start.Before(deadline) {
+			samples = append(samples, newSample(rr))
+		}
+	}
+	return samples, min(time.Since(start), duration)
+}
+
+// newSample creates a sample from the response.
+// Besides the requests that have failed, the server errors are counted as failures, since the server couldn't handle the request.
+func newSample(rr sender.RequestResponse) Sample {
+	status, _ := strconv.Atoi(rr.Response.Status)
+	return Sample{
+		Group:   endpoint.Group(rr.Request),
+		Latency: rr.Response.Duration,
+		Failed:  rr.Response.Error != "" || status >= 500,
+	}
+}
@@ -0,0 +1,108 @@
+package bench_test
+
+import (
+	"context"
+	"github.com/nikitakuchur/testpoint/internal/bench"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	var received atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		if r.URL.Path == "/error" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	requests := []sender.Request{
+		{Url: server.URL + "/ok", Method: "GET", UserUrl: server.URL},
+		{Url: server.URL + "/error", Method: "GET", UserUrl: server.URL},
+	}
+
+	s := sender.NewSender(sender.WithRetryPolicy(sender.RetryPolicy{MaxAttempts: 1}))
+	samples, elapsed := bench.Run(context.Background(), s, requests, bench.Config{
+		Duration:    200 * time.Millisecond,
+		Warmup:      50 * time.Millisecond,
+		Concurrency: 2,
+	})
+
+	if elapsed <= 0 || elapsed > 200*time.Millisecond {
+		t.Errorf("incorrect duration: %v", elapsed)
+	}
+	if len(samples) < 2 {
+		t.Fatalf("incorrect result: expected some samples, got %v", len(samples))
+	}
+	// the warmup requests are sent, but not counted
+	if int64(len(samples)) >= received.Load() {
+		t.Errorf("incorrect result: %v samples for %v received requests", len(samples), received.Load())
+	}
+	for _, s := range samples {
+		if s.Failed != (s.Group == "GET /error") {
+			t.Errorf("incorrect sample: %+v", s)
+		}
+	}
+}
+
+func TestRunWithCanceledContext(t *testing.T) {
+	requests := []sender.Request{{Url: "http://localhost:1/test", Method: "GET", UserUrl: "http://localhost:1"}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s := sender.NewSender(sender.WithRetryPolicy(sender.RetryPolicy{MaxAttempts: 1}))
+	samples, _ := bench.Run(ctx, s, requests, bench.Config{Duration: time.Minute, Concurrency: 1})
+
+	if len(samples) != 0 {
+		t.Errorf("incorrect result: expected no samples, got %v", len(samples))
+	}
+}
+
+func TestRunWithSlowRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(150 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	requests := []sender.Request{{Url: server.URL + "/test", Method: "GET", UserUrl: server.URL}}
+
+	s := sender.NewSender(sender.WithRetryPolicy(sender.RetryPolicy{MaxAttempts: 1}))
+	samples, _ := bench.Run(context.Background(), s, requests, bench.Config{
+		Duration:    200 * time.Millisecond,
+		Concurrency: 1,
+	})
+
+	// the second request finishes after the deadline, but it was started before it
+	if len(samples) != 2 {
+		t.Fatalf("incorrect result: expected 2 samples, got %v", len(samples))
+	}
+	for _, s := range samples {
+		if s.Latency < 150*time.Millisecond {
+			t.Errorf("incorrect sample: %+v", s)
+		}
+	}
+}
+
+func TestRunWithFailedRequestsAfterDeadline(t *testing.T) {
+	userUrl := "http://localhost:1"
+	requests := []sender.Request{{Url: userUrl + "/test", Method: "GET", UserUrl: userUrl}}
+
+	// the rate limit holds the next requests back until the time is up, and then they fail right away
+	s := sender.NewSender(
+		sender.WithRetryPolicy(sender.RetryPolicy{MaxAttempts: 1}),
+		sender.WithTargetConfig(userUrl, sender.TargetConfig{RateLimit: 5}),
+	)
+	samples, _ := bench.Run(context.Background(), s, requests, bench.Config{
+		Duration:    100 * time.Millisecond,
+		Concurrency: 1,
+	})
+
+	if len(samples) != 1 || !samples[0].Failed {
+		t.Errorf("incorrect result: expected 1 failed sample, got %+v", samples)
+	}
+}
@@ -0,0 +1,85 @@
+package bench
+
+import (
+	"fmt"
+	"time"
+)
+
+// Thresholds define how much worse the candidate can be than the baseline before it's a regression.
+type Thresholds struct {
+	// Latency is the maximum relative increase of a latency percentile, e.g. 0.1 for 10%.
+	Latency float64
+	// ErrorRate is the maximum absolute increase of the error rate, e.g. 0.01 for one percentage point.
+	ErrorRate float64
+	// MinSamples is the minimum number of requests in a group on both sides for it to be checked,
+	// since the percentiles of a few requests are mostly noise.
+	MinSamples int
+}
+
+// Comparison is the result of comparing an endpoint group of two targets.
+type Comparison struct {
+	Group     string
+	Baseline  Stats
+	Candidate Stats
+	// Regressions describe what has got worse than the thresholds allow.
+	Regressions []string
+	// Skipped is set if the group doesn't have enough samples to be checked.
+	Skipped bool
+}
+
+// Compare checks every endpoint group of the candidate against the same group of the baseline.
+// The groups that exist only on one side are skipped, since there's nothing to compare them with.
+func Compare(baseline Summary, candidate Summary, thresholds Thresholds) []Comparison {
+	var result []Comparison
+	for _, group := range baseline.GroupNames() {
+		c, ok := candidate.Groups[group]
+		if !ok {
+			continue
+		}
+		b := baseline.Groups[group]
+		comparison := Comparison{Group: group, Baseline: b, Candidate: c}
+		if b.Requests < thresholds.MinSamples || c.Requests < thresholds.MinSamples {
+			comparison.Skipped = true
+		} else {
+			comparison.Regressions = findRegressions(b, c, thresholds)
+		}
+		result = append(result, comparison)
+	}
+	return result
+}
+
+func findRegressions(b Stats, c Stats, thresholds Thresholds) []string {
+	var regressions []string
+	latencies := []struct {
+		name      string
+		baseline  time.Duration
+		candidate time.Duration
+	}{
+		{"p50", b.P50, c.P50},
+		{"p90", b.P90, c.P90},
+		{"p99", b.P99, c.P99},
+	}
+	for _, l := range latencies {
+		if l.baseline <= 0 {
+			continue
+		}
+		change := float64(l.candidate-l.baseline) / float64(l.baseline)
+		if change > thresholds.Latency {
+			regressions = append(regressions, fmt.Sprintf("%v %+.1f%%", l.name, change*100))
+		}
+	}
+	if change := c.ErrorRate() - b.ErrorRate(); change > thresholds.ErrorRate {
+		regressions = append(regressions, fmt.Sprintf("errors %+.2fpp", change*100))
+	}
+	return regressions
+}
+
+// HasRegressions checks whether any of the groups has a regression.
+func HasRegressions(comparisons []Comparison) bool {
+	for _, c := range comparisons {
+		if len(c.Regressions) > 0 {
+			return true
+		}
+	}
+	return false
+}
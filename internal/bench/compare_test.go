@@ -0,0 +1,45 @@
+package bench_test
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/nikitakuchur/testpoint/internal/bench"
+	"testing"
+	"time"
+)
+
+func TestCompare(t *testing.T) {
+	stats := func(requests int, failures int, p50 time.Duration, p99 time.Duration) bench.Stats {
+		return bench.Stats{Requests: requests, Failures: failures, P50: p50, P90: p50, P99: p99, Max: p99}
+	}
+	baseline := bench.Summary{Groups: map[string]bench.Stats{
+		bench.TotalGroup: stats(1000, 0, 10*time.Millisecond, 50*time.Millisecond),
+		"GET /fast":      stats(500, 0, 10*time.Millisecond, 20*time.Millisecond),
+		"GET /slow":      stats(490, 0, 10*time.Millisecond, 50*time.Millisecond),
+		"GET /rare":      stats(10, 0, 10*time.Millisecond, 10*time.Millisecond),
+		"GET /removed":   stats(500, 0, 10*time.Millisecond, 10*time.Millisecond),
+	}}
+	candidate := bench.Summary{Groups: map[string]bench.Stats{
+		bench.TotalGroup: stats(1000, 10, 10*time.Millisecond, 54*time.Millisecond),
+		"GET /fast":      stats(500, 10, 11*time.Millisecond, 21*time.Millisecond),
+		"GET /slow":      stats(490, 0, 10*time.Millisecond, 60*time.Millisecond),
+		"GET /rare":      stats(10, 10, 100*time.Millisecond, 100*time.Millisecond),
+	}}
+
+	actual := bench.Compare(baseline, candidate, bench.Thresholds{Latency: 0.1, ErrorRate: 0.01, MinSamples: 100})
+
+	expected := []bench.Comparison{
+		{Group: bench.TotalGroup, Baseline: baseline.Groups[bench.TotalGroup], Candidate: candidate.Groups[bench.TotalGroup]},
+		{Group: "GET /fast", Baseline: baseline.Groups["GET /fast"], Candidate: candidate.Groups["GET /fast"], Regressions: []string{"errors +2.00pp"}},
+		{Group: "GET /rare", Baseline: baseline.Groups["GET /rare"], Candidate: candidate.Groups["GET /rare"], Skipped: true},
+		{Group: "GET /slow", Baseline: baseline.Groups["GET /slow"], Candidate: candidate.Groups["GET /slow"], Regressions: []string{"p99 +20.0%"}},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("incorrect result (-expected +actual):\n%s", diff)
+	}
+	if !bench.HasRegressions(actual) {
+		t.Error("incorrect result: expected regressions")
+	}
+	if bench.HasRegressions(actual[:1]) {
+		t.Error("incorrect result: expected no regressions in the total")
+	}
+}
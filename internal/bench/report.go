@@ -0,0 +1,67 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// WriteSummary prints the stats of every endpoint group of the target as a table.
+func WriteSummary(out io.Writer, s Summary) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "%v (%v)\n", s.Target, s.Duration.Round(time.Millisecond))
+	_, _ = fmt.Fprintln(w, "GROUP\tREQUESTS\tERRORS\tRPS\tP50\tP90\tP99\tMAX")
+	for _, name := range s.GroupNames() {
+		stats := s.Groups[name]
+		_, _ = fmt.Fprintf(w, "%v\t%v\t%.2f%%\t%.1f\t%v\t%v\t%v\t%v\n",
+			name, stats.Requests, stats.ErrorRate()*100, stats.Throughput,
+			formatLatency(stats.P50), formatLatency(stats.P90), formatLatency(stats.P99), formatLatency(stats.Max),
+		)
+	}
+	_, _ = fmt.Fprintln(w)
+	return w.Flush()
+}
+
+// WriteComparison prints the comparison of two targets as a table.
+func WriteComparison(out io.Writer, baseline string, candidate string, comparisons []Comparison) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "%v vs %v\n", candidate, baseline)
+	_, _ = fmt.Fprintln(w, "GROUP\tP50\tP90\tP99\tERRORS\tRPS\tRESULT")
+	for _, c := range comparisons {
+		result := "ok"
+		switch {
+		case c.Skipped:
+			result = "not enough samples"
+		case len(c.Regressions) > 0:
+			result = "REGRESSION: " + strings.Join(c.Regressions, ", ")
+		}
+		_, _ = fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%.2f%% -> %.2f%%\t%.1f -> %.1f\t%v\n",
+			c.Group,
+			formatChange(c.Baseline.P50, c.Candidate.P50),
+			formatChange(c.Baseline.P90, c.Candidate.P90),
+			formatChange(c.Baseline.P99, c.Candidate.P99),
+			c.Baseline.ErrorRate()*100, c.Candidate.ErrorRate()*100,
+			c.Baseline.Throughput, c.Candidate.Throughput,
+			result,
+		)
+	}
+	_, _ = fmt.Fprintln(w)
+	return w.Flush()
+}
+
+func formatLatency(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return d.Round(10 * time.Microsecond).String()
+}
+
+func formatChange(baseline time.Duration, candidate time.Duration) string {
+	if baseline == 0 || candidate == 0 {
+		return formatLatency(baseline) + " -> " + formatLatency(candidate)
+	}
+	change := float64(candidate-baseline) / float64(baseline) * 100
+	return fmt.Sprintf("%v -> %v (%+.1f%%)", formatLatency(baseline), formatLatency(candidate), change)
+}
@@ -0,0 +1,95 @@
+package bench
+
+import (
+	"maps"
+	"slices"
+	"time"
+)
+
+// TotalGroup is the name of the group that has all the requests.
+const TotalGroup = "total"
+
+// Stats is the summary of the samples. The latencies are only calculated for the requests that haven't failed.
+type Stats struct {
+	Requests   int
+	Failures   int
+	Throughput float64
+	P50        time.Duration
+	P90        time.Duration
+	P99        time.Duration
+	Max        time.Duration
+}
+
+// ErrorRate is the share of the failed requests.
+func (s Stats) ErrorRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Failures) / float64(s.Requests)
+}
+
+// Summary is the result of the benchmark for a target.
+type Summary struct {
+	Target   string
+	Duration time.Duration
+	// Groups has the stats for each endpoint group and for all the requests together under TotalGroup.
+	Groups map[string]Stats
+}
+
+// GroupNames returns the names of the groups, the total goes first and the rest are sorted.
+func (s Summary) GroupNames() []string {
+	var names []string
+	for name := range maps.Keys(s.Groups) {
+		if name != TotalGroup {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return append([]string{TotalGroup}, names...)
+}
+
+// Summarize calculates the stats of the samples collected during the given duration.
+func Summarize(target string, samples []Sample, duration time.Duration) Summary {
+	groups := map[string][]Sample{TotalGroup: samples}
+	for _, s := range samples {
+		groups[s.Group] = append(groups[s.Group], s)
+	}
+
+	summary := Summary{Target: target, Duration: duration, Groups: make(map[string]Stats, len(groups))}
+	for name, group := range groups {
+		summary.Groups[name] = calculateStats(group, duration)
+	}
+	return summary
+}
+
+func calculateStats(samples []Sample, duration time.Duration) Stats {
+	stats := Stats{Requests: len(samples)}
+	if duration > 0 {
+		stats.Throughput = float64(len(samples)) / duration.Seconds()
+	}
+
+	latencies := make([]time.Duration, 0, len(samples))
+	for _, s := range samples {
+		if s.Failed {
+			stats.Failures++
+			continue
+		}
+		latencies = append(latencies, s.Latency)
+	}
+	if len(latencies) == 0 {
+		return stats
+	}
+
+	slices.Sort(latencies)
+	stats.P50 = percentile(latencies, 50)
+	stats.P90 = percentile(latencies, 90)
+	stats.P99 = percentile(latencies, 99)
+	stats.Max = latencies[len(latencies)-1]
+	return stats
+}
+
+// percentile finds the value below which the given percentage of the sorted values falls, using the nearest rank.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank-1, 0)]
+}
@@ -0,0 +1,74 @@
+package bench_test
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/nikitakuchur/testpoint/internal/bench"
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	var samples []bench.Sample
+	for i := 1; i <= 100; i++ {
+		samples = append(samples, bench.Sample{Group: "GET /a", Latency: time.Duration(i) * time.Millisecond})
+	}
+	samples = append(samples,
+		bench.Sample{Group: "GET /b", Latency: 500 * time.Millisecond},
+		bench.Sample{Group: "GET /b", Latency: time.Second, Failed: true},
+	)
+
+	actual := bench.Summarize("http://localhost:8080", samples, 2*time.Second)
+
+	expected := bench.Summary{
+		Target:   "http://localhost:8080",
+		Duration: 2 * time.Second,
+		Groups: map[string]bench.Stats{
+			bench.TotalGroup: {
+				Requests:   102,
+				Failures:   1,
+				Throughput: 51,
+				P50:        51 * time.Millisecond,
+				P90:        91 * time.Millisecond,
+				P99:        100 * time.Millisecond,
+				Max:        500 * time.Millisecond,
+			},
+			"GET /a": {
+				Requests:   100,
+				Throughput: 50,
+				P50:        50 * time.Millisecond,
+				P90:        90 * time.Millisecond,
+				P99:        99 * time.Millisecond,
+				Max:        100 * time.Millisecond,
+			},
+			"GET /b": {
+				Requests:   2,
+				Failures:   1,
+				Throughput: 1,
+				P50:        500 * time.Millisecond,
+				P90:        500 * time.Millisecond,
+				P99:        500 * time.Millisecond,
+				Max:        500 * time.Millisecond,
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("incorrect result (-expected +actual):\n%s", diff)
+	}
+	if names := actual.GroupNames(); !cmp.Equal(names, []string{bench.TotalGroup, "GET /a", "GET /b"}) {
+		t.Errorf("incorrect group names: %v", names)
+	}
+}
+
+func TestSummarizeWithOnlyFailures(t *testing.T) {
+	samples := []bench.Sample{{Group: "GET /a", Latency: time.Second, Failed: true}}
+
+	actual := bench.Summarize("http://localhost:8080", samples, time.Second).Groups[bench.TotalGroup]
+
+	expected := bench.Stats{Requests: 1, Failures: 1, Throughput: 1}
+	if actual != expected {
+		t.Errorf("incorrect result: expected %v, got %v", expected, actual)
+	}
+	if actual.ErrorRate() != 1 {
+		t.Errorf("incorrect error rate: expected 1, got %v", actual.ErrorRate())
+	}
+}
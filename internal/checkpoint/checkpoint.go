@@ -0,0 +1,140 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"github.com/nikitakuchur/testpoint/internal/io/readers/reqreader"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// Filename is the name of the checkpoint file in the output directory.
+const Filename = ".testpoint-checkpoint.json"
+
+// Checkpoint is the position in the input before which all the records have got their responses.
+// It's only valid for the same input and the same user URLs.
+type Checkpoint struct {
+	Input    string             `json:"input"`
+	Urls     []string           `json:"urls"`
+	Position reqreader.Position `json:"position"`
+}
+
+// Matches checks whether the checkpoint was made for the given input and user URLs.
+func (c Checkpoint) Matches(input string, urls []string) bool {
+	return c.Input == input && slices.Equal(c.Urls, urls)
+}
+
+// Load reads the checkpoint from the file.
+func Load(path string) (Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	var c Checkpoint
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Checkpoint{}, err
+	}
+	return c, nil
+}
+
+// Save writes the checkpoint to the file.
+// The file is replaced at once, so the checkpoint is never broken even if the process dies in the middle of saving it.
+func (c Checkpoint) Save(path string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Tracker moves the checkpoint forward as the records get their responses for all the user URLs.
+// The records can be completed in any order, so the checkpoint stays at the earliest record that isn't completed yet.
+// If a record never gets all of its responses, for example, because its transformation failed,
+// the checkpoint doesn't move past it, and the rest of the records are checked against the output files on resume.
+type Tracker struct {
+	mu         sync.Mutex
+	path       string
+	checkpoint Checkpoint
+	saved      bool
+	// pending are the records that were read, but not completed yet, in the order they were read
+	pending []record
+	// written is the number of user URLs that got the responses for each hash
+	written map[uint64]int
+}
+
+type record struct {
+	hash uint64
+	pos  reqreader.Position
+}
+
+// NewTracker creates a tracker that starts with the given checkpoint and saves it to the given path.
+// The completed requests are the ones that were written in the previous runs.
+func NewTracker(path string, checkpoint Checkpoint, completed map[string]map[uint64]bool) *Tracker {
+	written := make(map[uint64]int)
+	for _, hashes := range completed {
+		for hash := range hashes {
+			written[hash]++
+		}
+	}
+	return &Tracker{path: path, checkpoint: checkpoint, written: written}
+}
+
+// Read registers the record that has just been read along with the position right after it.
+func (t *Tracker) Read(rec reqreader.ReqRecord, pos reqreader.Position) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.pending = append(t.pending, record{rec.Hash, pos})
+}
+
+// Written registers the response that has been written.
+// The failed requests are sent once again on resume, so their responses don't complete the records.
+func (t *Tracker) Written(rr sender.RequestResponse) {
+	if rr.Response.Error != "" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.written[rr.Request.Hash]++
+}
+
+// Save moves the checkpoint past the completed records and saves it.
+// It must be called only after the written responses are flushed to the files.
+func (t *Tracker) Save() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	i := 0
+	for i < len(t.pending) && t.written[t.pending[i].hash] >= len(t.checkpoint.Urls) {
+		i++
+	}
+	if i == 0 && t.saved {
+		return nil
+	}
+	if i > 0 {
+		t.checkpoint.Position = t.pending[i-1].pos
+		t.pending = t.pending[i:]
+	}
+	if err := t.checkpoint.Save(t.path); err != nil {
+		return err
+	}
+	t.saved = true
+	return nil
+}
@@ -0,0 +1,81 @@
+package checkpoint_test
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/nikitakuchur/testpoint/internal/checkpoint"
+	"github.com/nikitakuchur/testpoint/internal/io/readers/reqreader"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"path/filepath"
+	"testing"
+)
+
+func TestTracker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), checkpoint.Filename)
+	urls := []string{"http://foo.com", "http://bar.com"}
+	start := checkpoint.Checkpoint{Input: "requests.csv", Urls: urls}
+
+	// the second record was completed in the previous run for one of the targets
+	tracker := checkpoint.NewTracker(path, start, map[string]map[uint64]bool{"http://foo.com": {2: true}})
+
+	positions := []reqreader.Position{
+		{File: "requests.csv", Offset: 10, Record: 1},
+		{File: "requests.csv", Offset: 20, Record: 2},
+		{File: "requests.csv", Offset: 30, Record: 3},
+	}
+	for i, pos := range positions {
+		tracker.Read(reqreader.ReqRecord{Hash: uint64(i + 1)}, pos)
+	}
+
+	written := func(userUrl string, hash uint64) {
+		tracker.Written(sender.RequestResponse{Request: sender.Request{UserUrl: userUrl, Hash: hash}})
+	}
+	failed := func(userUrl string, hash uint64) {
+		tracker.Written(sender.RequestResponse{
+			Request:  sender.Request{UserUrl: userUrl, Hash: hash},
+			Response: sender.Response{Error: sender.ErrorTimeout},
+		})
+	}
+	steps := []struct {
+		written  func()
+		expected reqreader.Position
+	}{
+		{func() {}, reqreader.Position{}},
+		// the records completed out of order don't move the checkpoint
+		{func() { written("http://bar.com", 2); written("http://foo.com", 3) }, reqreader.Position{}},
+		{func() { written("http://foo.com", 1) }, reqreader.Position{}},
+		{func() { written("http://bar.com", 1) }, positions[1]},
+		// the failed request is sent once again on resume, so the record isn't completed
+		{func() { failed("http://bar.com", 3) }, positions[1]},
+		{func() { written("http://bar.com", 3) }, positions[2]},
+	}
+
+	for i, step := range steps {
+		step.written()
+		if err := tracker.Save(); err != nil {
+			t.Fatal(err)
+		}
+
+		actual, err := checkpoint.Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := checkpoint.Checkpoint{Input: "requests.csv", Urls: urls, Position: step.expected}
+		if diff := cmp.Diff(expected, actual); diff != "" {
+			t.Errorf("step %v: %v", i, diff)
+		}
+	}
+}
+
+func TestCheckpointMatches(t *testing.T) {
+	c := checkpoint.Checkpoint{Input: "requests.csv", Urls: []string{"http://foo.com", "http://bar.com"}}
+
+	if !c.Matches("requests.csv", []string{"http://foo.com", "http://bar.com"}) {
+		t.Error("incorrect result: expected the checkpoint to match")
+	}
+	if c.Matches("requests.csv", []string{"http://foo.com"}) {
+		t.Error("incorrect result: expected the checkpoint not to match other URLs")
+	}
+	if c.Matches("other.csv", []string{"http://foo.com", "http://bar.com"}) {
+		t.Error("incorrect result: expected the checkpoint not to match another input")
+	}
+}
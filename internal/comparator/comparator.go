@@ -1,10 +1,13 @@
 package comparator
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/nikitakuchur/testpoint/internal/io/readers/respreader"
 	"github.com/nikitakuchur/testpoint/internal/sender"
 	"github.com/nikitakuchur/testpoint/internal/strdiff"
 	"log"
+	"strings"
 	"sync"
 )
 
@@ -20,9 +23,29 @@ type Comparator interface {
 	Compare(resp1, resp2 sender.Response) (map[string][]strdiff.Diff, error)
 }
 
+// Option configures the comparison of the responses.
+type Option func(c *compareConfig)
+
+type compareConfig struct {
+	onCompared func(rec1, rec2 respreader.RespRecord)
+}
+
+// WithCompared sets the function that is called for every pair of records once it has been compared,
+// whether it has any mismatches or not, e.g. to show the progress.
+func WithCompared(f func(rec1, rec2 respreader.RespRecord)) Option {
+	return func(c *compareConfig) {
+		c.onCompared = f
+	}
+}
+
 // CompareResponses compares responses from the given channels using the specified response comparator.
-func CompareResponses(records1, records2 <-chan respreader.RespRecord, numComparisons int, comparator Comparator, workers int) <-chan RespDiff {
-	responsesToCompare := matchResponses(records1, records2, numComparisons)
+func CompareResponses(records1, records2 <-chan respreader.RespRecord, numComparisons int, comparator Comparator, workers int, opts ...Option) <-chan RespDiff {
+	var conf compareConfig
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	responsesToCompare := MatchResponses(records1, records2, numComparisons)
 
 	output := make(chan RespDiff)
 
@@ -33,7 +56,13 @@ func CompareResponses(records1, records2 <-chan respreader.RespRecord, numCompar
 			defer wg.Done()
 
 			for responses := range responsesToCompare {
-				compareRecords(responses[0], responses[1], comparator, output)
+				diffs := CompareRecords(responses[0], responses[1], comparator)
+				if conf.onCompared != nil {
+					conf.onCompared(responses[0], responses[1])
+				}
+				if len(diffs) != 0 {
+					output <- RespDiff{responses[0], responses[1], diffs}
+				}
 			}
 		}()
 	}
@@ -46,13 +75,15 @@ func CompareResponses(records1, records2 <-chan respreader.RespRecord, numCompar
 	return output
 }
 
-func matchResponses(records1, records2 <-chan respreader.RespRecord, numComparisons int) <-chan []respreader.RespRecord {
+// MatchResponses finds the records with the same request hash in the given channels and sends them in pairs.
+// If the requests were repeated, the responses are paired by the number of the repeat.
+func MatchResponses(records1, records2 <-chan respreader.RespRecord, numComparisons int) <-chan []respreader.RespRecord {
 	matchedResponses := make(chan []respreader.RespRecord)
 
 	go func() {
 		defer close(matchedResponses)
 
-		buffer := make(map[uint64]respreader.RespRecord)
+		buffer := make(map[recordKey]respreader.RespRecord)
 
 		count := 0
 		isRecords1Closed, isRecords2Closed := false, false
@@ -73,13 +104,13 @@ func matchResponses(records1, records2 <-chan respreader.RespRecord, numComparis
 					isRecords1Closed = true
 					continue
 				}
-				rec2, ok := buffer[rec1.ReqHash]
+				rec2, ok := buffer[keyOf(rec1)]
 				if !ok {
 					// we don't have the second record yet, so we need to put this one aside
-					buffer[rec1.ReqHash] = rec1
+					buffer[keyOf(rec1)] = rec1
 					break
 				}
-				delete(buffer, rec1.ReqHash)
+				delete(buffer, keyOf(rec1))
 
 				// we have both records, let's send them to compare
 				matchedResponses <- []respreader.RespRecord{rec1, rec2}
@@ -89,12 +120,12 @@ func matchResponses(records1, records2 <-chan respreader.RespRecord, numComparis
 					isRecords2Closed = true
 					continue
 				}
-				rec1, ok := buffer[rec2.ReqHash]
+				rec1, ok := buffer[keyOf(rec2)]
 				if !ok {
-					buffer[rec2.ReqHash] = rec2
+					buffer[keyOf(rec2)] = rec2
 					break
 				}
-				delete(buffer, rec2.ReqHash)
+				delete(buffer, keyOf(rec2))
 
 				matchedResponses <- []respreader.RespRecord{rec1, rec2}
 				count++
@@ -109,24 +140,103 @@ func matchResponses(records1, records2 <-chan respreader.RespRecord, numComparis
 	return matchedResponses
 }
 
-func compareRecords(x, y respreader.RespRecord, comparator Comparator, output chan<- RespDiff) {
+// recordKey identifies the response to a request, the same request can be repeated several times.
+type recordKey struct {
+	hash   uint64
+	repeat int
+}
+
+func keyOf(rec respreader.RespRecord) recordKey {
+	return recordKey{rec.ReqHash, rec.ReqRepeat}
+}
+
+// CompareRecords compares two response records, it returns the differences per field, or nothing if they match.
+func CompareRecords(x, y respreader.RespRecord, comparator Comparator) map[string][]strdiff.Diff {
 	diffs := make(map[string][]strdiff.Diff)
-	defer func() {
-		if len(diffs) != 0 {
-			output <- RespDiff{x, y, diffs}
+
+	if x.RespError != "" || y.RespError != "" {
+		// there's nothing to compare if a request has failed, so we can only check if both of them failed the same way
+		if x.RespError != y.RespError {
+			diffs["error"] = strdiff.CalculateLineDiff(describeOutcome(x), describeOutcome(y))
 		}
-	}()
+		return diffs
+	}
 
-	resp1 := sender.Response{Status: x.RespStatus, Body: x.RespBody}
-	resp2 := sender.Response{Status: y.RespStatus, Body: y.RespBody}
+	// the bodies can be the same even if one target serves the content directly and the other one redirects to it
+	if x.RespRedirects != y.RespRedirects {
+		diffs["redirects"] = strdiff.CalculateLineDiff(describeRedirects(x.RespRedirects), describeRedirects(y.RespRedirects))
+	}
+
+	// the bodies that were truncated, stored in files or encoded can't be compared as is, so we compare their digests
+	resp1, resp2 := toResponse(x), toResponse(y)
+	if IsOpaqueBody(x) || IsOpaqueBody(y) {
+		resp1.Body, resp2.Body = "sha256:"+x.RespBodyDigest, "sha256:"+y.RespBodyDigest
+	}
 
 	respDiffs, err := comparator.Compare(resp1, resp2)
 	if err != nil {
 		log.Printf("%v, the records with hash=%v were skipped", x.ReqHash, err)
-		return
+		return diffs
 	}
 
 	for k, v := range respDiffs {
 		diffs[k] = v
 	}
+	return diffs
+}
+
+func toResponse(rec respreader.RespRecord) sender.Response {
+	return sender.Response{
+		Status:          rec.RespStatus,
+		Body:            rec.RespBody,
+		Attempts:        rec.RespAttempts,
+		Error:           rec.RespError,
+		ErrorMessage:    rec.RespErrorMessage,
+		BodyEncoding:    rec.RespBodyEncoding,
+		BodyTruncated:   rec.RespBodyTruncated,
+		BodyDigest:      rec.RespBodyDigest,
+		Headers:         rec.RespHeaders,
+		Proto:           rec.RespProto,
+		TimeToFirstByte: rec.RespTimeToFirstByte,
+		Duration:        rec.RespDuration,
+		Size:            rec.RespSize,
+		ContentEncoding: rec.RespContentEncoding,
+		TLSVersion:      rec.RespTLSVersion,
+		TLSCipher:       rec.RespTLSCipher,
+		Redirects:       rec.RespRedirects,
+		Skew:            rec.RespSkew,
+		Trailers:        rec.RespTrailers,
+	}
+}
+
+// IsOpaqueBody checks whether the record doesn't have the whole body as text, so it can only be compared by the digest.
+func IsOpaqueBody(rec respreader.RespRecord) bool {
+	return rec.RespBodyTruncated || rec.RespBodyFile != "" || rec.RespBodyEncoding != ""
+}
+
+// describeRedirects converts the redirect chain to a line per redirect, so the diff shows which hops have changed.
+func describeRedirects(redirects string) string {
+	if redirects == "" {
+		return "no redirects"
+	}
+	var chain []sender.Redirect
+	if err := json.Unmarshal([]byte(redirects), &chain); err != nil {
+		return redirects
+	}
+	lines := make([]string, 0, len(chain))
+	for _, r := range chain {
+		lines = append(lines, fmt.Sprintf("%v %v", r.Status, r.Location))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// describeOutcome describes how the request ended, so a failed request can be compared with a successful one.
+func describeOutcome(rec respreader.RespRecord) string {
+	if rec.RespError == "" {
+		return "status " + rec.RespStatus
+	}
+	if rec.RespErrorMessage == "" {
+		return rec.RespError
+	}
+	return rec.RespError + ": " + rec.RespErrorMessage
 }
@@ -61,6 +61,34 @@ func TestCompareResponses(t *testing.T) {
 	}
 }
 
+func TestCompareResponsesWithCompared(t *testing.T) {
+	records1 := make(chan respreader.RespRecord)
+	records2 := make(chan respreader.RespRecord)
+
+	go func() {
+		records1 <- respreader.RespRecord{ReqHash: 1, RespStatus: "200", RespBody: "foo"}
+		records1 <- respreader.RespRecord{ReqHash: 2, RespStatus: "200", RespBody: "bar"}
+		records1 <- respreader.RespRecord{ReqHash: 3, RespStatus: "200", RespBody: "baz"}
+		records2 <- respreader.RespRecord{ReqHash: 1, RespStatus: "200", RespBody: "foo"}
+		records2 <- respreader.RespRecord{ReqHash: 2, RespStatus: "200", RespBody: "qux"}
+		close(records1)
+		close(records2)
+	}()
+
+	var compared []uint64
+	diffs := comparator.CompareResponses(records1, records2, 0, comparator.NewDefaultComparator(false), 1,
+		comparator.WithCompared(func(rec1, rec2 respreader.RespRecord) {
+			compared = append(compared, rec1.ReqHash)
+		}),
+	)
+
+	// both the matching and the mismatching pairs are compared, the record without a pair isn't
+	actual := testutils.ChanToSlice(diffs)
+	if len(actual) != 1 || len(compared) != 2 || compared[0] != 1 || compared[1] != 2 {
+		t.Errorf("incorrect result: %v diffs, compared %v", len(actual), compared)
+	}
+}
+
 func TestCompareResponsesWithMissingRecords(t *testing.T) {
 	records1 := make(chan respreader.RespRecord)
 	records2 := make(chan respreader.RespRecord)
@@ -99,6 +127,158 @@ func TestCompareResponsesWithMissingRecords(t *testing.T) {
 	}
 }
 
+func TestCompareResponsesWithRepeats(t *testing.T) {
+	records1 := make(chan respreader.RespRecord)
+	records2 := make(chan respreader.RespRecord)
+
+	go func() {
+		records1 <- respreader.RespRecord{ReqHash: 1, RespStatus: "200", RespBody: "foo"}
+		records1 <- respreader.RespRecord{ReqHash: 1, ReqRepeat: 1, RespStatus: "200", RespBody: "bar"}
+		records2 <- respreader.RespRecord{ReqHash: 1, ReqRepeat: 1, RespStatus: "200", RespBody: "bar"}
+		records2 <- respreader.RespRecord{ReqHash: 1, RespStatus: "200", RespBody: "foo"}
+		close(records1)
+		close(records2)
+	}()
+
+	diffs := comparator.CompareResponses(records1, records2, 0, comparator.NewDefaultComparator(false), 1)
+
+	// the responses are paired by the number of the repeat, so they all match
+	if actual := testutils.ChanToSlice(diffs); len(actual) != 0 {
+		t.Errorf("incorrect result: expected no diffs, got %v", actual)
+	}
+}
+
+func TestCompareResponsesWithTimeouts(t *testing.T) {
+	records1 := make(chan respreader.RespRecord)
+	records2 := make(chan respreader.RespRecord)
+
+	go func() {
+		records1 <- respreader.RespRecord{ReqHash: 1, RespStatus: "200", RespBody: "foo"}
+		records2 <- respreader.RespRecord{ReqHash: 1, RespError: "timeout"}
+		records1 <- respreader.RespRecord{ReqHash: 2, RespError: "timeout"}
+		records2 <- respreader.RespRecord{ReqHash: 2, RespError: "timeout"}
+		close(records1)
+		close(records2)
+	}()
+
+	diffs := comparator.CompareResponses(records1, records2, 0, comparator.NewDefaultComparator(false), 1)
+
+	var actual = testutils.ChanToSlice(diffs)
+
+	expected := []comparator.RespDiff{
+		{
+			Rec1: respreader.RespRecord{ReqHash: 1, RespStatus: "200", RespBody: "foo"},
+			Rec2: respreader.RespRecord{ReqHash: 1, RespError: "timeout"},
+			Diffs: map[string][]strdiff.Diff{
+				"error": {
+					{Operation: strdiff.DiffDelete, Text: "status 200"},
+					{Operation: strdiff.DiffInsert, Text: "timeout"},
+				},
+			},
+		},
+	}
+
+	if diff := cmp.Diff(actual, expected); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestCompareResponsesWithFailedRequests(t *testing.T) {
+	records1 := make(chan respreader.RespRecord)
+	records2 := make(chan respreader.RespRecord)
+
+	go func() {
+		records1 <- respreader.RespRecord{ReqHash: 1, RespStatus: "200", RespBody: "foo"}
+		records2 <- respreader.RespRecord{ReqHash: 1, RespError: "connection", RespErrorMessage: "connection reset by peer"}
+		records1 <- respreader.RespRecord{ReqHash: 2, RespError: "tls", RespErrorMessage: "certificate signed by unknown authority"}
+		records2 <- respreader.RespRecord{ReqHash: 2, RespError: "connection", RespErrorMessage: "connection refused"}
+		records1 <- respreader.RespRecord{ReqHash: 3, RespError: "connection", RespErrorMessage: "dial tcp 127.0.0.1:8083"}
+		records2 <- respreader.RespRecord{ReqHash: 3, RespError: "connection", RespErrorMessage: "dial tcp 127.0.0.1:8084"}
+		close(records1)
+		close(records2)
+	}()
+
+	diffs := comparator.CompareResponses(records1, records2, 0, comparator.NewDefaultComparator(false), 1)
+
+	var actual = testutils.ChanToSlice(diffs)
+	if len(actual) != 2 {
+		t.Fatal("incorrect result: expected number of diffs is 2, got", len(actual))
+	}
+
+	expected := map[uint64]string{
+		1: "status 200\n->\nconnection: connection reset by peer",
+		2: "tls: certificate signed by unknown authority\n->\nconnection: connection refused",
+	}
+	for _, d := range actual {
+		var deleted, inserted string
+		for _, diff := range d.Diffs["error"] {
+			switch diff.Operation {
+			case strdiff.DiffDelete:
+				deleted += diff.Text
+			case strdiff.DiffInsert:
+				inserted += diff.Text
+			case strdiff.DiffEqual:
+				deleted += diff.Text
+				inserted += diff.Text
+			}
+		}
+		if e := expected[d.Rec1.ReqHash]; deleted+"\n->\n"+inserted != e {
+			t.Errorf("incorrect result: expected %q, got %q", e, deleted+"\n->\n"+inserted)
+		}
+	}
+}
+
+func TestCompareResponsesWithRedirects(t *testing.T) {
+	records1 := make(chan respreader.RespRecord)
+	records2 := make(chan respreader.RespRecord)
+
+	redirect := `[{"status":301,"location":"/api/new"}]`
+	go func() {
+		records1 <- respreader.RespRecord{ReqHash: 1, RespStatus: "200", RespBody: "foo", RespRedirects: redirect}
+		records2 <- respreader.RespRecord{ReqHash: 1, RespStatus: "200", RespBody: "foo"}
+		records1 <- respreader.RespRecord{ReqHash: 2, RespStatus: "200", RespBody: "bar", RespRedirects: redirect}
+		records2 <- respreader.RespRecord{ReqHash: 2, RespStatus: "200", RespBody: "bar", RespRedirects: redirect}
+		close(records1)
+		close(records2)
+	}()
+
+	diffs := comparator.CompareResponses(records1, records2, 0, comparator.NewDefaultComparator(false), 1)
+
+	var actual = testutils.ChanToSlice(diffs)
+	if len(actual) != 1 {
+		t.Fatal("incorrect result: expected number of diffs is 1, got", len(actual))
+	}
+	if actual[0].Rec1.ReqHash != 1 || len(actual[0].Diffs) != 1 || actual[0].Diffs["redirects"] == nil {
+		t.Errorf("incorrect result: expected a redirect diff for hash=1, got %v", actual[0].Diffs)
+	}
+}
+
+func TestCompareResponsesWithOpaqueBodies(t *testing.T) {
+	records1 := make(chan respreader.RespRecord)
+	records2 := make(chan respreader.RespRecord)
+
+	go func() {
+		// the same digests, but the bodies were truncated at different sizes
+		records1 <- respreader.RespRecord{ReqHash: 1, RespStatus: "200", RespBody: "foo", RespBodyTruncated: true, RespBodyDigest: "abc"}
+		records2 <- respreader.RespRecord{ReqHash: 1, RespStatus: "200", RespBody: "fo", RespBodyTruncated: true, RespBodyDigest: "abc"}
+		// different binary bodies stored in files
+		records1 <- respreader.RespRecord{ReqHash: 2, RespStatus: "200", RespBodyEncoding: "base64", RespBodyDigest: "abc", RespBodyFile: "bodies/abc"}
+		records2 <- respreader.RespRecord{ReqHash: 2, RespStatus: "200", RespBodyEncoding: "base64", RespBodyDigest: "def", RespBodyFile: "bodies/def"}
+		close(records1)
+		close(records2)
+	}()
+
+	diffs := comparator.CompareResponses(records1, records2, 0, comparator.NewDefaultComparator(false), 1)
+
+	var actual = testutils.ChanToSlice(diffs)
+	if len(actual) != 1 {
+		t.Fatal("incorrect result: expected number of diffs is 1, got", len(actual))
+	}
+	if actual[0].Rec1.ReqHash != 2 || actual[0].Diffs["body"] == nil {
+		t.Errorf("incorrect result: expected a body diff for hash=2, got %v", actual[0].Diffs)
+	}
+}
+
 type ErrorRespComparator struct {
 }
 
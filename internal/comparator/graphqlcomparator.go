@@ -0,0 +1,44 @@
+package comparator
+
+import (
+	"github.com/nikitakuchur/testpoint/internal/graphql"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"github.com/nikitakuchur/testpoint/internal/strdiff"
+	jsonutils "github.com/nikitakuchur/testpoint/internal/utils/json"
+)
+
+// GraphQLComparator compares the data and the errors of GraphQL responses separately,
+// so the report shows whether the data has changed, or only the errors have.
+// The errors are normalized before the comparison, see graphql.NormalizeErrors.
+// The responses that aren't GraphQL responses are compared the same way as by the default comparator.
+type GraphQLComparator struct {
+	ignoreOrder bool
+}
+
+func NewGraphQLComparator(ignoreOrder bool) GraphQLComparator {
+	return GraphQLComparator{ignoreOrder}
+}
+
+func (c GraphQLComparator) Compare(x, y sender.Response) (map[string][]strdiff.Diff, error) {
+	resp1, err1 := graphql.ParseResponse(x.Body)
+	resp2, err2 := graphql.ParseResponse(y.Body)
+	if x.Status != y.Status || err1 != nil || err2 != nil {
+		return NewDefaultComparator(c.ignoreOrder).Compare(x, y)
+	}
+
+	result := make(map[string][]strdiff.Diff)
+
+	data1 := jsonutils.ToJson(resp1.Data, c.ignoreOrder, []string{})
+	data2 := jsonutils.ToJson(resp2.Data, c.ignoreOrder, []string{})
+	if data1 != data2 {
+		result["data"] = strdiff.CalculateLineDiff(data1, data2)
+	}
+
+	errors1 := jsonutils.ToJson(graphql.NormalizeErrors(resp1.Errors), false, []string{})
+	errors2 := jsonutils.ToJson(graphql.NormalizeErrors(resp2.Errors), false, []string{})
+	if errors1 != errors2 {
+		result["errors"] = strdiff.CalculateLineDiff(errors1, errors2)
+	}
+
+	return result, nil
+}
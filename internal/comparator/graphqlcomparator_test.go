@@ -0,0 +1,87 @@
+package comparator_test
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/nikitakuchur/testpoint/internal/comparator"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"github.com/nikitakuchur/testpoint/internal/strdiff"
+	"testing"
+)
+
+func TestGraphQLComparatorWithDifferentData(t *testing.T) {
+	rec1 := sender.Response{
+		Status: "200",
+		Body:   `{"data":{"user":{"name":"foo"}},"extensions":{"tracing":{"duration":120}}}`,
+	}
+	rec2 := sender.Response{
+		Status: "200",
+		Body:   `{"data":{"user":{"name":"bar"}},"extensions":{"tracing":{"duration":95}}}`,
+	}
+	comp := comparator.NewGraphQLComparator(false)
+
+	actual, _ := comp.Compare(rec1, rec2)
+
+	expected := map[string][]strdiff.Diff{
+		"data": {
+			{Operation: strdiff.DiffEqual, Text: "{\n  \"user\": {\n"},
+			{Operation: strdiff.DiffDelete, Text: "    \"name\": \"foo\"\n"},
+			{Operation: strdiff.DiffInsert, Text: "    \"name\": \"bar\"\n"},
+			{Operation: strdiff.DiffEqual, Text: "  }\n}"},
+		},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestGraphQLComparatorWithEquivalentErrors(t *testing.T) {
+	rec1 := sender.Response{
+		Status: "200",
+		Body: `{"data":null,"errors":[` +
+			`{"message":"Not  found","path":["user"],"locations":[{"line":3,"column":5},{"line":1,"column":2}],"extensions":{"code":"NOT_FOUND","trace":"abc"}},` +
+			`{"message":"Forbidden","path":["account"]}]}`,
+	}
+	rec2 := sender.Response{
+		Status: "200",
+		Body: `{"data":null,"errors":[` +
+			`{"message":"Forbidden","path":["account"]},` +
+			`{"message":"Not found\n","path":["user"],"locations":[{"line":1,"column":2},{"line":3,"column":5}],"extensions":{"code":"NOT_FOUND","trace":"def"}}]}`,
+	}
+	comp := comparator.NewGraphQLComparator(false)
+
+	actual, _ := comp.Compare(rec1, rec2)
+
+	if len(actual) != 0 {
+		t.Errorf("incorrect result: expected no differences, got %v", actual)
+	}
+}
+
+func TestGraphQLComparatorWithDifferentErrors(t *testing.T) {
+	rec1 := sender.Response{
+		Status: "200",
+		Body:   `{"data":{"user":null},"errors":[{"message":"Not found","path":["user"],"extensions":{"code":"NOT_FOUND"}}]}`,
+	}
+	rec2 := sender.Response{
+		Status: "200",
+		Body:   `{"data":{"user":null},"errors":[{"message":"Not found","path":["user"],"extensions":{"code":"INTERNAL"}}]}`,
+	}
+	comp := comparator.NewGraphQLComparator(false)
+
+	actual, _ := comp.Compare(rec1, rec2)
+
+	if _, ok := actual["errors"]; !ok || len(actual) != 1 {
+		t.Errorf("incorrect result: expected only the errors to differ, got %v", actual)
+	}
+}
+
+func TestGraphQLComparatorWithNonGraphQLBody(t *testing.T) {
+	rec1 := sender.Response{Status: "502", Body: "Bad Gateway"}
+	rec2 := sender.Response{Status: "502", Body: "Bad gateway"}
+	comp := comparator.NewGraphQLComparator(false)
+
+	actual, _ := comp.Compare(rec1, rec2)
+
+	if _, ok := actual["body"]; !ok || len(actual) != 1 {
+		t.Errorf("incorrect result: expected the body to differ, got %v", actual)
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"github.com/nikitakuchur/testpoint/internal/strdiff"
 	jsonutils "github.com/nikitakuchur/testpoint/internal/utils/json"
 	"reflect"
+	"strings"
 	"sync"
+	"time"
 )
 
 type ScriptComparator struct {
@@ -47,7 +49,7 @@ func NewScriptComparator(script string, ignoreOrder bool) (ScriptComparator, err
 func (c *ScriptComparator) Compare(x, y sender.Response) (map[string][]strdiff.Diff, error) {
 	c.mu.Lock()
 	// goja is not thread safe, so we have to lock this piece of code
-	result, err := c.compare(goja.Undefined(), c.runtime.ToValue(x), c.runtime.ToValue(y))
+	result, err := c.compare(goja.Undefined(), c.runtime.ToValue(toJsResponse(x)), c.runtime.ToValue(toJsResponse(y)))
 	c.mu.Unlock()
 
 	if err != nil {
@@ -66,6 +68,23 @@ func (c *ScriptComparator) Compare(x, y sender.Response) (map[string][]strdiff.D
 	return diffs, nil
 }
 
+// toJsResponse converts the response into a map with the same keys as the fields of the response,
+// except that the first letters are lowercase, and the durations are converted to milliseconds.
+func toJsResponse(resp sender.Response) map[string]any {
+	result := make(map[string]any)
+	v := reflect.ValueOf(resp)
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		key := strings.ToLower(field.Name[:1]) + field.Name[1:]
+		if d, ok := v.Field(i).Interface().(time.Duration); ok {
+			result[key] = float64(d) / float64(time.Millisecond)
+			continue
+		}
+		result[key] = v.Field(i).Interface()
+	}
+	return result
+}
+
 func (c *ScriptComparator) extractComparisonDefinitions(v goja.Value) map[string]comparisonDefinition {
 	if v == nil || goja.IsNull(v) || goja.IsUndefined(v) {
 		return nil
@@ -6,6 +6,7 @@ import (
 	"github.com/nikitakuchur/testpoint/internal/sender"
 	"github.com/nikitakuchur/testpoint/internal/strdiff"
 	"testing"
+	"time"
 )
 
 func TestNewScriptComparatorWithStatus(t *testing.T) {
@@ -330,3 +331,38 @@ function compare(resp1, resp2) {
 		t.Errorf("incorrect result: expected an error")
 	}
 }
+
+func TestNewScriptComparatorWithMetadata(t *testing.T) {
+	comp, _ := comparator.NewScriptComparator(`
+function compare(resp1, resp2) {
+	return {
+		"contentType": {x: JSON.parse(resp1.headers)["Content-Type"], y: JSON.parse(resp2.headers)["Content-Type"]},
+		"slow": {x: resp1.duration > 100, y: resp2.duration > 100}
+	};
+}
+`, false)
+
+	rec1 := sender.Response{
+		Status:   "200",
+		Headers:  `{"Content-Type":"application/json"}`,
+		Duration: 20 * time.Millisecond,
+	}
+	rec2 := sender.Response{
+		Status:   "200",
+		Headers:  `{"Content-Type":"application/json"}`,
+		Duration: 150 * time.Millisecond,
+	}
+
+	actual, _ := comp.Compare(rec1, rec2)
+
+	expected := map[string][]strdiff.Diff{
+		"slow": {
+			{Operation: strdiff.DiffDelete, Text: "false"},
+			{Operation: strdiff.DiffInsert, Text: "true"},
+		},
+	}
+
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}
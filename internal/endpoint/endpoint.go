@@ -0,0 +1,41 @@
+package endpoint
+
+import (
+	"github.com/nikitakuchur/testpoint/internal/graphql"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// idPattern matches the path segments that are most likely IDs: numbers, UUIDs and long hex strings.
+var idPattern = regexp.MustCompile(`^(\d+|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9a-fA-F]{16,})$`)
+
+// Group returns the endpoint of the request: the method and the path where the IDs are replaced with ":id",
+// so the requests to the same endpoint end up in the same group. The query is ignored.
+// All the GraphQL requests usually go to the same URL, so their operation name is added to the group.
+func Group(req sender.Request) string {
+	path := req.Url
+	if u, err := url.Parse(req.Url); err == nil {
+		path = u.Path
+	}
+	// the path of the user URL is the same for all the requests, so it's not a part of the endpoint
+	if u, err := url.Parse(req.UserUrl); err == nil && u.Path != "" && u.Path != "/" {
+		path = strings.TrimPrefix(path, strings.TrimSuffix(u.Path, "/"))
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if idPattern.MatchString(segment) {
+			segments[i] = ":id"
+		}
+	}
+	group := req.Method + " " + strings.Join(segments, "/")
+	if operation := graphql.OperationName(req.Body); operation != "" {
+		group += " " + operation
+	}
+	return group
+}
@@ -0,0 +1,79 @@
+package endpoint_test
+
+import (
+	"github.com/nikitakuchur/testpoint/internal/endpoint"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"testing"
+)
+
+func TestGroup(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      sender.Request
+		expected string
+	}{
+		{
+			name:     "root",
+			req:      sender.Request{Method: "GET", Url: "http://localhost:8080", UserUrl: "http://localhost:8080"},
+			expected: "GET /",
+		},
+		{
+			name:     "query is ignored",
+			req:      sender.Request{Method: "GET", Url: "http://localhost:8080/api/users?page=2", UserUrl: "http://localhost:8080"},
+			expected: "GET /api/users",
+		},
+		{
+			name:     "numeric ID",
+			req:      sender.Request{Method: "GET", Url: "http://localhost:8080/api/users/42/orders", UserUrl: "http://localhost:8080"},
+			expected: "GET /api/users/:id/orders",
+		},
+		{
+			name:     "UUID",
+			req:      sender.Request{Method: "DELETE", Url: "http://localhost:8080/api/orders/123e4567-e89b-12d3-a456-426614174000", UserUrl: "http://localhost:8080"},
+			expected: "DELETE /api/orders/:id",
+		},
+		{
+			name:     "hex ID",
+			req:      sender.Request{Method: "GET", Url: "http://localhost:8080/api/objects/507f1f77bcf86cd799439011", UserUrl: "http://localhost:8080"},
+			expected: "GET /api/objects/:id",
+		},
+		{
+			name:     "short hex word is kept",
+			req:      sender.Request{Method: "GET", Url: "http://localhost:8080/api/feed", UserUrl: "http://localhost:8080"},
+			expected: "GET /api/feed",
+		},
+		{
+			name:     "user URL path is removed",
+			req:      sender.Request{Method: "POST", Url: "http://localhost:8080/v2/api/orders", UserUrl: "http://localhost:8080/v2/"},
+			expected: "POST /api/orders",
+		},
+		{
+			name: "GraphQL operation",
+			req: sender.Request{
+				Method:  "POST",
+				Url:     "http://localhost:8080/graphql",
+				Body:    `{"query":"query FindUser { user { name } }"}`,
+				UserUrl: "http://localhost:8080",
+			},
+			expected: "POST /graphql FindUser",
+		},
+		{
+			name: "anonymous GraphQL operation",
+			req: sender.Request{
+				Method:  "POST",
+				Url:     "http://localhost:8080/graphql",
+				Body:    `{"query":"{ user { name } }"}`,
+				UserUrl: "http://localhost:8080",
+			},
+			expected: "POST /graphql",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := endpoint.Group(tt.req)
+			if actual != tt.expected {
+				t.Errorf("incorrect result: expected %v, got %v", tt.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package filter
+
+import (
+	"context"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+)
+
+// SkipCompleted removes the requests that have already got their responses in a previous run.
+// The completed requests are identified by their user URL and hash.
+// It stops when the context is canceled.
+func SkipCompleted(ctx context.Context, input <-chan sender.Request, completed map[string]map[uint64]bool) <-chan sender.Request {
+	output := make(chan sender.Request)
+
+	go func() {
+		defer close(output)
+
+		for req := range input {
+			if completed[req.UserUrl][req.Hash] {
+				continue
+			}
+			select {
+			case output <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return output
+}
+
+// SkipCompletedGroups works the same way as SkipCompleted, but it removes the completed requests from each group.
+// The groups with no requests left are dropped.
+func SkipCompletedGroups(ctx context.Context, input <-chan []sender.Request, completed map[string]map[uint64]bool) <-chan []sender.Request {
+	output := make(chan []sender.Request)
+
+	go func() {
+		defer close(output)
+
+		for group := range input {
+			var rest []sender.Request
+			for _, req := range group {
+				if !completed[req.UserUrl][req.Hash] {
+					rest = append(rest, req)
+				}
+			}
+			if len(rest) == 0 {
+				continue
+			}
+			select {
+			case output <- rest:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return output
+}
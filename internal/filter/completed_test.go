@@ -0,0 +1,61 @@
+package filter_test
+
+import (
+	"context"
+	"github.com/google/go-cmp/cmp"
+	"github.com/nikitakuchur/testpoint/internal/filter"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	testutils "github.com/nikitakuchur/testpoint/internal/utils/testing"
+	"testing"
+)
+
+var completed = map[string]map[uint64]bool{
+	"http://foo.com": {1: true, 2: true},
+	"http://bar.com": {1: true},
+}
+
+func TestSkipCompleted(t *testing.T) {
+	requests := make(chan sender.Request)
+	go func() {
+		requests <- sender.Request{Url: "http://foo.com/api/1", UserUrl: "http://foo.com", Hash: 1}
+		requests <- sender.Request{Url: "http://bar.com/api/1", UserUrl: "http://bar.com", Hash: 1}
+		requests <- sender.Request{Url: "http://foo.com/api/2", UserUrl: "http://foo.com", Hash: 2}
+		requests <- sender.Request{Url: "http://bar.com/api/2", UserUrl: "http://bar.com", Hash: 2}
+		requests <- sender.Request{Url: "http://foo.com/api/3", UserUrl: "http://foo.com", Hash: 3}
+		close(requests)
+	}()
+
+	actual := testutils.ChanToSlice(filter.SkipCompleted(context.Background(), requests, completed))
+
+	expected := []sender.Request{
+		{Url: "http://bar.com/api/2", UserUrl: "http://bar.com", Hash: 2},
+		{Url: "http://foo.com/api/3", UserUrl: "http://foo.com", Hash: 3},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestSkipCompletedGroups(t *testing.T) {
+	groups := make(chan []sender.Request)
+	go func() {
+		groups <- []sender.Request{
+			{Url: "http://foo.com/api/1", UserUrl: "http://foo.com", Hash: 1},
+			{Url: "http://bar.com/api/1", UserUrl: "http://bar.com", Hash: 1},
+		}
+		groups <- []sender.Request{
+			{Url: "http://foo.com/api/2", UserUrl: "http://foo.com", Hash: 2},
+			{Url: "http://bar.com/api/2", UserUrl: "http://bar.com", Hash: 2},
+		}
+		close(groups)
+	}()
+
+	actual := testutils.ChanToSlice(filter.SkipCompletedGroups(context.Background(), groups, completed))
+
+	expected := [][]sender.Request{
+		{{Url: "http://bar.com/api/2", UserUrl: "http://bar.com", Hash: 2}},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}
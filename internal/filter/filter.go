@@ -1,11 +1,13 @@
 package filter
 
 import (
+	"context"
 	"github.com/nikitakuchur/testpoint/internal/io/readers/reqreader"
 )
 
 // Filter removes duplicates from the data stream.
-func Filter(input <-chan reqreader.ReqRecord) <-chan reqreader.ReqRecord {
+// It stops when the context is canceled.
+func Filter(ctx context.Context, input <-chan reqreader.ReqRecord) <-chan reqreader.ReqRecord {
 	output := make(chan reqreader.ReqRecord)
 
 	set := make(map[uint64]interface{})
@@ -18,7 +20,11 @@ func Filter(input <-chan reqreader.ReqRecord) <-chan reqreader.ReqRecord {
 			if ok {
 				continue
 			}
-			output <- rec
+			select {
+			case output <- rec:
+			case <-ctx.Done():
+				return
+			}
 			set[rec.Hash] = struct{}{}
 		}
 	}()
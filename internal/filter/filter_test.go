@@ -1,6 +1,7 @@
 package filter_test
 
 import (
+	"context"
 	"github.com/google/go-cmp/cmp"
 	"github.com/nikitakuchur/testpoint/internal/filter"
 	"github.com/nikitakuchur/testpoint/internal/io/readers/reqreader"
@@ -11,7 +12,7 @@ func TestFilterWithNoData(t *testing.T) {
 	records := make(chan reqreader.ReqRecord)
 	close(records)
 
-	filteredRecords := filter.Filter(records)
+	filteredRecords := filter.Filter(context.Background(), records)
 
 	var actual = chanToSlice(filteredRecords)
 	if len(actual) != 0 {
@@ -33,7 +34,7 @@ func TestFilter(t *testing.T) {
 		close(records)
 	}()
 
-	filteredRecords := filter.Filter(records)
+	filteredRecords := filter.Filter(context.Background(), records)
 
 	var actual = chanToSlice(filteredRecords)
 	if len(actual) != 5 {
@@ -0,0 +1,159 @@
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Body builds the body of a GraphQL request, the variables must be a JSON object or empty.
+func Body(query string, operationName string, variables string) (string, error) {
+	body := map[string]any{"query": query}
+	if operationName != "" {
+		body["operationName"] = operationName
+	}
+	if strings.TrimSpace(variables) != "" {
+		var v map[string]any
+		if err := json.Unmarshal([]byte(variables), &v); err != nil {
+			return "", fmt.Errorf("the GraphQL variables must be a JSON object: %w", err)
+		}
+		body["variables"] = v
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// operationPattern matches the definition of a named operation in a query.
+var operationPattern = regexp.MustCompile(`(?:^|[\s{}])(?:query|mutation|subscription)\s+([_A-Za-z][_0-9A-Za-z]*)`)
+
+// OperationName returns the name of the operation in the body of a GraphQL request.
+// If the body doesn't have the operationName field, the name of the first operation in the query is used.
+// It returns an empty string if the body isn't a GraphQL request, or the operation is anonymous.
+func OperationName(body string) string {
+	var req struct {
+		Query         *string `json:"query"`
+		OperationName string  `json:"operationName"`
+	}
+	if err := json.Unmarshal([]byte(body), &req); err != nil || req.Query == nil {
+		return ""
+	}
+	if req.OperationName != "" {
+		return req.OperationName
+	}
+	if m := operationPattern.FindStringSubmatch(*req.Query); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// Response is the body of a GraphQL response split into its parts.
+type Response struct {
+	Data   any
+	Errors []any
+}
+
+// ParseResponse splits the body of a GraphQL response into the data and the errors.
+// The extensions are left out, since they usually contain tracing and timings that differ from one request to another.
+func ParseResponse(body string) (Response, error) {
+	var resp map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		return Response{}, err
+	}
+	data, hasData := resp["data"]
+	errs, hasErrors := resp["errors"]
+	if !hasData && !hasErrors {
+		return Response{}, errors.New("the body has neither data nor errors")
+	}
+
+	var result Response
+	if hasData {
+		if err := json.Unmarshal(data, &result.Data); err != nil {
+			return Response{}, err
+		}
+	}
+	if hasErrors {
+		if err := json.Unmarshal(errs, &result.Errors); err != nil {
+			return Response{}, fmt.Errorf("the errors must be an array: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// whitespace matches the sequences of whitespace characters.
+var whitespace = regexp.MustCompile(`\s+`)
+
+// NormalizeErrors brings the errors to a form where only the meaningful differences are left.
+// The whitespace in the messages is collapsed, the locations are sorted, only the code is kept from the extensions,
+// and the errors are sorted by their path and message, since the order of the errors isn't guaranteed.
+func NormalizeErrors(errs []any) []any {
+	result := make([]any, 0, len(errs))
+	for _, e := range errs {
+		obj, ok := e.(map[string]any)
+		if !ok {
+			result = append(result, e)
+			continue
+		}
+
+		normalized := make(map[string]any, len(obj))
+		for k, v := range obj {
+			switch k {
+			case "message":
+				if s, ok := v.(string); ok {
+					v = strings.TrimSpace(whitespace.ReplaceAllString(s, " "))
+				}
+			case "locations":
+				v = sortLocations(v)
+			case "extensions":
+				ext, ok := v.(map[string]any)
+				if !ok || ext["code"] == nil {
+					continue
+				}
+				v = map[string]any{"code": ext["code"]}
+			}
+			normalized[k] = v
+		}
+		result = append(result, normalized)
+	}
+
+	slices.SortStableFunc(result, func(a, b any) int {
+		return strings.Compare(errorKey(a), errorKey(b))
+	})
+	return result
+}
+
+func sortLocations(v any) any {
+	locations, ok := v.([]any)
+	if !ok {
+		return v
+	}
+	sorted := slices.Clone(locations)
+	slices.SortStableFunc(sorted, func(a, b any) int {
+		return strings.Compare(locationKey(a), locationKey(b))
+	})
+	return sorted
+}
+
+func locationKey(v any) string {
+	loc, _ := v.(map[string]any)
+	// the numbers are padded, so they are sorted as numbers
+	line, _ := loc["line"].(float64)
+	column, _ := loc["column"].(float64)
+	return fmt.Sprintf("%010.0f:%010.0f", line, column)
+}
+
+func errorKey(v any) string {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	path, _ := json.Marshal(obj["path"])
+	message, _ := obj["message"].(string)
+	return string(path) + "\x00" + message
+}
@@ -0,0 +1,89 @@
+package graphql_test
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/nikitakuchur/testpoint/internal/graphql"
+	"testing"
+)
+
+func TestBody(t *testing.T) {
+	actual, err := graphql.Body("query Q { a }", "Q", `{"x": 1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"operationName":"Q","query":"query Q { a }","variables":{"x":1}}`
+	if actual != expected {
+		t.Errorf("incorrect result: expected %v, got %v", expected, actual)
+	}
+
+	if _, err := graphql.Body("{ a }", "", "not json"); err == nil {
+		t.Error("incorrect result: expected an error for invalid variables")
+	}
+}
+
+func TestOperationName(t *testing.T) {
+	tests := []struct {
+		body     string
+		expected string
+	}{
+		{`{"query":"query FindUser { user { name } }"}`, "FindUser"},
+		{`{"query":"mutation AddUser($name: String) { addUser(name: $name) { id } }"}`, "AddUser"},
+		{`{"query":"fragment F on User { name } query WithFragment { user { ...F } }"}`, "WithFragment"},
+		{`{"query":"query A { a } query B { b }","operationName":"B"}`, "B"},
+		{`{"query":"{ user { name } }"}`, ""},
+		{`{"name":"query Foo"}`, ""},
+		{`not json`, ""},
+	}
+	for _, tt := range tests {
+		if actual := graphql.OperationName(tt.body); actual != tt.expected {
+			t.Errorf("incorrect result for %v: expected %v, got %v", tt.body, tt.expected, actual)
+		}
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	actual, err := graphql.ParseResponse(`{"data":{"a":1},"errors":[{"message":"x"}],"extensions":{"cost":3}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := graphql.Response{
+		Data:   map[string]any{"a": 1.0},
+		Errors: []any{map[string]any{"message": "x"}},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+
+	for _, body := range []string{`{"result":1}`, `[1]`, `{"errors":{"message":"x"}}`} {
+		if _, err := graphql.ParseResponse(body); err == nil {
+			t.Errorf("incorrect result for %v: expected an error", body)
+		}
+	}
+}
+
+func TestNormalizeErrors(t *testing.T) {
+	errs := []any{
+		map[string]any{
+			"message":    "  Cannot query   field\n\"foo\" ",
+			"locations":  []any{map[string]any{"line": 12.0, "column": 1.0}, map[string]any{"line": 2.0, "column": 7.0}},
+			"extensions": map[string]any{"code": "GRAPHQL_VALIDATION_FAILED", "stacktrace": []any{"..."}},
+		},
+		map[string]any{"message": "Forbidden", "path": []any{"a"}, "extensions": map[string]any{"requestId": "123"}},
+		"unexpected",
+	}
+
+	actual := graphql.NormalizeErrors(errs)
+
+	expected := []any{
+		"unexpected",
+		map[string]any{"message": "Forbidden", "path": []any{"a"}},
+		map[string]any{
+			"message":    `Cannot query field "foo"`,
+			"locations":  []any{map[string]any{"line": 2.0, "column": 7.0}, map[string]any{"line": 12.0, "column": 1.0}},
+			"extensions": map[string]any{"code": "GRAPHQL_VALIDATION_FAILED"},
+		},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}
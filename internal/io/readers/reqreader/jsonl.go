@@ -0,0 +1,105 @@
+package reqreader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// readJsonRecords reads a file with a JSON object per line. The keys of the object are the fields of the record
+// in the same order, so they work the same way as the columns of a CSV file with a header.
+// The values that aren't strings, e.g. the headers as an object, are kept as compact JSON.
+func readJsonRecords(ctx context.Context, file *os.File, numRequests int, from Position, conf config, output chan<- ReqRecord) error {
+	if from.Offset > 0 {
+		if _, err := file.Seek(from.Offset, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	reader := bufio.NewReader(file)
+	offset := from.Offset
+
+	for count := from.Record; ; {
+		if numRequests > 0 && count >= numRequests {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		line, err := reader.ReadBytes('\n')
+		offset += int64(len(line))
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			if err == io.EOF {
+				return nil
+			}
+			continue
+		}
+
+		rec, parseErr := parseJsonRecord(line)
+		if parseErr != nil {
+			conf.logf("%v, the record was skipped", parseErr)
+		} else {
+			count++
+			rec.Hash = Hash(rec)
+			conf.onRead(rec, Position{file.Name(), offset, count})
+			select {
+			case output <- rec:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// parseJsonRecord converts a JSON object to a record, keeping the order of its keys.
+func parseJsonRecord(line []byte) (ReqRecord, error) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	if t, err := decoder.Token(); err != nil || t != json.Delim('{') {
+		return ReqRecord{}, errors.New("invalid JSON line: expected an object")
+	}
+
+	rec := ReqRecord{Fields: []string{}, Values: []string{}}
+	for decoder.More() {
+		t, err := decoder.Token()
+		if err != nil {
+			return ReqRecord{}, fmt.Errorf("invalid JSON line: %w", err)
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return ReqRecord{}, fmt.Errorf("invalid JSON line: %w", err)
+		}
+		rec.Fields = append(rec.Fields, t.(string))
+		rec.Values = append(rec.Values, jsonValue(value))
+	}
+	if _, err := decoder.Token(); err != nil {
+		return ReqRecord{}, fmt.Errorf("invalid JSON line: %w", err)
+	}
+	return rec, nil
+}
+
+func jsonValue(value json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return s
+	}
+	if string(value) == "null" {
+		return ""
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, value); err != nil {
+		return string(value)
+	}
+	return compact.String()
+}
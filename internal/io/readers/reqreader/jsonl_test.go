@@ -0,0 +1,80 @@
+package reqreader_test
+
+import (
+	"context"
+	"github.com/google/go-cmp/cmp"
+	"github.com/nikitakuchur/testpoint/internal/io/readers/reqreader"
+	testutils "github.com/nikitakuchur/testpoint/internal/utils/testing"
+	"testing"
+)
+
+func TestReadRequestsFromJsonLines(t *testing.T) {
+	tempDir := t.TempDir()
+	filename := testutils.CreateTempFile(tempDir, "requests*.jsonl", `
+{"url":"/api/test?prefix=te","method":"PUT","headers":{"myHeader":"test1"},"body":"{\"field\":\"test1\"}"}
+
+{"method":"GET","url":"/api/test?prefix=ca","body":null,"priority":1.50}
+not json
+[1, 2]
+{"url":"/api/test?prefix=do","headers":{ "a": [1, 2] }}`)
+
+	records := reqreader.ReadRequests(context.Background(), filename, true, 0)
+
+	actual := testutils.ChanToSlice(records)
+	expected := []reqreader.ReqRecord{
+		{
+			Fields: []string{"url", "method", "headers", "body"},
+			Values: []string{"/api/test?prefix=te", "PUT", `{"myHeader":"test1"}`, `{"field":"test1"}`},
+		},
+		{
+			Fields: []string{"method", "url", "body", "priority"},
+			Values: []string{"GET", "/api/test?prefix=ca", "", "1.50"},
+		},
+		{
+			Fields: []string{"url", "headers"},
+			Values: []string{"/api/test?prefix=do", `{"a":[1,2]}`},
+		},
+	}
+	for i := range expected {
+		expected[i].Hash = reqreader.Hash(expected[i])
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+
+	// the records have the same hashes as the same records in a CSV file
+	csvFilename := testutils.CreateTempFile(tempDir, "requests.csv", `url,method,headers,body
+/api/test?prefix=te,PUT,"{""myHeader"":""test1""}","{""field"":""test1""}"
+`)
+	csvRecords := testutils.ChanToSlice(reqreader.ReadRequests(context.Background(), csvFilename, true, 0))
+	if csvRecords[0].Hash != actual[0].Hash {
+		t.Errorf("incorrect result: expected the hash %v, got %v", csvRecords[0].Hash, actual[0].Hash)
+	}
+}
+
+func TestReadRequestsFromJsonLinesWithStart(t *testing.T) {
+	tempDir := t.TempDir()
+	filename := testutils.CreateTempFile(tempDir, "requests*.ndjson", `{"url":"/api/test?prefix=te"}
+{"url":"/api/test?prefix=ca"}
+{"url":"/api/test?prefix=do"}
+{"url":"/api/test?prefix=sp"}
+`)
+
+	var positions []reqreader.Position
+	onRead := func(_ reqreader.ReqRecord, pos reqreader.Position) {
+		positions = append(positions, pos)
+	}
+	all := testutils.ChanToSlice(reqreader.ReadRequests(context.Background(), filename, false, 0, reqreader.WithOnRead(onRead)))
+	if len(positions) != 4 {
+		t.Fatal("incorrect result: expected number of positions is 4, got", len(positions))
+	}
+	if positions[1] != (reqreader.Position{File: filename, Offset: 60, Record: 2}) {
+		t.Error("incorrect result: unexpected position of the second record", positions[1])
+	}
+
+	records := reqreader.ReadRequests(context.Background(), filename, false, 3, reqreader.WithStart(positions[1]))
+	actual := testutils.ChanToSlice(records)
+	if diff := cmp.Diff(all[2:3], actual); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -1,6 +1,7 @@
 package reqreader
 
 import (
+	"context"
 	"encoding/csv"
 	"fmt"
 	"hash/fnv"
@@ -8,6 +9,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 )
 
@@ -32,8 +34,47 @@ func (rec ReqRecord) String() string {
 	return strings.Join(rec.Values, ", ")
 }
 
+// Position is the place in the input right after a record, where the reading can be resumed.
+type Position struct {
+	File   string `json:"file"`
+	Offset int64  `json:"offset"`
+	// Record is the number of records read from the file, so the --num-requests limit still works after resuming.
+	Record int `json:"record"`
+}
+
+type config struct {
+	start  Position
+	onRead func(rec ReqRecord, pos Position)
+	logf   func(format string, v ...any)
+}
+
+// Option configures how the requests are read.
+type Option func(c *config)
+
+// WithStart makes the reading start from the given position instead of the beginning of the input.
+// The records before the position are not read at all.
+func WithStart(pos Position) Option {
+	return func(c *config) {
+		c.start = pos
+	}
+}
+
+// WithOnRead sets the function that is called with each record and its position before the record is sent further.
+func WithOnRead(onRead func(rec ReqRecord, pos Position)) Option {
+	return func(c *config) {
+		c.onRead = onRead
+	}
+}
+
 // ReadRequests reads the CSV files with requests and sends the data to the output channel.
-func ReadRequests(path string, withHeader bool, numRequests int) <-chan ReqRecord {
+// The files with the .jsonl or .ndjson extension are read as JSON Lines, see readJsonRecords.
+// It stops reading when the context is canceled.
+func ReadRequests(ctx context.Context, path string, withHeader bool, numRequests int, opts ...Option) <-chan ReqRecord {
+	conf := config{onRead: func(ReqRecord, Position) {}, logf: log.Printf}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
 	output := make(chan ReqRecord)
 
 	go func() {
@@ -41,14 +82,33 @@ func ReadRequests(path string, withHeader bool, numRequests int) <-chan ReqRecor
 
 		filenames, err := readFilenames(path)
 		if err != nil {
-			log.Printf("%v: %v, request reading was skipped", path, err)
+			conf.logf("%v: %v, request reading was skipped", path, err)
 			return
 		}
 
+		start := conf.start
+		if start.File != "" {
+			i := slices.Index(filenames, start.File)
+			if i == -1 {
+				conf.logf("%v: the file is not found in the input, the reading starts from the beginning", start.File)
+				start = Position{}
+			} else {
+				filenames = filenames[i:]
+			}
+		}
+
 		for _, filename := range filenames {
-			err := readFile(filename, withHeader, numRequests, output)
+			var from Position
+			if filename == start.File {
+				from = start
+			}
+			err := readFile(ctx, filename, withHeader, numRequests, from, conf, output)
+			if ctx.Err() != nil {
+				conf.logf("request reading was stopped")
+				return
+			}
 			if err != nil {
-				log.Printf("%v: %v, the file was skipped", filename, err)
+				conf.logf("%v: %v, the file was skipped", filename, err)
 			}
 		}
 	}()
@@ -56,7 +116,7 @@ func ReadRequests(path string, withHeader bool, numRequests int) <-chan ReqRecor
 	return output
 }
 
-func readFile(filename string, withHeader bool, numRequests int, output chan<- ReqRecord) error {
+func readFile(ctx context.Context, filename string, withHeader bool, numRequests int, from Position, conf config, output chan<- ReqRecord) error {
 	file, err := os.Open(filename)
 	defer file.Close()
 
@@ -64,7 +124,11 @@ func readFile(filename string, withHeader bool, numRequests int, output chan<- R
 		return err
 	}
 
-	err = readRecords(file, withHeader, numRequests, output)
+	if IsJsonLines(filename) {
+		return readJsonRecords(ctx, file, numRequests, from, conf, output)
+	}
+
+	err = readRecords(ctx, file, withHeader, numRequests, from, conf, output)
 	if err != nil {
 		return err
 	}
@@ -72,7 +136,13 @@ func readFile(filename string, withHeader bool, numRequests int, output chan<- R
 	return nil
 }
 
-func readRecords(file *os.File, withHeader bool, numRequests int, output chan<- ReqRecord) error {
+// IsJsonLines checks whether the file has a JSON object per line instead of CSV records, judging by its extension.
+func IsJsonLines(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext == ".jsonl" || ext == ".ndjson"
+}
+
+func readRecords(ctx context.Context, file *os.File, withHeader bool, numRequests int, from Position, conf config, output chan<- ReqRecord) error {
 	reader := csv.NewReader(file)
 
 	var header []string = nil
@@ -84,27 +154,62 @@ func readRecords(file *os.File, withHeader bool, numRequests int, output chan<-
 		header = h
 	}
 
-	for count := 0; ; count++ {
+	// the header is always read from the beginning, and then we jump right to the first record we need
+	var base int64
+	if from.Offset > 0 {
+		if _, err := file.Seek(from.Offset, io.SeekStart); err != nil {
+			return err
+		}
+		base = from.Offset
+		reader = csv.NewReader(file)
+		if header != nil {
+			reader.FieldsPerRecord = len(header)
+		}
+	}
+
+	for count := from.Record; ; count++ {
 		if numRequests > 0 && count >= numRequests {
 			return nil
 		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 
 		values, err := reader.Read()
 		if err == io.EOF {
 			return nil
 		}
 		if err != nil {
-			log.Printf("%v, the record was skipped", err)
+			conf.logf("%v, the record was skipped", err)
 			continue
 		}
 
 		rec := ReqRecord{Fields: header, Values: values}
-		rec.Hash = hash(rec)
-		output <- rec
+		rec.Hash = Hash(rec)
+		conf.onRead(rec, Position{file.Name(), base + reader.InputOffset(), count + 1})
+		select {
+		case output <- rec:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// CountRequests counts the records the same way ReadRequests reads them, but without logging the problems,
+// since they are logged when the records are actually read.
+func CountRequests(ctx context.Context, path string, withHeader bool, numRequests int, opts ...Option) int {
+	quiet := func(c *config) {
+		c.logf = func(string, ...any) {}
+	}
+	count := 0
+	for range ReadRequests(ctx, path, withHeader, numRequests, append(opts, quiet)...) {
+		count++
 	}
+	return count
 }
 
-func hash(rec ReqRecord) uint64 {
+// Hash identifies the record, the same records read from the input always get the same hash.
+func Hash(rec ReqRecord) uint64 {
 	h := fnv.New64()
 	h.Write([]byte(rec.String()))
 	return h.Sum64()
@@ -1,6 +1,7 @@
 package reqreader_test
 
 import (
+	"context"
 	"github.com/google/go-cmp/cmp"
 	"github.com/nikitakuchur/testpoint/internal/io/readers/reqreader"
 	testutils "github.com/nikitakuchur/testpoint/internal/utils/testing"
@@ -17,7 +18,7 @@ url,method,headers,body
 /api/test?prefix=sp,HEAD,"{""myHeader"":""test4""}","{""field"":""test4""}"
 `)
 
-	records := reqreader.ReadRequests(filename, true, 0)
+	records := reqreader.ReadRequests(context.Background(), filename, true, 0)
 
 	actual := testutils.ChanToSlice(records)
 	if len(actual) != 4 {
@@ -61,7 +62,7 @@ func TestReadRequestsWithoutHeader(t *testing.T) {
 /api/test?prefix=sp,HEAD,"{""myHeader"":""test4""}","{""field"":""test4""}"
 `)
 
-	records := reqreader.ReadRequests(filename, false, 0)
+	records := reqreader.ReadRequests(context.Background(), filename, false, 0)
 
 	actual := testutils.ChanToSlice(records)
 	if len(actual) != 4 {
@@ -93,7 +94,7 @@ func TestReadRequestsWithoutHeader(t *testing.T) {
 }
 
 func TestReadRequestsWithEmptyPath(t *testing.T) {
-	records := reqreader.ReadRequests("", true, 0)
+	records := reqreader.ReadRequests(context.Background(), "", true, 0)
 	actual := testutils.ChanToSlice(records)
 	if len(actual) != 0 {
 		t.Error("incorrect result: expected number of records is 0, got", len(actual))
@@ -104,7 +105,7 @@ func TestReadRequestsWithEmptyFile(t *testing.T) {
 	tempDir := t.TempDir()
 	filename := testutils.CreateTempFile(tempDir, "requests.csv", ``)
 
-	records := reqreader.ReadRequests(filename, true, 0)
+	records := reqreader.ReadRequests(context.Background(), filename, true, 0)
 
 	actual := testutils.ChanToSlice(records)
 	if len(actual) != 0 {
@@ -122,7 +123,7 @@ url,method,headers,body
 /api/test?prefix=sp,HEAD,"{""myHeader"":""test4""}","{""field"":""test4""}""
 `)
 
-	records := reqreader.ReadRequests(filename, true, 0)
+	records := reqreader.ReadRequests(context.Background(), filename, true, 0)
 
 	actual := testutils.ChanToSlice(records)
 	if len(actual) != 2 {
@@ -147,6 +148,77 @@ url,method,headers,body
 	}
 }
 
+func TestReadRequestsWithCanceledContext(t *testing.T) {
+	tempDir := t.TempDir()
+	filename := testutils.CreateTempFile(tempDir, "requests.csv", `
+/api/test?prefix=te
+/api/test?prefix=ca
+`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	records := reqreader.ReadRequests(ctx, filename, false, 0)
+
+	actual := testutils.ChanToSlice(records)
+	if len(actual) != 0 {
+		t.Error("incorrect result: expected number of records is 0, got", len(actual))
+	}
+}
+
+func TestReadRequestsWithStart(t *testing.T) {
+	tempDir := t.TempDir()
+	filename := testutils.CreateTempFile(tempDir, "requests.csv", `
+url,method
+/api/test?prefix=te,PUT
+/api/test?prefix=ca,GET
+/api/test?prefix=do,DELETE
+/api/test?prefix=sp,HEAD
+`)
+
+	var positions []reqreader.Position
+	onRead := func(_ reqreader.ReqRecord, pos reqreader.Position) {
+		positions = append(positions, pos)
+	}
+	all := testutils.ChanToSlice(reqreader.ReadRequests(context.Background(), filename, true, 0, reqreader.WithOnRead(onRead)))
+	if len(positions) != 4 {
+		t.Fatal("incorrect result: expected number of positions is 4, got", len(positions))
+	}
+	if positions[1] != (reqreader.Position{File: filename, Offset: 60, Record: 2}) {
+		t.Error("incorrect result: unexpected position of the second record", positions[1])
+	}
+
+	records := reqreader.ReadRequests(context.Background(), filename, true, 0, reqreader.WithStart(positions[1]))
+	actual := testutils.ChanToSlice(records)
+	if diff := cmp.Diff(all[2:], actual); diff != "" {
+		t.Error(diff)
+	}
+
+	// the records read before the start count towards the limit
+	records = reqreader.ReadRequests(context.Background(), filename, true, 3, reqreader.WithStart(positions[1]))
+	actual = testutils.ChanToSlice(records)
+	if diff := cmp.Diff(all[2:3], actual); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestCountRequests(t *testing.T) {
+	tempDir := t.TempDir()
+	filename := testutils.CreateTempFile(tempDir, "requests.csv", `
+url,method
+/api/test?prefix=te,PUT
+/api/test?prefix=ca,GET,"broken"
+/api/test?prefix=do,DELETE
+`)
+
+	if actual := reqreader.CountRequests(context.Background(), filename, true, 0); actual != 2 {
+		t.Error("incorrect result: expected number of records is 2, got", actual)
+	}
+	if actual := reqreader.CountRequests(context.Background(), filename, true, 1); actual != 1 {
+		t.Error("incorrect result: expected number of records is 1, got", actual)
+	}
+}
+
 func TestReadRequestsFromDir(t *testing.T) {
 	tempDir := t.TempDir()
 	testutils.CreateTempFile(tempDir, "requests-1.csv", `
@@ -164,7 +236,7 @@ url,method,headers,body
 /api/test2?prefix=st,HEAD,"{""myHeader"":""test8""}","{""field"":""test8""}"
 `)
 
-	records := reqreader.ReadRequests(tempDir, true, 0)
+	records := reqreader.ReadRequests(context.Background(), tempDir, true, 0)
 
 	actual := testutils.ChanToSlice(records)
 	if len(actual) != 8 {
@@ -223,7 +295,7 @@ url,method,headers,body
 func TestReadRequestsFromEmptyDir(t *testing.T) {
 	tempDir := t.TempDir()
 
-	records := reqreader.ReadRequests(tempDir, true, 0)
+	records := reqreader.ReadRequests(context.Background(), tempDir, true, 0)
 
 	actual := testutils.ChanToSlice(records)
 	if len(actual) != 0 {
@@ -232,7 +304,7 @@ func TestReadRequestsFromEmptyDir(t *testing.T) {
 }
 
 func TestReadRequestsFromNonexistentDir(t *testing.T) {
-	records := reqreader.ReadRequests("/this/directory/does/not/exist/", true, 0)
+	records := reqreader.ReadRequests(context.Background(), "/this/directory/does/not/exist/", true, 0)
 
 	actual := testutils.ChanToSlice(records)
 	if len(actual) != 0 {
@@ -2,11 +2,14 @@ package respreader
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // RespRecord represents a response record from an input CSV file generated by the send command.
@@ -16,18 +19,54 @@ type RespRecord struct {
 	ReqHeaders string
 	ReqBody    string
 	ReqHash    uint64
+	// ReqRepeat is the number of the repeat if the request was sent more than once.
+	ReqRepeat int
 
-	RespStatus string
-	RespBody   string
+	RespStatus       string
+	RespBody         string
+	RespAttempts     int
+	RespError        string
+	RespErrorMessage string
+
+	RespBodyEncoding  string
+	RespBodyTruncated bool
+	RespBodyDigest    string
+	RespBodyFile      string
+
+	RespHeaders         string
+	RespProto           string
+	RespTimeToFirstByte time.Duration
+	RespDuration        time.Duration
+	RespSize            int
+	RespContentEncoding string
+	RespTLSVersion      string
+	RespTLSCipher       string
+	RespRedirects       string
+	RespSkew            time.Duration
+	RespTrailers        string
 }
 
 func (r RespRecord) String() string {
 	return fmt.Sprintf(
-		"reqUrl: %v, reqMethod: %v, reqHeaders: %v, reqBody: %v, reqHash: %v, respStatus: %v, respBody: %v",
-		r.ReqUrl, r.ReqMethod, r.ReqHeaders, r.ReqBody, r.ReqHash, r.RespStatus, r.RespBody,
+		"reqUrl: %v, reqMethod: %v, reqHeaders: %v, reqBody: %v, reqHash: %v, reqRepeat: %v, "+
+			"respStatus: %v, respBody: %v, respAttempts: %v, respError: %v, respErrorMessage: %v, "+
+			"respBodyEncoding: %v, respBodyTruncated: %v, respBodyDigest: %v, respBodyFile: %v, "+
+			"respHeaders: %v, respProto: %v, respTimeToFirstByte: %v, respDuration: %v, respSize: %v, respContentEncoding: %v, "+
+			"respTLSVersion: %v, respTLSCipher: %v, respRedirects: %v, respSkew: %v, respTrailers: %v",
+		r.ReqUrl, r.ReqMethod, r.ReqHeaders, r.ReqBody, r.ReqHash, r.ReqRepeat,
+		r.RespStatus, r.RespBody, r.RespAttempts, r.RespError, r.RespErrorMessage,
+		r.RespBodyEncoding, r.RespBodyTruncated, r.RespBodyDigest, r.RespBodyFile,
+		r.RespHeaders, r.RespProto, r.RespTimeToFirstByte, r.RespDuration, r.RespSize, r.RespContentEncoding,
+		r.RespTLSVersion, r.RespTLSCipher, r.RespRedirects, r.RespSkew, r.RespTrailers,
 	)
 }
 
+// requiredColumns are the columns that every response file has, including the ones generated by older versions.
+var requiredColumns = []string{
+	"req_url", "req_method", "req_headers", "req_body", "req_hash",
+	"resp_status", "resp_body",
+}
+
 // ReadResponses reads the CSV file with responses and sends the data to the output channel.
 func ReadResponses(filename string) <-chan RespRecord {
 	output := make(chan RespRecord)
@@ -50,10 +89,33 @@ func ReadResponses(filename string) <-chan RespRecord {
 	return output
 }
 
+// CountResponses counts the records in the CSV file with responses, so the progress can be estimated.
+// The broken records are not counted, and if the file cannot be read, it returns zero.
+func CountResponses(filename string) int {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	count := -1 // the header is not a response
+	for {
+		_, err := reader.Read()
+		var parseErr *csv.ParseError
+		if err != nil && !errors.As(err, &parseErr) {
+			return max(count, 0)
+		}
+		if err == nil {
+			count++
+		}
+	}
+}
+
 func readRecords(file *os.File, output chan<- RespRecord) error {
 	reader := csv.NewReader(file)
 
-	// we need to skip the CSV header
+	// the header tells us where each column is, since newer versions of the send command write more of them
 	header, err := reader.Read()
 	if err == io.EOF {
 		return nil
@@ -61,8 +123,9 @@ func readRecords(file *os.File, output chan<- RespRecord) error {
 	if err != nil {
 		log.Fatalf("%v: %v", file.Name(), err)
 	}
-	if len(header) < 7 {
-		log.Fatalf("%v: there are missing values", file.Name())
+	columns, err := columnIndexes(header)
+	if err != nil {
+		log.Fatalf("%v: %v", file.Name(), err)
 	}
 
 	for {
@@ -75,16 +138,86 @@ func readRecords(file *os.File, output chan<- RespRecord) error {
 			continue
 		}
 
-		hash, err := strconv.ParseUint(values[4], 10, 64)
+		get := func(name string) string {
+			i, ok := columns[name]
+			if !ok {
+				return ""
+			}
+			return values[i]
+		}
+
+		hash, err := strconv.ParseUint(get("req_hash"), 10, 64)
 		if err != nil {
-			log.Printf("%v: cannot parse the hash value '%v', the record was skipped", file.Name(), values[4])
+			log.Printf("%v: cannot parse the hash value '%v', the record was skipped", file.Name(), get("req_hash"))
 			continue
 		}
 
 		rec := RespRecord{
-			values[0], values[1], values[2], values[3], hash,
-			values[5], values[6],
+			get("req_url"), get("req_method"), get("req_headers"), get("req_body"), hash, parseInt(get("req_repeat")),
+			get("resp_status"), get("resp_body"), parseInt(get("resp_attempts")),
+			get("resp_error"), get("resp_error_message"),
+			get("resp_body_encoding"), parseBool(get("resp_body_truncated")), get("resp_body_digest"), get("resp_body_file"),
+			get("resp_headers"), get("resp_proto"), parseDuration(get("resp_ttfb")), parseDuration(get("resp_duration")),
+			parseInt(get("resp_size")), get("resp_content_encoding"),
+			get("resp_tls_version"), get("resp_tls_cipher"), get("resp_redirects"),
+			parseDuration(get("resp_skew")), get("resp_trailers"),
 		}
 		output <- rec
 	}
 }
+
+// columnIndexes finds where each column is by its name in the header.
+// The files with seven columns could be written by the older versions with a different header,
+// so if the names don't match, the columns are taken by their position, the same way those versions read them.
+func columnIndexes(header []string) (map[string]int, error) {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+
+	var missing []string
+	for _, name := range requiredColumns {
+		if _, ok := columns[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return columns, nil
+	}
+
+	if len(header) == len(requiredColumns) {
+		columns = make(map[string]int, len(requiredColumns))
+		for i, name := range requiredColumns {
+			columns[name] = i
+		}
+		return columns, nil
+	}
+	return nil, fmt.Errorf("the required columns are missing: %v", strings.Join(missing, ", "))
+}
+
+// parseInt parses an optional numeric value, the missing or incorrect values are treated as zero.
+func parseInt(s string) int {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// parseBool parses an optional flag, the missing or incorrect values are treated as false.
+func parseBool(s string) bool {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return false
+	}
+	return v
+}
+
+// parseDuration parses an optional duration, the missing or incorrect values are treated as zero.
+func parseDuration(s string) time.Duration {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return 0
+	}
+	return v
+}
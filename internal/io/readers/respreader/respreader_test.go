@@ -4,9 +4,12 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/nikitakuchur/testpoint/internal/io/readers/respreader"
 	testutils "github.com/nikitakuchur/testpoint/internal/utils/testing"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
+// TestReadResponses checks that the files with seven columns generated by the older versions are still supported.
 func TestReadResponses(t *testing.T) {
 	tempDir := t.TempDir()
 	filename := testutils.CreateTempFile(tempDir, "responses.csv", `
@@ -68,6 +71,86 @@ http://localhost:8080/api/test?prefix=sp,HEAD,"{""myHeader"":""test4""}","{""fie
 	}
 }
 
+func TestReadResponsesWithAdditionalColumns(t *testing.T) {
+	tempDir := t.TempDir()
+	filename := testutils.CreateTempFile(tempDir, "responses.csv", `
+req_url,req_method,req_headers,req_body,req_hash,resp_status,resp_body,resp_attempts,resp_error,resp_error_message,resp_body_encoding,resp_body_truncated,resp_body_digest,resp_body_file,resp_headers,resp_proto,resp_ttfb,resp_duration,resp_size,resp_content_encoding,resp_tls_version,resp_tls_cipher,resp_redirects,resp_skew
+http://localhost:8080/api/test?prefix=te,PUT,"{""myHeader"":""test1""}","{""field"":""test1""}",123,"200","[1,2,3]",3,,,base64,true,abc123,bodies/abc123,"{""Content-Type"":""application/json""}",HTTP/2.0,1.5ms,2ms,7,gzip,TLS 1.3,TLS_AES_128_GCM_SHA256,"[{""status"":301,""location"":""/api/test""}]",3ms
+http://localhost:8080/api/test?prefix=ca,GET,,,234,,,5,timeout,context deadline exceeded,,false,,,,,0s,0s,0,,,,,
+`)
+
+	records := respreader.ReadResponses(filename)
+
+	actual := testutils.ChanToSlice(records)
+
+	expected := []respreader.RespRecord{
+		{
+			ReqUrl:       "http://localhost:8080/api/test?prefix=te",
+			ReqMethod:    "PUT",
+			ReqHeaders:   `{"myHeader":"test1"}`,
+			ReqBody:      `{"field":"test1"}`,
+			ReqHash:      123,
+			RespStatus:   "200",
+			RespBody:     "[1,2,3]",
+			RespAttempts: 3,
+
+			RespBodyEncoding:  "base64",
+			RespBodyTruncated: true,
+			RespBodyDigest:    "abc123",
+			RespBodyFile:      "bodies/abc123",
+
+			RespHeaders:         `{"Content-Type":"application/json"}`,
+			RespProto:           "HTTP/2.0",
+			RespTimeToFirstByte: 1500 * time.Microsecond,
+			RespDuration:        2 * time.Millisecond,
+			RespSize:            7,
+			RespContentEncoding: "gzip",
+			RespTLSVersion:      "TLS 1.3",
+			RespTLSCipher:       "TLS_AES_128_GCM_SHA256",
+			RespRedirects:       `[{"status":301,"location":"/api/test"}]`,
+			RespSkew:            3 * time.Millisecond,
+		},
+		{
+			ReqUrl:           "http://localhost:8080/api/test?prefix=ca",
+			ReqMethod:        "GET",
+			ReqHash:          234,
+			RespAttempts:     5,
+			RespError:        "timeout",
+			RespErrorMessage: "context deadline exceeded",
+		},
+	}
+
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}
+
+// TestReadResponsesWithOtherHeader checks that the files with seven columns are read by the position if the header is different.
+func TestReadResponsesWithOtherHeader(t *testing.T) {
+	tempDir := t.TempDir()
+	filename := testutils.CreateTempFile(tempDir, "responses.csv", `
+url,method,headers,body,hash,status,response
+http://localhost:8080/api/test,GET,"{""myHeader"":""test1""}",,123,200,"[1,2,3]"
+`)
+
+	records := respreader.ReadResponses(filename)
+
+	actual := testutils.ChanToSlice(records)
+	expected := []respreader.RespRecord{
+		{
+			ReqUrl:     "http://localhost:8080/api/test",
+			ReqMethod:  "GET",
+			ReqHeaders: `{"myHeader":"test1"}`,
+			ReqHash:    123,
+			RespStatus: "200",
+			RespBody:   "[1,2,3]",
+		},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("incorrect result (-expected +actual):\n%s", diff)
+	}
+}
+
 func TestReadRequestsWithWithIncorrectRecords(t *testing.T) {
 	tempDir := t.TempDir()
 	filename := testutils.CreateTempFile(tempDir, "responses.csv", `
@@ -113,3 +196,19 @@ func TestReadRequestsWithEmptyFile(t *testing.T) {
 		t.Error("incorrect result: expected slice size is 0, got", len(actual))
 	}
 }
+
+func TestCountResponses(t *testing.T) {
+	tempDir := t.TempDir()
+	filename := testutils.CreateTempFile(tempDir, "responses.csv", `
+req_url,req_method,req_headers,req_body,req_hash,resp_status,resp_body
+http://localhost:8080/api/test?prefix=te,PUT,,,123,200,foo
+http://localhost:8080/api/test?prefix=ca,GET,,,234,200,bar
+`)
+
+	if actual := respreader.CountResponses(filename); actual != 2 {
+		t.Error("incorrect result: expected number of records is 2, got", actual)
+	}
+	if actual := respreader.CountResponses(filepath.Join(tempDir, "nonexistent.csv")); actual != 0 {
+		t.Error("incorrect result: expected number of records is 0, got", actual)
+	}
+}
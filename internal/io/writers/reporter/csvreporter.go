@@ -28,8 +28,8 @@ func (r CsvReporter) Report(input <-chan comparator.RespDiff) {
 		"req1_url", "req1_method", "req1_headers", "req1_body",
 		"req2_url", "req2_method", "req2_headers", "req2_body",
 		"req_hash",
-		"resp1_status", "resp1_body",
-		"resp2_status", "resp2_body",
+		"resp1_status", "resp1_body", "resp1_error", "resp1_error_message", "resp1_redirects",
+		"resp2_status", "resp2_body", "resp2_error", "resp2_error_message", "resp2_redirects",
 	})
 
 	for d := range input {
@@ -38,8 +38,8 @@ func (r CsvReporter) Report(input <-chan comparator.RespDiff) {
 			d.Rec1.ReqUrl, d.Rec1.ReqMethod, d.Rec1.ReqHeaders, d.Rec1.ReqBody,
 			d.Rec2.ReqUrl, d.Rec2.ReqMethod, d.Rec2.ReqHeaders, d.Rec2.ReqBody,
 			reqHash,
-			d.Rec1.RespStatus, d.Rec1.RespBody,
-			d.Rec2.RespStatus, d.Rec2.RespBody,
+			d.Rec1.RespStatus, d.Rec1.RespBody, d.Rec1.RespError, d.Rec1.RespErrorMessage, d.Rec1.RespRedirects,
+			d.Rec2.RespStatus, d.Rec2.RespBody, d.Rec2.RespError, d.Rec2.RespErrorMessage, d.Rec2.RespRedirects,
 		})
 	}
 
@@ -27,6 +27,17 @@ func TestCsvReporter_Report(t *testing.T) {
 			},
 			Diffs: map[string][]strdiff.Diff{},
 		}
+		diffs <- comparator.RespDiff{
+			Rec1: respreader.RespRecord{
+				ReqUrl: "http://test1.com", ReqMethod: "GET", ReqHash: 456,
+				RespStatus: "200", RespBody: "hello",
+			},
+			Rec2: respreader.RespRecord{
+				ReqUrl: "http://test2.com", ReqMethod: "GET", ReqHash: 456,
+				RespError: "connection", RespErrorMessage: "connection reset by peer",
+			},
+			Diffs: map[string][]strdiff.Diff{},
+		}
 
 		close(diffs)
 	}()
@@ -35,8 +46,9 @@ func TestCsvReporter_Report(t *testing.T) {
 
 	actual := testutils.ReadFile(tempDir + "/report.csv")
 
-	expected := `req1_url,req1_method,req1_headers,req1_body,req2_url,req2_method,req2_headers,req2_body,req_hash,resp1_status,resp1_body,resp2_status,resp2_body
-http://test1.com,GET,headers,body,http://test2.com,GET,headers,body,123,200,hello,404,not found
+	expected := `req1_url,req1_method,req1_headers,req1_body,req2_url,req2_method,req2_headers,req2_body,req_hash,resp1_status,resp1_body,resp1_error,resp1_error_message,resp1_redirects,resp2_status,resp2_body,resp2_error,resp2_error_message,resp2_redirects
+http://test1.com,GET,headers,body,http://test2.com,GET,headers,body,123,200,hello,,,,404,not found,,,
+http://test1.com,GET,,,http://test2.com,GET,,,456,200,hello,,,,,,connection,connection reset by peer,
 `
 
 	if actual != expected {
@@ -0,0 +1,54 @@
+package reporter
+
+import (
+	"fmt"
+	"github.com/nikitakuchur/testpoint/internal/comparator"
+	"github.com/nikitakuchur/testpoint/internal/endpoint"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"log"
+	"slices"
+	"strings"
+)
+
+// GroupReporter counts the mismatches per endpoint and logs the counts when the comparison is done.
+// The GraphQL requests are grouped by their operation, since they all go to the same URL.
+type GroupReporter struct {
+	logger *log.Logger
+}
+
+func NewGroupReporter(logger *log.Logger) GroupReporter {
+	return GroupReporter{logger: logger}
+}
+
+func (r GroupReporter) Report(input <-chan comparator.RespDiff) {
+	counts := make(map[string]int)
+	for diff := range input {
+		group := endpoint.Group(sender.Request{
+			Url:    diff.Rec1.ReqUrl,
+			Method: diff.Rec1.ReqMethod,
+			Body:   diff.Rec1.ReqBody,
+		})
+		counts[group]++
+	}
+	if len(counts) == 0 {
+		return
+	}
+
+	groups := make([]string, 0, len(counts))
+	for group := range counts {
+		groups = append(groups, group)
+	}
+	// the groups with the most mismatches go first
+	slices.SortFunc(groups, func(a, b string) int {
+		if counts[a] != counts[b] {
+			return counts[b] - counts[a]
+		}
+		return strings.Compare(a, b)
+	})
+
+	sb := strings.Builder{}
+	for _, group := range groups {
+		sb.WriteString(fmt.Sprintf("\t%d\t%s\n", counts[group], group))
+	}
+	r.logger.Print("mismatches per endpoint:\n", sb.String())
+}
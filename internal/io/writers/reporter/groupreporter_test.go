@@ -0,0 +1,57 @@
+package reporter
+
+import (
+	"bytes"
+	"github.com/google/go-cmp/cmp"
+	"github.com/nikitakuchur/testpoint/internal/comparator"
+	"github.com/nikitakuchur/testpoint/internal/io/readers/respreader"
+	"log"
+	"testing"
+)
+
+func TestGroupReporter_Report(t *testing.T) {
+	buff := bytes.Buffer{}
+	logger := log.New(&buff, "", 0)
+	rep := NewGroupReporter(logger)
+
+	diffs := make(chan comparator.RespDiff)
+
+	go func() {
+		records := []respreader.RespRecord{
+			{ReqUrl: "http://test.com/users/123", ReqMethod: "GET"},
+			{ReqUrl: "http://test.com/graphql", ReqMethod: "POST", ReqBody: `{"query":"query FindUser { user { name } }"}`},
+			{ReqUrl: "http://test.com/users/456", ReqMethod: "GET"},
+			{ReqUrl: "http://test.com/graphql", ReqMethod: "POST", ReqBody: `{"query":"mutation AddUser { addUser { id } }"}`},
+			{ReqUrl: "http://test.com/graphql", ReqMethod: "POST", ReqBody: `{"query":"{ user { name } }","operationName":"FindUser"}`},
+		}
+		for _, rec := range records {
+			diffs <- comparator.RespDiff{Rec1: rec, Rec2: rec}
+		}
+		close(diffs)
+	}()
+
+	rep.Report(diffs)
+
+	expected := "mismatches per endpoint:\n" +
+		"\t2\tGET /users/:id\n" +
+		"\t2\tPOST /graphql FindUser\n" +
+		"\t1\tPOST /graphql AddUser\n"
+
+	if diff := cmp.Diff(expected, buff.String()); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestGroupReporter_ReportWithNoData(t *testing.T) {
+	buff := bytes.Buffer{}
+	rep := NewGroupReporter(log.New(&buff, "", 0))
+
+	diffs := make(chan comparator.RespDiff)
+	close(diffs)
+
+	rep.Report(diffs)
+
+	if buff.Len() != 0 {
+		t.Errorf("incorrect result: expected no output, got %q", buff.String())
+	}
+}
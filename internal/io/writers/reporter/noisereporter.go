@@ -0,0 +1,38 @@
+package reporter
+
+import (
+	"fmt"
+	"github.com/nikitakuchur/testpoint/internal/comparator"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// NoiseReporter logs the paths that were classified as noise per endpoint when the comparison is done,
+// so it's clear which parts of the responses haven't been compared.
+type NoiseReporter struct {
+	logger *log.Logger
+	paths  map[string][]string
+}
+
+func NewNoiseReporter(logger *log.Logger, paths map[string][]string) NoiseReporter {
+	return NoiseReporter{logger: logger, paths: paths}
+}
+
+func (r NoiseReporter) Report(input <-chan comparator.RespDiff) {
+	for range input {
+	}
+	if len(r.paths) == 0 {
+		return
+	}
+
+	sb := strings.Builder{}
+	for _, group := range slices.Sorted(maps.Keys(r.paths)) {
+		sb.WriteString(fmt.Sprintf("\t%s\n", group))
+		for _, path := range r.paths[group] {
+			sb.WriteString(fmt.Sprintf("\t\t%s\n", path))
+		}
+	}
+	r.logger.Print("noise per endpoint:\n", sb.String())
+}
@@ -0,0 +1,50 @@
+package reporter
+
+import (
+	"bytes"
+	"github.com/google/go-cmp/cmp"
+	"github.com/nikitakuchur/testpoint/internal/comparator"
+	"log"
+	"testing"
+)
+
+func TestNoiseReporter_Report(t *testing.T) {
+	buff := bytes.Buffer{}
+	rep := NewNoiseReporter(log.New(&buff, "", 0), map[string][]string{
+		"POST /users":    {"status"},
+		"GET /users/:id": {"body.createdAt", "body.tags[*]"},
+	})
+
+	diffs := make(chan comparator.RespDiff)
+	go func() {
+		diffs <- comparator.RespDiff{}
+		close(diffs)
+	}()
+
+	rep.Report(diffs)
+
+	expected := "noise per endpoint:\n" +
+		"\tGET /users/:id\n" +
+		"\t\tbody.createdAt\n" +
+		"\t\tbody.tags[*]\n" +
+		"\tPOST /users\n" +
+		"\t\tstatus\n"
+
+	if diff := cmp.Diff(expected, buff.String()); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestNoiseReporter_ReportWithNoNoise(t *testing.T) {
+	buff := bytes.Buffer{}
+	rep := NewNoiseReporter(log.New(&buff, "", 0), map[string][]string{})
+
+	diffs := make(chan comparator.RespDiff)
+	close(diffs)
+
+	rep.Report(diffs)
+
+	if buff.Len() != 0 {
+		t.Errorf("incorrect result: expected no output, got %q", buff.String())
+	}
+}
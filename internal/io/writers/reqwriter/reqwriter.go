@@ -0,0 +1,95 @@
+package reqwriter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/nikitakuchur/testpoint/internal/io/readers/reqreader"
+	"os"
+	"strings"
+)
+
+// Writer writes request records in the format reqreader reads: a CSV file with a header,
+// or JSON Lines if the file has the .jsonl or .ndjson extension.
+type Writer struct {
+	file   *os.File
+	csv    *csv.Writer
+	fields []string
+}
+
+// NewWriter creates the file and writes the header if it's a CSV file, the records must have the given fields.
+func NewWriter(filename string, fields []string) (*Writer, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	w := &Writer{file: file, fields: fields}
+	if !reqreader.IsJsonLines(filename) {
+		w.csv = csv.NewWriter(file)
+		if err := w.writeCsv(fields); err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Write writes the values of a record, they are flushed right away, so the file is complete at any moment.
+func (w *Writer) Write(values []string) error {
+	if w.csv != nil {
+		return w.writeCsv(values)
+	}
+	_, err := w.file.Write(w.jsonLine(values))
+	return err
+}
+
+func (w *Writer) writeCsv(values []string) error {
+	if err := w.csv.Write(values); err != nil {
+		return err
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+// jsonLine converts the values to a JSON object with the keys in the same order as the fields.
+// The values that are compact JSON objects or arrays, e.g. the headers, are written as they are, so they are easy to read.
+// The reader turns them back into the same strings.
+func (w *Writer) jsonLine(values []string) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range w.fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(field)
+		buf.Write(key)
+		buf.WriteByte(':')
+		var value string
+		if i < len(values) {
+			value = values[i]
+		}
+		if isCompactJson(value) {
+			buf.WriteString(value)
+		} else {
+			data, _ := json.Marshal(value)
+			buf.Write(data)
+		}
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+func isCompactJson(value string) bool {
+	if !strings.HasPrefix(value, "{") && !strings.HasPrefix(value, "[") {
+		return false
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, []byte(value)); err != nil {
+		return false
+	}
+	return compact.String() == value
+}
+
+func (w *Writer) Close() error {
+	return w.file.Close()
+}
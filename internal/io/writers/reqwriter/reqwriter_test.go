@@ -0,0 +1,68 @@
+package reqwriter_test
+
+import (
+	"context"
+	"github.com/google/go-cmp/cmp"
+	"github.com/nikitakuchur/testpoint/internal/io/readers/reqreader"
+	"github.com/nikitakuchur/testpoint/internal/io/writers/reqwriter"
+	testutils "github.com/nikitakuchur/testpoint/internal/utils/testing"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	fields := []string{"url", "method", "headers", "body"}
+	values := [][]string{
+		{"/api/test?prefix=te", "PUT", `{"myHeader":"test1"}`, `{"field":"test1"}`},
+		{"/api/test?prefix=ca", "POST", "", `{ "field": "not compact" }`},
+		{"/api/test?prefix=do", "GET", "", `"quoted"`},
+		{"/api/test?prefix=sp", "POST", "", "line1\nline2,\"quoted\""},
+	}
+
+	for _, name := range []string{"requests.csv", "requests.jsonl"} {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), name)
+			w, err := reqwriter.NewWriter(filename, fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range values {
+				if err := w.Write(v); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			actual := testutils.ChanToSlice(reqreader.ReadRequests(context.Background(), filename, true, 0))
+
+			var expected []reqreader.ReqRecord
+			for _, v := range values {
+				rec := reqreader.ReqRecord{Fields: fields, Values: v}
+				rec.Hash = reqreader.Hash(rec)
+				expected = append(expected, rec)
+			}
+			if diff := cmp.Diff(expected, actual); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestWriterWithJsonLines(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "requests.jsonl")
+	w, err := reqwriter.NewWriter(filename, []string{"url", "headers", "body"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Write([]string{"/api/test", `{"myHeader":"test1"}`, "text"})
+	_ = w.Close()
+
+	actual := testutils.ReadFile(filename)
+
+	expected := `{"url":"/api/test","headers":{"myHeader":"test1"},"body":"text"}` + "\n"
+	if actual != expected {
+		t.Errorf("incorrect result:\nexpected: %v\nactual: %v", expected, actual)
+	}
+}
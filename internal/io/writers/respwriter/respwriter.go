@@ -2,18 +2,77 @@ package respwriter
 
 import (
 	"encoding/csv"
+	"github.com/nikitakuchur/testpoint/internal/secrets"
 	"github.com/nikitakuchur/testpoint/internal/sender"
 	"log"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
-	"sync/atomic"
 	"time"
 )
 
+// bodiesDir is the directory inside the output directory where the large bodies are stored.
+const bodiesDir = "bodies"
+
+// header is the list of columns of the output files.
+var header = []string{
+	"req_url", "req_method", "req_headers", "req_body", "req_hash",
+	"resp_status", "resp_body", "resp_attempts", "resp_error", "resp_error_message",
+	"resp_body_encoding", "resp_body_truncated", "resp_body_digest", "resp_body_file",
+	"resp_headers", "resp_proto", "resp_ttfb", "resp_duration", "resp_size", "resp_content_encoding",
+	"resp_tls_version", "resp_tls_cipher", "resp_redirects", "resp_skew",
+	"resp_trailers", "req_repeat",
+}
+
+// Checkpoint is told about every written response, and it's saved only after the responses are flushed to the files.
+type Checkpoint interface {
+	Written(rr sender.RequestResponse)
+	Save() error
+}
+
+// checkpointInterval is how often the files are flushed and the checkpoint is saved.
+const checkpointInterval = 10 * time.Second
+
+type config struct {
+	bodyFileThreshold int
+	append            bool
+	checkpoint        Checkpoint
+}
+
+// Option configures how the responses are written.
+type Option func(c *config)
+
+// WithBodyFileThreshold makes the bodies longer than the given number of bytes to be stored in separate files.
+// The files are named after the body digests, and the records reference them in the resp_body_file column.
+func WithBodyFileThreshold(threshold int) Option {
+	return func(c *config) {
+		c.bodyFileThreshold = threshold
+	}
+}
+
+// WithAppend makes the responses to be appended to the existing files instead of overwriting them.
+func WithAppend() Option {
+	return func(c *config) {
+		c.append = true
+	}
+}
+
+// WithCheckpoint sets the checkpoint that keeps track of the written responses.
+func WithCheckpoint(checkpoint Checkpoint) Option {
+	return func(c *config) {
+		c.checkpoint = checkpoint
+	}
+}
+
 // WriteResponses creates files for each unique host and writes the results in them.
-func WriteResponses(input <-chan sender.RequestResponse, dir string) {
+// It writes everything until the input is closed, so the files are always complete, and returns the number of the written responses.
+func WriteResponses(input <-chan sender.RequestResponse, dir string, opts ...Option) uint64 {
+	var conf config
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
 	fileMap := make(map[string]*os.File)
 	writerMap := make(map[string]*csv.Writer)
 
@@ -24,21 +83,25 @@ func WriteResponses(input <-chan sender.RequestResponse, dir string) {
 		}
 	}()
 
-	var processed atomic.Uint64
-	ticker := time.NewTicker(10 * time.Second)
-	done := make(chan bool)
-
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			case _ = <-ticker.C:
-				log.Printf("collected %v responses...", processed.Load())
+	flush := func() {
+		for _, writer := range writerMap {
+			writer.Flush()
+			if err := writer.Error(); err != nil {
+				log.Fatalln("cannot write into a file:", err)
 			}
 		}
-	}()
+		if conf.checkpoint == nil {
+			return
+		}
+		if err := conf.checkpoint.Save(); err != nil {
+			log.Fatalln("cannot save the checkpoint:", err)
+		}
+	}
+	// the checkpoint must not get ahead of the files, so it's saved after everything is flushed
+	defer flush()
+	lastFlush := time.Now()
 
+	var processed uint64
 	for rr := range input {
 		userUrl := rr.Request.UserUrl
 
@@ -46,34 +109,101 @@ func WriteResponses(input <-chan sender.RequestResponse, dir string) {
 		writer := writerMap[userUrl]
 		if !ok {
 			path := filepath.Join(dir, urlToFilename(userUrl))
-			file = createFile(path)
+			var empty bool
+			if conf.append {
+				file, empty = openFile(path)
+			} else {
+				file, empty = createFile(path), true
+			}
 
 			fileMap[userUrl] = file
 			writer = csv.NewWriter(file)
 			writerMap[userUrl] = writer
 
-			writeLine(writer, []string{
-				"req_url", "req_method", "req_headers", "req_body", "req_hash",
-				"resp_status", "resp_body",
-			})
+			if empty {
+				writeLine(writer, header)
+			}
+		}
+
+		body, bodyFile := rr.Response.Body, ""
+		if conf.bodyFileThreshold > 0 && len(body) > conf.bodyFileThreshold {
+			bodyFile = writeBodyFile(dir, rr.Response)
+			body = ""
 		}
 
 		reqHash := strconv.FormatUint(rr.Request.Hash, 10)
-		writeLine(writer, []string{
+		writeLine(writer, maskSecrets([]string{
 			rr.Request.Url, rr.Request.Method, rr.Request.Headers, rr.Request.Body, reqHash,
-			rr.Response.Status, rr.Response.Body,
-		})
-		processed.Add(1)
+			rr.Response.Status, body, strconv.Itoa(rr.Response.Attempts),
+			rr.Response.Error, rr.Response.ErrorMessage,
+			rr.Response.BodyEncoding, strconv.FormatBool(rr.Response.BodyTruncated), rr.Response.BodyDigest, bodyFile,
+			rr.Response.Headers, rr.Response.Proto, rr.Response.TimeToFirstByte.String(), rr.Response.Duration.String(),
+			strconv.Itoa(rr.Response.Size), rr.Response.ContentEncoding,
+			rr.Response.TLSVersion, rr.Response.TLSCipher, rr.Response.Redirects, rr.Response.Skew.String(),
+			rr.Response.Trailers, strconv.Itoa(rr.Request.Repeat),
+		}))
+		processed++
+
+		if conf.checkpoint != nil {
+			conf.checkpoint.Written(rr)
+			if time.Since(lastFlush) >= checkpointInterval {
+				flush()
+				lastFlush = time.Now()
+			}
+		}
+	}
+	log.Println("total number of collected responses:", processed)
+	return processed
+}
+
+// writeBodyFile writes the body into a separate file and returns its path relative to the output directory.
+// The same bodies have the same digest, so they share the same file.
+func writeBodyFile(dir string, resp sender.Response) string {
+	name := filepath.Join(bodiesDir, resp.BodyDigest)
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err == nil {
+		return name
+	}
+
+	data := []byte(resp.Body)
+	if resp.BodyEncoding != "" {
+		var err error
+		data, err = sender.DecodeBody(resp.Body, resp.BodyEncoding)
+		if err != nil {
+			log.Fatalln("cannot decode a response body:", err)
+		}
+	}
+	data = []byte(secrets.MaskSecrets(string(data)))
+
+	err := os.MkdirAll(filepath.Join(dir, bodiesDir), 0755)
+	if err != nil {
+		log.Fatalln("cannot create a directory for the bodies:", err)
+	}
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		log.Fatalln("cannot write a body file:", err)
 	}
-	ticker.Stop()
-	done <- true
-	log.Println("total number of collected responses:", processed.Load())
+	return name
+}
+
+// maskSecrets hides the expanded secrets, so they never end up in the output files.
+func maskSecrets(record []string) []string {
+	for i, v := range record {
+		record[i] = secrets.MaskSecrets(v)
+	}
+	return record
 }
 
 func urlToFilename(url string) string {
 	if url == "" {
 		return "output.csv"
 	}
+	// the socket path starts with a slash, so the unix URLs would get a double dash, e.g. unix--var-run-app-sock
+	if socket, _, ok := sender.SplitUnixUrl(url); ok {
+		path := strings.TrimPrefix(url, "unix://"+socket)
+		url = "unix-" + strings.Trim(socket, "/") + "-" + strings.Trim(strings.TrimPrefix(path, ":"), "/")
+		url = strings.TrimSuffix(url, "-")
+	}
 	url = strings.ReplaceAll(url, "://", "-")
 	url = strings.ReplaceAll(url, ":", "-")
 	url = strings.ReplaceAll(url, "/", "-")
@@ -89,6 +219,19 @@ func createFile(path string) *os.File {
 	return file
 }
 
+// openFile opens the file for appending and tells whether it's empty, so it needs a header.
+func openFile(path string) (*os.File, bool) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		log.Fatalln("cannot open a file:", err)
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		log.Fatalln("cannot open a file:", err)
+	}
+	return file, stat.Size() == 0
+}
+
 func writeLine(writer *csv.Writer, record []string) {
 	err := writer.Write(record)
 	if err != nil {
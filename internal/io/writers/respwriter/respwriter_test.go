@@ -2,12 +2,14 @@ package respwriter_test
 
 import (
 	"github.com/nikitakuchur/testpoint/internal/io/writers/respwriter"
+	"github.com/nikitakuchur/testpoint/internal/secrets"
 	"github.com/nikitakuchur/testpoint/internal/sender"
 	testutils "github.com/nikitakuchur/testpoint/internal/utils/testing"
 	"log"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestWriteResponsesWithNoResponses(t *testing.T) {
@@ -39,7 +41,18 @@ func TestWriteResponsesToOneFile(t *testing.T) {
 				UserUrl: "http://test.com",
 				Hash:    1234,
 			},
-			Response: sender.Response{Status: "200", Body: "Hello world!"},
+			Response: sender.Response{
+				Status:          "200",
+				Body:            "Hello world!",
+				Attempts:        1,
+				Headers:         `{"Content-Type":"text/plain"}`,
+				Proto:           "HTTP/1.1",
+				TimeToFirstByte: 1500 * time.Microsecond,
+				Duration:        2 * time.Millisecond,
+				Size:            12,
+				ContentEncoding: "gzip",
+				Redirects:       `[{"status":301,"location":"/api/foo"}]`,
+			},
 		}
 		responses <- sender.RequestResponse{
 			Request: sender.Request{
@@ -50,7 +63,7 @@ func TestWriteResponsesToOneFile(t *testing.T) {
 				UserUrl: "http://test.com",
 				Hash:    5678,
 			},
-			Response: sender.Response{Status: "200", Body: "Goodbye!"},
+			Response: sender.Response{Status: "200", Body: "Goodbye!", Attempts: 1},
 		}
 		close(responses)
 	}()
@@ -65,9 +78,9 @@ func TestWriteResponsesToOneFile(t *testing.T) {
 
 	actual := testutils.ReadFile(tempDir + "/http-test-com.csv")
 
-	expected := `req_url,req_method,req_headers,req_body,req_hash,resp_status,resp_body
-http://test.com/api/foo,GET,"{""myHeader"":""foo""}","{""field"":""foo""}",1234,200,Hello world!
-http://test.com/api/bar,GET,"{""myHeader"":""bar""}","{""field"":""bar""}",5678,200,Goodbye!
+	expected := `req_url,req_method,req_headers,req_body,req_hash,resp_status,resp_body,resp_attempts,resp_error,resp_error_message,resp_body_encoding,resp_body_truncated,resp_body_digest,resp_body_file,resp_headers,resp_proto,resp_ttfb,resp_duration,resp_size,resp_content_encoding,resp_tls_version,resp_tls_cipher,resp_redirects,resp_skew,resp_trailers,req_repeat
+http://test.com/api/foo,GET,"{""myHeader"":""foo""}","{""field"":""foo""}",1234,200,Hello world!,1,,,,false,,,"{""Content-Type"":""text/plain""}",HTTP/1.1,1.5ms,2ms,12,gzip,,,"[{""status"":301,""location"":""/api/foo""}]",0s,,0
+http://test.com/api/bar,GET,"{""myHeader"":""bar""}","{""field"":""bar""}",5678,200,Goodbye!,1,,,,false,,,,,0s,0s,0,,,,,0s,,0
 `
 
 	if actual != expected {
@@ -89,7 +102,7 @@ func TestWriteResponsesToMultipleFiles(t *testing.T) {
 				UserUrl: "http://test1.com",
 				Hash:    1234,
 			},
-			Response: sender.Response{Status: "200", Body: "Hello world!"},
+			Response: sender.Response{Status: "200", Body: "Hello world!", Attempts: 1},
 		}
 		responses <- sender.RequestResponse{
 			Request: sender.Request{
@@ -100,7 +113,7 @@ func TestWriteResponsesToMultipleFiles(t *testing.T) {
 				UserUrl: "http://test2.com",
 				Hash:    5678,
 			},
-			Response: sender.Response{Status: "200", Body: "Goodbye!"},
+			Response: sender.Response{Status: "200", Body: "Goodbye!", Attempts: 1},
 		}
 		close(responses)
 	}()
@@ -117,11 +130,11 @@ func TestWriteResponsesToMultipleFiles(t *testing.T) {
 		filename string
 		content  string
 	}{
-		{"/http-test1-com.csv", `req_url,req_method,req_headers,req_body,req_hash,resp_status,resp_body
-http://test1.com/api/foo,GET,"{""myHeader"":""foo""}","{""field"":""foo""}",1234,200,Hello world!
+		{"/http-test1-com.csv", `req_url,req_method,req_headers,req_body,req_hash,resp_status,resp_body,resp_attempts,resp_error,resp_error_message,resp_body_encoding,resp_body_truncated,resp_body_digest,resp_body_file,resp_headers,resp_proto,resp_ttfb,resp_duration,resp_size,resp_content_encoding,resp_tls_version,resp_tls_cipher,resp_redirects,resp_skew,resp_trailers,req_repeat
+http://test1.com/api/foo,GET,"{""myHeader"":""foo""}","{""field"":""foo""}",1234,200,Hello world!,1,,,,false,,,,,0s,0s,0,,,,,0s,,0
 `},
-		{"/http-test2-com.csv", `req_url,req_method,req_headers,req_body,req_hash,resp_status,resp_body
-http://test2.com/api/bar,GET,"{""myHeader"":""bar""}","{""field"":""bar""}",5678,200,Goodbye!
+		{"/http-test2-com.csv", `req_url,req_method,req_headers,req_body,req_hash,resp_status,resp_body,resp_attempts,resp_error,resp_error_message,resp_body_encoding,resp_body_truncated,resp_body_digest,resp_body_file,resp_headers,resp_proto,resp_ttfb,resp_duration,resp_size,resp_content_encoding,resp_tls_version,resp_tls_cipher,resp_redirects,resp_skew,resp_trailers,req_repeat
+http://test2.com/api/bar,GET,"{""myHeader"":""bar""}","{""field"":""bar""}",5678,200,Goodbye!,1,,,,false,,,,,0s,0s,0,,,,,0s,,0
 `},
 	}
 
@@ -148,7 +161,7 @@ func TestWriteResponsesWithNoUrl(t *testing.T) {
 				UserUrl: "",
 				Hash:    1234,
 			},
-			Response: sender.Response{Status: "200", Body: "Hello world!"},
+			Response: sender.Response{Status: "200", Body: "Hello world!", Attempts: 1},
 		}
 		close(responses)
 	}()
@@ -163,13 +176,129 @@ func TestWriteResponsesWithNoUrl(t *testing.T) {
 
 	actual := testutils.ReadFile(tempDir + "/output.csv")
 
-	expected := `req_url,req_method,req_headers,req_body,req_hash,resp_status,resp_body
-http://test.com/api/foo,GET,"{""myHeader"":""foo""}","{""field"":""foo""}",1234,200,Hello world!
+	expected := `req_url,req_method,req_headers,req_body,req_hash,resp_status,resp_body,resp_attempts,resp_error,resp_error_message,resp_body_encoding,resp_body_truncated,resp_body_digest,resp_body_file,resp_headers,resp_proto,resp_ttfb,resp_duration,resp_size,resp_content_encoding,resp_tls_version,resp_tls_cipher,resp_redirects,resp_skew,resp_trailers,req_repeat
+http://test.com/api/foo,GET,"{""myHeader"":""foo""}","{""field"":""foo""}",1234,200,Hello world!,1,,,,false,,,,,0s,0s,0,,,,,0s,,0
+`
+
+	if actual != expected {
+		t.Errorf("incorrect result:\nexpected: %v\nactual: %v", expected, actual)
+	}
+}
+
+func TestWriteResponsesWithUnixUrls(t *testing.T) {
+	tempDir := t.TempDir()
+
+	responses := make(chan sender.RequestResponse)
+	go func() {
+		for _, userUrl := range []string{"unix:///var/run/app.sock:/api", "unix:///var/run/other.sock"} {
+			responses <- sender.RequestResponse{
+				Request:  sender.Request{Url: userUrl + "/foo", Method: "GET", UserUrl: userUrl, Hash: 1234},
+				Response: sender.Response{Status: "200", Attempts: 1},
+			}
+		}
+		close(responses)
+	}()
+
+	respwriter.WriteResponses(responses, tempDir)
+
+	actual := readFilenames(tempDir)
+
+	expected := []string{filepath.Join(tempDir, "unix-var-run-app-sock-api.csv"), filepath.Join(tempDir, "unix-var-run-other-sock.csv")}
+	if len(actual) != len(expected) || actual[0] != expected[0] || actual[1] != expected[1] {
+		t.Errorf("incorrect result: expected %v, got %v", expected, actual)
+	}
+}
+
+func TestWriteResponsesWithSecrets(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TESTPOINT_TOKEN", "qwerty")
+
+	url, err := secrets.Expand("http://test.com/api/foo?token=${TESTPOINT_TOKEN}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responses := make(chan sender.RequestResponse)
+	go func() {
+		responses <- sender.RequestResponse{
+			Request: sender.Request{
+				Url:     url,
+				Method:  "GET",
+				Headers: `{"Authorization":"Bearer qwerty"}`,
+				UserUrl: "http://test.com",
+				Hash:    1234,
+			},
+			// the server can echo the secrets back
+			Response: sender.Response{
+				Status:    "200",
+				Body:      "Hello qwerty!",
+				Attempts:  1,
+				Headers:   `{"X-Token":"qwerty"}`,
+				Redirects: `["http://test.com/api/bar?token=qwerty"]`,
+			},
+		}
+		responses <- sender.RequestResponse{
+			Request: sender.Request{Url: "http://test.com/api/baz", Method: "GET", UserUrl: "http://test.com", Hash: 5678},
+			Response: sender.Response{
+				Status:     "200",
+				Body:       "The token is qwerty",
+				BodyDigest: "digest",
+				Attempts:   1,
+			},
+		}
+		close(responses)
+	}()
+
+	respwriter.WriteResponses(responses, tempDir, respwriter.WithBodyFileThreshold(15))
+
+	actual := testutils.ReadFile(tempDir + "/http-test-com.csv")
+
+	expected := `req_url,req_method,req_headers,req_body,req_hash,resp_status,resp_body,resp_attempts,resp_error,resp_error_message,resp_body_encoding,resp_body_truncated,resp_body_digest,resp_body_file,resp_headers,resp_proto,resp_ttfb,resp_duration,resp_size,resp_content_encoding,resp_tls_version,resp_tls_cipher,resp_redirects,resp_skew,resp_trailers,req_repeat
+http://test.com/api/foo?token=*****,GET,"{""Authorization"":""Bearer *****""}",,1234,200,Hello *****!,1,,,,false,,,"{""X-Token"":""*****""}",,0s,0s,0,,,,"[""http://test.com/api/bar?token=*****""]",0s,,0
+http://test.com/api/baz,GET,,,5678,200,,1,,,,false,digest,bodies/digest,,,0s,0s,0,,,,,0s,,0
 `
 
 	if actual != expected {
 		t.Errorf("incorrect result:\nexpected: %v\nactual: %v", expected, actual)
 	}
+
+	actual = testutils.ReadFile(filepath.Join(tempDir, "bodies", "digest"))
+	if actual != "The token is *****" {
+		t.Errorf("incorrect body file: %v", actual)
+	}
+}
+
+func TestWriteResponsesWithBodyFiles(t *testing.T) {
+	tempDir := t.TempDir()
+
+	responses := make(chan sender.RequestResponse)
+	go func() {
+		responses <- sender.RequestResponse{
+			Request:  sender.Request{Url: "http://test.com/api/foo", Method: "GET", UserUrl: "http://test.com", Hash: 1234},
+			Response: sender.Response{Status: "200", Body: "Hello world!", Attempts: 1, BodyDigest: "abc"},
+		}
+		responses <- sender.RequestResponse{
+			Request:  sender.Request{Url: "http://test.com/api/bar", Method: "GET", UserUrl: "http://test.com", Hash: 5678},
+			Response: sender.Response{Status: "200", Body: "AAEC", Attempts: 1, BodyEncoding: "base64", BodyDigest: "def"},
+		}
+		close(responses)
+	}()
+
+	respwriter.WriteResponses(responses, tempDir, respwriter.WithBodyFileThreshold(5))
+
+	actual := testutils.ReadFile(tempDir + "/http-test-com.csv")
+
+	expected := `req_url,req_method,req_headers,req_body,req_hash,resp_status,resp_body,resp_attempts,resp_error,resp_error_message,resp_body_encoding,resp_body_truncated,resp_body_digest,resp_body_file,resp_headers,resp_proto,resp_ttfb,resp_duration,resp_size,resp_content_encoding,resp_tls_version,resp_tls_cipher,resp_redirects,resp_skew,resp_trailers,req_repeat
+http://test.com/api/foo,GET,,,1234,200,,1,,,,false,abc,bodies/abc,,,0s,0s,0,,,,,0s,,0
+http://test.com/api/bar,GET,,,5678,200,AAEC,1,,,base64,false,def,,,,0s,0s,0,,,,,0s,,0
+`
+
+	if actual != expected {
+		t.Errorf("incorrect result:\nexpected: %v\nactual: %v", expected, actual)
+	}
+	if body := testutils.ReadFile(tempDir + "/bodies/abc"); body != "Hello world!" {
+		t.Errorf("incorrect body file: expected 'Hello world!', got '%v'", body)
+	}
 }
 
 func readFilenames(path string) []string {
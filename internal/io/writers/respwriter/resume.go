@@ -0,0 +1,157 @@
+package respwriter
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+)
+
+// Resume prepares the existing output files in the directory for appending and returns the hashes of the requests
+// that have already been written for each user URL. If the previous run was killed in the middle of writing a record,
+// the incomplete record is cut off, so the request is sent once again.
+// The records of the failed requests are removed too, since the errors, e.g. timeouts, are usually worth retrying,
+// and the file mustn't have two responses to the same request.
+func Resume(dir string, userUrls []string) (map[string]map[uint64]bool, error) {
+	completed := make(map[string]map[uint64]bool, len(userUrls))
+	for _, userUrl := range userUrls {
+		path := filepath.Join(dir, urlToFilename(userUrl))
+		hashes, err := resumeFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", path, err)
+		}
+		completed[userUrl] = hashes
+	}
+	return completed, nil
+}
+
+func resumeFile(path string) (map[uint64]bool, error) {
+	hashes := make(map[uint64]bool)
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		return hashes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := stat.Size()
+
+	reader := csv.NewReader(file)
+	h, err := reader.Read()
+	if err == io.EOF {
+		return hashes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Equal(h, header) {
+		return nil, errors.New("the file was written by a different version, so it cannot be resumed")
+	}
+	hashColumn := slices.Index(header, "req_hash")
+	errorColumn := slices.Index(header, "resp_error")
+	headerEnd := reader.InputOffset()
+
+	// failed are the byte ranges of the records of the failed requests
+	var failed [][2]int64
+	complete := func(hash uint64, failedRequest bool, start int64, end int64) {
+		if failedRequest {
+			failed = append(failed, [2]int64{start, end})
+		} else {
+			hashes[hash] = true
+		}
+	}
+
+	// the hash of the last record isn't added until we know the record is complete
+	var lastHash uint64
+	var lastStart, lastEnd int64
+	hasLast, lastFailed := false, false
+	broken := false
+	for {
+		start := reader.InputOffset()
+		values, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// only the last record can be broken, since it's the only one that could be written partially
+			if _, err := reader.Read(); err != io.EOF {
+				return nil, fmt.Errorf("the record at offset %v is broken", start)
+			}
+			broken = true
+			break
+		}
+		if hasLast {
+			complete(lastHash, lastFailed, lastStart, lastEnd)
+		}
+		lastHash, err = strconv.ParseUint(values[hashColumn], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse the hash value '%v'", values[hashColumn])
+		}
+		hasLast, lastFailed, lastStart, lastEnd = true, values[errorColumn] != "", start, reader.InputOffset()
+	}
+
+	end := reader.InputOffset()
+	if hasLast {
+		// a complete record always ends with a new line, otherwise it has to be written once again
+		if broken || endsWithNewLine(file, lastEnd) {
+			complete(lastHash, lastFailed, lastStart, lastEnd)
+			end = lastEnd
+		} else {
+			end = lastStart
+		}
+	} else if broken {
+		end = headerEnd
+	}
+	if len(failed) > 0 {
+		end, err = removeRecords(file, failed, end)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("%v: %v responses to the failed requests were removed, the requests are sent once again", path, len(failed))
+	}
+	if end < size {
+		if err := file.Truncate(end); err != nil {
+			return nil, err
+		}
+	}
+	return hashes, nil
+}
+
+// removeRecords cuts the given byte ranges out of the part of the file before the end, and returns its new end.
+// The ranges must be in order, the data after each of them is moved back in place.
+func removeRecords(file *os.File, ranges [][2]int64, end int64) (int64, error) {
+	dst := ranges[0][0]
+	for i, r := range ranges {
+		next := end
+		if i+1 < len(ranges) {
+			next = ranges[i+1][0]
+		}
+		// the data is only moved back, so it's always read before it's overwritten
+		n, err := io.Copy(io.NewOffsetWriter(file, dst), io.NewSectionReader(file, r[1], next-r[1]))
+		if err != nil {
+			return 0, err
+		}
+		dst += n
+	}
+	return dst, nil
+}
+
+// endsWithNewLine checks whether the part of the file before the given offset ends with a new line.
+func endsWithNewLine(file *os.File, offset int64) bool {
+	b := make([]byte, 1)
+	_, err := file.ReadAt(b, offset-1)
+	return err == nil && b[0] == '\n'
+}
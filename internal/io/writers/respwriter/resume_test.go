@@ -0,0 +1,103 @@
+package respwriter_test
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/nikitakuchur/testpoint/internal/io/writers/respwriter"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	testutils "github.com/nikitakuchur/testpoint/internal/utils/testing"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const header = "req_url,req_method,req_headers,req_body,req_hash,resp_status,resp_body,resp_attempts,resp_error,resp_error_message,resp_body_encoding,resp_body_truncated,resp_body_digest,resp_body_file,resp_headers,resp_proto,resp_ttfb,resp_duration,resp_size,resp_content_encoding,resp_tls_version,resp_tls_cipher,resp_redirects,resp_skew,resp_trailers,req_repeat\n"
+
+func TestResume(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// the last record of the first file was cut off in the middle
+	writeFile(t, filepath.Join(tempDir, "http-test1-com.csv"), header+
+		"http://test1.com/api/foo,GET,,,1234,200,foo,1,,,,false,,,,,0s,0s,0,,,,,0s,,0\n"+
+		"http://test1.com/api/bar,GET,,,5678,200,bar,1,,,,false,,,,,0s,0s,0,,,,,0")
+	writeFile(t, filepath.Join(tempDir, "http-test2-com.csv"), header+
+		"http://test2.com/api/foo,GET,,,1234,200,foo,1,,,,false,,,,,0s,0s,0,,,,,0s,,0\n"+
+		"http://test2.com/api/bar,GET,,,5678,200,bar,1,,,,false,,,,,0s,0s,0,,,,,0s,,0\n")
+
+	completed, err := respwriter.Resume(tempDir, []string{"http://test1.com", "http://test2.com", "http://test3.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]map[uint64]bool{
+		"http://test1.com": {1234: true},
+		"http://test2.com": {1234: true, 5678: true},
+		"http://test3.com": {},
+	}
+	if diff := cmp.Diff(expected, completed); diff != "" {
+		t.Error(diff)
+	}
+
+	responses := make(chan sender.RequestResponse)
+	go func() {
+		responses <- sender.RequestResponse{
+			Request:  sender.Request{Url: "http://test1.com/api/bar", Method: "GET", UserUrl: "http://test1.com", Hash: 5678},
+			Response: sender.Response{Status: "200", Body: "bar", Attempts: 1},
+		}
+		close(responses)
+	}()
+
+	respwriter.WriteResponses(responses, tempDir, respwriter.WithAppend())
+
+	actual := testutils.ReadFile(filepath.Join(tempDir, "http-test1-com.csv"))
+	expectedFile := header +
+		"http://test1.com/api/foo,GET,,,1234,200,foo,1,,,,false,,,,,0s,0s,0,,,,,0s,,0\n" +
+		"http://test1.com/api/bar,GET,,,5678,200,bar,1,,,,false,,,,,0s,0s,0,,,,,0s,,0\n"
+	if actual != expectedFile {
+		t.Errorf("incorrect result:\nexpected: %v\nactual: %v", expectedFile, actual)
+	}
+}
+
+func TestResumeWithFailedRequests(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "http-test-com.csv")
+	writeFile(t, path, header+
+		"http://test.com/api/foo,GET,,,1,,,1,timeout,timed out,,false,,,,,0s,0s,0,,,,,0s,,0\n"+
+		"http://test.com/api/bar,GET,,,2,200,bar,1,,,,false,,,,,0s,0s,0,,,,,0s,,0\n"+
+		"http://test.com/api/baz,GET,,,3,,,1,connection,refused,,false,,,,,0s,0s,0,,,,,0s,,0\n"+
+		"http://test.com/api/qux,GET,,,4,200,qux,1,,,,false,,,,,0s,0s,0,,,,,0s,,0\n"+
+		"http://test.com/api/quux,GET,,,5,,,1,timeout,timed out,,false,,,,,0s,0s,0,,,,,0s,,0\n")
+
+	completed, err := respwriter.Resume(tempDir, []string{"http://test.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the failed requests are sent once again, so their records are removed
+	expected := map[string]map[uint64]bool{"http://test.com": {2: true, 4: true}}
+	if diff := cmp.Diff(expected, completed); diff != "" {
+		t.Error(diff)
+	}
+	actual := testutils.ReadFile(path)
+	expectedFile := header +
+		"http://test.com/api/bar,GET,,,2,200,bar,1,,,,false,,,,,0s,0s,0,,,,,0s,,0\n" +
+		"http://test.com/api/qux,GET,,,4,200,qux,1,,,,false,,,,,0s,0s,0,,,,,0s,,0\n"
+	if actual != expectedFile {
+		t.Errorf("incorrect result:\nexpected: %v\nactual: %v", expectedFile, actual)
+	}
+}
+
+func TestResumeWithDifferentColumns(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFile(t, filepath.Join(tempDir, "http-test-com.csv"), "req_url,req_method,req_headers,req_body,req_hash,resp_status,resp_body\n")
+
+	_, err := respwriter.Resume(tempDir, []string{"http://test.com"})
+	if err == nil {
+		t.Error("incorrect result: expected an error, got nil")
+	}
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
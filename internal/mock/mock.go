@@ -0,0 +1,235 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nikitakuchur/testpoint/internal/io/readers/respreader"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Config contains the settings of the mock server.
+type Config struct {
+	// MatchBody makes the request body a part of the match, the JSON bodies are compared regardless of formatting and key order.
+	MatchBody bool
+	// FallbackStatus is the status of the responses to the requests that haven't been recorded.
+	FallbackStatus int
+	// FallbackBody is the body of the responses to the requests that haven't been recorded.
+	FallbackBody string
+}
+
+// skippedHeaders are the recorded headers that describe the original connection rather than the response.
+var skippedHeaders = []string{"Connection", "Keep-Alive", "Content-Length", "Transfer-Encoding", "Trailer"}
+
+// response is a recorded response that is ready to be written.
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// Server is an HTTP server that answers the requests with the recorded responses.
+// If the same request has been recorded more than once, the responses are returned in the recorded order,
+// and the last one is repeated after that.
+type Server struct {
+	conf      Config
+	responses map[string][]response
+	unmatched atomic.Uint64
+
+	// mu protects the positions of the requests that have more than one response
+	mu   sync.Mutex
+	next map[string]int
+}
+
+func New(conf Config) *Server {
+	return &Server{conf: conf, responses: make(map[string][]response), next: make(map[string]int)}
+}
+
+// Load adds the records to the server. The bodies stored in separate files are read from the given directory.
+// The records that don't have a complete HTTP response, like the failed or truncated ones, are skipped.
+// It returns the number of loaded and skipped records.
+func (s *Server) Load(records <-chan respreader.RespRecord, dir string) (loaded int, skipped int, err error) {
+	for rec := range records {
+		if !isReplayable(rec) {
+			skipped++
+			continue
+		}
+		resp, err := newResponse(rec, dir)
+		if err != nil {
+			// the channel is drained, so the reader doesn't get stuck
+			for range records {
+			}
+			return loaded, skipped, err
+		}
+		key, err := s.key(rec.ReqMethod, rec.ReqUrl, rec.ReqBody)
+		if err != nil {
+			skipped++
+			continue
+		}
+		s.responses[key] = append(s.responses[key], resp)
+		loaded++
+	}
+	return loaded, skipped, nil
+}
+
+// isReplayable checks whether the record has the whole HTTP response.
+func isReplayable(rec respreader.RespRecord) bool {
+	if rec.RespError != "" || rec.RespBodyTruncated {
+		return false
+	}
+	return !strings.HasPrefix(rec.ReqUrl, "grpc://") && !strings.HasPrefix(rec.ReqUrl, "grpcs://")
+}
+
+func newResponse(rec respreader.RespRecord, dir string) (response, error) {
+	status, err := strconv.Atoi(rec.RespStatus)
+	if err != nil {
+		return response{}, fmt.Errorf("invalid status of the response to %v: %w", rec.ReqUrl, err)
+	}
+
+	header := make(http.Header)
+	if rec.RespHeaders != "" {
+		var headers map[string]string
+		if err := json.Unmarshal([]byte(rec.RespHeaders), &headers); err != nil {
+			return response{}, fmt.Errorf("invalid headers of the response to %v: %w", rec.ReqUrl, err)
+		}
+		for k, v := range headers {
+			if http.CanonicalHeaderKey(k) == "Set-Cookie" {
+				for _, cookie := range splitCookies(v) {
+					header.Add(k, cookie)
+				}
+				continue
+			}
+			header.Set(k, v)
+		}
+	}
+	for _, h := range skippedHeaders {
+		header.Del(h)
+	}
+
+	var body []byte
+	if rec.RespBodyFile != "" {
+		body, err = os.ReadFile(filepath.Join(dir, rec.RespBodyFile))
+	} else {
+		body, err = sender.DecodeBody(rec.RespBody, rec.RespBodyEncoding)
+	}
+	if err != nil {
+		return response{}, fmt.Errorf("cannot read the body of the response to %v: %w", rec.ReqUrl, err)
+	}
+	return response{status: status, header: header, body: body}, nil
+}
+
+// cookieSeparator matches the commas the Set-Cookie headers were joined with when they were recorded.
+// A new cookie starts with its name and the equals sign, so the commas inside the dates, e.g. in Expires, are left alone.
+var cookieSeparator = regexp.MustCompile(`, *([^;,\s=]+=)`)
+
+// splitCookies splits the recorded value of the Set-Cookie headers back into separate headers.
+func splitCookies(value string) []string {
+	var cookies []string
+	start := 0
+	for _, m := range cookieSeparator.FindAllStringSubmatchIndex(value, -1) {
+		cookies = append(cookies, value[start:m[0]])
+		start = m[2]
+	}
+	return append(cookies, value[start:])
+}
+
+// key returns the string the requests are matched by: the method, the path, the query with sorted parameters,
+// and the body if it's enabled.
+func (s *Server) key(method string, rawUrl string, body string) (string, error) {
+	if _, httpUrl, ok := sender.SplitUnixUrl(rawUrl); ok {
+		rawUrl = httpUrl
+	}
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", err
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	key := strings.ToUpper(method) + " " + path + "?" + u.Query().Encode()
+	if s.conf.MatchBody {
+		key += "\n" + normalizeBody(body)
+	}
+	return key, nil
+}
+
+// normalizeBody sorts the keys and removes the whitespace if the body is JSON.
+func normalizeBody(body string) string {
+	var v any
+	if err := json.Unmarshal([]byte(body), &v); err != nil {
+		return body
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return string(data)
+}
+
+func (s *Server) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(rw, "cannot read the request body", http.StatusBadRequest)
+		return
+	}
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	key, err := s.key(method, r.URL.RequestURI(), string(body))
+	if err != nil {
+		http.Error(rw, "invalid request URL", http.StatusBadRequest)
+		return
+	}
+
+	resp, ok := s.find(key)
+	if !ok {
+		if s.unmatched.Add(1) == 1 {
+			log.Println("some of the requests haven't been recorded, they get the fallback response")
+		}
+		rw.WriteHeader(s.conf.FallbackStatus)
+		_, _ = rw.Write([]byte(s.conf.FallbackBody))
+		return
+	}
+
+	for k, v := range resp.header {
+		rw.Header()[k] = v
+	}
+	rw.WriteHeader(resp.status)
+	_, _ = rw.Write(resp.body)
+}
+
+// find returns the next response to the request with the given key.
+func (s *Server) find(key string) (response, bool) {
+	responses := s.responses[key]
+	if len(responses) == 0 {
+		return response{}, false
+	}
+	if len(responses) == 1 {
+		return responses[0], true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.next[key]
+	if i < len(responses)-1 {
+		s.next[key] = i + 1
+	}
+	return responses[i], true
+}
+
+// Unmatched returns the number of requests that got the fallback response.
+func (s *Server) Unmatched() uint64 {
+	return s.unmatched.Load()
+}
@@ -0,0 +1,180 @@
+package mock_test
+
+import (
+	"encoding/base64"
+	"github.com/google/go-cmp/cmp"
+	"github.com/nikitakuchur/testpoint/internal/io/readers/respreader"
+	"github.com/nikitakuchur/testpoint/internal/mock"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func toChan(records ...respreader.RespRecord) <-chan respreader.RespRecord {
+	output := make(chan respreader.RespRecord, len(records))
+	for _, rec := range records {
+		output <- rec
+	}
+	close(output)
+	return output
+}
+
+func do(t *testing.T, method string, url string, body string) (int, http.Header, string) {
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, resp.Header, string(data)
+}
+
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "bodies"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bodies", "abc"), []byte("large body"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := mock.New(mock.Config{FallbackStatus: http.StatusTeapot, FallbackBody: "not recorded"})
+	loaded, skipped, err := server.Load(toChan(
+		respreader.RespRecord{
+			ReqUrl: "http://localhost:8080/api/users?b=2&a=1", ReqMethod: "GET",
+			RespStatus: "200", RespBody: `{"id":1}`, RespHeaders: `{"Content-Type":"application/json","Content-Length":"8"}`,
+		},
+		respreader.RespRecord{
+			ReqUrl: "http://localhost:8080/api/users", ReqMethod: "POST", ReqBody: `{"name":"foo"}`,
+			RespStatus: "201", RespBody: "created",
+		},
+		respreader.RespRecord{
+			ReqUrl: "http://localhost:8080/api/image", ReqMethod: "GET",
+			RespStatus: "200", RespBody: base64.StdEncoding.EncodeToString([]byte{0xff, 0x00}), RespBodyEncoding: sender.BodyEncodingBase64,
+		},
+		respreader.RespRecord{
+			ReqUrl: "http://localhost:8080/api/large", ReqMethod: "GET",
+			RespStatus: "200", RespBodyFile: filepath.Join("bodies", "abc"),
+		},
+		respreader.RespRecord{ReqUrl: "http://localhost:8080/api/failed", ReqMethod: "GET", RespError: sender.ErrorTimeout},
+		respreader.RespRecord{ReqUrl: "http://localhost:8080/api/truncated", ReqMethod: "GET", RespStatus: "200", RespBodyTruncated: true},
+	), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded != 4 || skipped != 2 {
+		t.Errorf("incorrect number of records: loaded %v, skipped %v", loaded, skipped)
+	}
+
+	s := httptest.NewServer(server)
+	defer s.Close()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		resp   string
+	}{
+		{name: "query in a different order", method: "GET", path: "/api/users?a=1&b=2", status: 200, resp: `{"id":1}`},
+		{name: "body is ignored", method: "POST", path: "/api/users", body: "anything", status: 201, resp: "created"},
+		{name: "binary body", method: "GET", path: "/api/image", status: 200, resp: string([]byte{0xff, 0x00})},
+		{name: "body file", method: "GET", path: "/api/large", status: 200, resp: "large body"},
+		{name: "different method", method: "DELETE", path: "/api/users", status: 418, resp: "not recorded"},
+		{name: "different query", method: "GET", path: "/api/users?a=1", status: 418, resp: "not recorded"},
+		{name: "failed request", method: "GET", path: "/api/failed", status: 418, resp: "not recorded"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, _, body := do(t, test.method, s.URL+test.path, test.body)
+			if status != test.status || body != test.resp {
+				t.Errorf("incorrect response: expected %v %q, got %v %q", test.status, test.resp, status, body)
+			}
+		})
+	}
+
+	_, header, _ := do(t, "GET", s.URL+"/api/users?a=1&b=2", "")
+	if header.Get("Content-Type") != "application/json" {
+		t.Errorf("incorrect headers: %v", header)
+	}
+	if server.Unmatched() != 3 {
+		t.Errorf("incorrect number of unmatched requests: expected 3, got %v", server.Unmatched())
+	}
+}
+
+func TestServerWithMatchBody(t *testing.T) {
+	server := mock.New(mock.Config{MatchBody: true, FallbackStatus: http.StatusNotFound})
+	_, _, err := server.Load(toChan(
+		respreader.RespRecord{ReqUrl: "http://localhost:8080/search", ReqMethod: "POST", ReqBody: `{"a":1,"b":2}`, RespStatus: "200", RespBody: "first"},
+		respreader.RespRecord{ReqUrl: "http://localhost:8080/search", ReqMethod: "POST", ReqBody: `{"a":2}`, RespStatus: "200", RespBody: "second"},
+	), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := httptest.NewServer(server)
+	defer s.Close()
+
+	if _, _, body := do(t, "POST", s.URL+"/search", `{ "b": 2, "a": 1 }`); body != "first" {
+		t.Errorf("incorrect response: expected %q, got %q", "first", body)
+	}
+	if _, _, body := do(t, "POST", s.URL+"/search", `{"a":2}`); body != "second" {
+		t.Errorf("incorrect response: expected %q, got %q", "second", body)
+	}
+	if status, _, _ := do(t, "POST", s.URL+"/search", `{"a":3}`); status != http.StatusNotFound {
+		t.Errorf("incorrect status: expected 404, got %v", status)
+	}
+}
+
+func TestServerWithRepeatedRequests(t *testing.T) {
+	server := mock.New(mock.Config{FallbackStatus: http.StatusNotFound})
+	_, _, err := server.Load(toChan(
+		respreader.RespRecord{ReqUrl: "http://localhost:8080/counter", ReqMethod: "GET", RespStatus: "200", RespBody: "1"},
+		respreader.RespRecord{ReqUrl: "http://localhost:8080/counter", ReqMethod: "GET", RespStatus: "200", RespBody: "2"},
+	), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := httptest.NewServer(server)
+	defer s.Close()
+
+	var actual []string
+	for range 3 {
+		_, _, body := do(t, "GET", s.URL+"/counter", "")
+		actual = append(actual, body)
+	}
+	if strings.Join(actual, ",") != "1,2,2" {
+		t.Errorf("incorrect responses: expected 1,2,2, got %v", actual)
+	}
+}
+
+func TestServerWithCookies(t *testing.T) {
+	server := mock.New(mock.Config{})
+	_, _, err := server.Load(toChan(respreader.RespRecord{
+		ReqUrl: "http://localhost:8080/login", ReqMethod: "POST", RespStatus: "200",
+		RespHeaders: `{"Set-Cookie":"a=1; Path=/, b=2; Expires=Wed, 21 Oct 2026 07:28:00 GMT; HttpOnly","Vary":"Accept, Cookie"}`,
+	}), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := httptest.NewServer(server)
+	defer s.Close()
+
+	_, header, _ := do(t, "POST", s.URL+"/login", "")
+	expected := []string{"a=1; Path=/", "b=2; Expires=Wed, 21 Oct 2026 07:28:00 GMT; HttpOnly"}
+	if diff := cmp.Diff(expected, header.Values("Set-Cookie")); diff != "" {
+		t.Error(diff)
+	}
+	if header.Get("Vary") != "Accept, Cookie" {
+		t.Errorf("incorrect header: %v", header.Get("Vary"))
+	}
+}
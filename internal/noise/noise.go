@@ -0,0 +1,215 @@
+package noise
+
+import (
+	"encoding/json"
+	"github.com/nikitakuchur/testpoint/internal/comparator"
+	"github.com/nikitakuchur/testpoint/internal/endpoint"
+	"github.com/nikitakuchur/testpoint/internal/io/readers/respreader"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"maps"
+	"slices"
+	"strconv"
+	"sync/atomic"
+)
+
+const (
+	// statusPath stands for the status of the response.
+	statusPath = "status"
+	// bodyPath stands for the whole body, the paths inside JSON bodies start with it as well.
+	bodyPath = "body"
+)
+
+// Noise keeps the parts of the responses that differ between two instances of the reference,
+// e.g. timestamps or random IDs. They are collected per endpoint, and the array indices are replaced with [*],
+// so a field that differs in one element of an array is considered noisy in all of them.
+type Noise struct {
+	paths      map[string]map[string]struct{}
+	suppressed atomic.Uint64
+}
+
+func New() *Noise {
+	return &Noise{paths: make(map[string]map[string]struct{})}
+}
+
+// Detect compares the responses of two instances of the reference and collects the differences as noise.
+func Detect(records1, records2 <-chan respreader.RespRecord) *Noise {
+	n := New()
+	for pair := range comparator.MatchResponses(records1, records2, 0) {
+		n.Add(pair[0], pair[1])
+	}
+	return n
+}
+
+// Add collects the differences between the two responses of the reference to the same request.
+// The failed requests are ignored, since they don't tell anything about the fields.
+func (n *Noise) Add(x, y respreader.RespRecord) {
+	if x.RespError != "" || y.RespError != "" {
+		return
+	}
+	group := groupOf(x)
+	if x.RespStatus != y.RespStatus {
+		n.add(group, statusPath)
+	}
+	if comparator.IsOpaqueBody(x) || comparator.IsOpaqueBody(y) {
+		if x.RespBodyDigest != y.RespBodyDigest {
+			n.add(group, bodyPath)
+		}
+		return
+	}
+	if x.RespBody == y.RespBody {
+		return
+	}
+
+	var v1, v2 any
+	if json.Unmarshal([]byte(x.RespBody), &v1) != nil || json.Unmarshal([]byte(y.RespBody), &v2) != nil {
+		n.add(group, bodyPath)
+		return
+	}
+	leaves1, leaves2 := make(map[string]leaf), make(map[string]leaf)
+	flatten(v1, "", bodyPath, leaves1)
+	flatten(v2, "", bodyPath, leaves2)
+	for path, l := range leaves1 {
+		if other, ok := leaves2[path]; !ok || other.value != l.value {
+			n.add(group, l.pattern)
+		}
+	}
+	for path, l := range leaves2 {
+		if _, ok := leaves1[path]; !ok {
+			n.add(group, l.pattern)
+		}
+	}
+}
+
+func (n *Noise) add(group string, path string) {
+	if n.paths[group] == nil {
+		n.paths[group] = make(map[string]struct{})
+	}
+	n.paths[group][path] = struct{}{}
+}
+
+// leaf is a scalar value or an empty object or array in a JSON document.
+type leaf struct {
+	// pattern is the path to the leaf where the array indices are replaced with [*]
+	pattern string
+	value   string
+}
+
+// flatten collects the leaves of the JSON value by their exact paths.
+func flatten(v any, path string, pattern string, output map[string]leaf) {
+	switch value := v.(type) {
+	case map[string]any:
+		if len(value) == 0 {
+			break
+		}
+		for k, child := range value {
+			flatten(child, path+"."+k, pattern+"."+k, output)
+		}
+		return
+	case []any:
+		if len(value) == 0 {
+			break
+		}
+		for i, child := range value {
+			flatten(child, path+"["+strconv.Itoa(i)+"]", pattern+"[*]", output)
+		}
+		return
+	}
+	data, _ := json.Marshal(v)
+	output[path] = leaf{pattern: pattern, value: string(data)}
+}
+
+func groupOf(rec respreader.RespRecord) string {
+	return endpoint.Group(sender.Request{Url: rec.ReqUrl, Method: rec.ReqMethod, Body: rec.ReqBody})
+}
+
+// Remove returns the record without the noisy parts of the response to the given endpoint.
+func (n *Noise) Remove(rec respreader.RespRecord, group string) respreader.RespRecord {
+	paths := n.paths[group]
+	if len(paths) == 0 {
+		return rec
+	}
+	if _, ok := paths[statusPath]; ok {
+		rec.RespStatus = ""
+	}
+	if _, ok := paths[bodyPath]; ok {
+		rec.RespBody, rec.RespBodyDigest = "", ""
+		rec.RespBodyEncoding, rec.RespBodyFile, rec.RespBodyTruncated = "", "", false
+		return rec
+	}
+	if comparator.IsOpaqueBody(rec) {
+		return rec
+	}
+	var v any
+	if json.Unmarshal([]byte(rec.RespBody), &v) != nil {
+		return rec
+	}
+	data, err := json.Marshal(remove(v, bodyPath, paths))
+	if err == nil {
+		rec.RespBody = string(data)
+	}
+	return rec
+}
+
+// remove drops the values whose path patterns are noisy, and returns what is left.
+func remove(v any, pattern string, paths map[string]struct{}) any {
+	switch value := v.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(value))
+		for k, child := range value {
+			p := pattern + "." + k
+			if _, ok := paths[p]; !ok {
+				result[k] = remove(child, p, paths)
+			}
+		}
+		return result
+	case []any:
+		p := pattern + "[*]"
+		if _, ok := paths[p]; ok {
+			return []any{}
+		}
+		result := make([]any, 0, len(value))
+		for _, child := range value {
+			result = append(result, remove(child, p, paths))
+		}
+		return result
+	}
+	return v
+}
+
+// Filter compares the mismatched records again without the noise, and passes on only the mismatches that are left.
+// The mismatches keep the original records, so the reports show the whole responses.
+func (n *Noise) Filter(input <-chan comparator.RespDiff, comp comparator.Comparator) <-chan comparator.RespDiff {
+	output := make(chan comparator.RespDiff)
+
+	go func() {
+		defer close(output)
+
+		for diff := range input {
+			// the endpoint is taken from the reference, so both records are filtered the same way
+			group := groupOf(diff.Rec1)
+			diffs := comparator.CompareRecords(n.Remove(diff.Rec1, group), n.Remove(diff.Rec2, group), comp)
+			if len(diffs) == 0 {
+				n.suppressed.Add(1)
+				continue
+			}
+			diff.Diffs = diffs
+			output <- diff
+		}
+	}()
+
+	return output
+}
+
+// Suppressed returns the number of mismatches that turned out to be noise.
+func (n *Noise) Suppressed() uint64 {
+	return n.suppressed.Load()
+}
+
+// Paths returns the sorted noisy paths per endpoint.
+func (n *Noise) Paths() map[string][]string {
+	result := make(map[string][]string, len(n.paths))
+	for group, paths := range n.paths {
+		result[group] = slices.Sorted(maps.Keys(paths))
+	}
+	return result
+}
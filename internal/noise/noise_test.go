@@ -0,0 +1,98 @@
+package noise_test
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/nikitakuchur/testpoint/internal/comparator"
+	"github.com/nikitakuchur/testpoint/internal/io/readers/respreader"
+	"github.com/nikitakuchur/testpoint/internal/noise"
+	testutils "github.com/nikitakuchur/testpoint/internal/utils/testing"
+	"testing"
+)
+
+func toChan[T any](items ...T) <-chan T {
+	output := make(chan T, len(items))
+	for _, item := range items {
+		output <- item
+	}
+	close(output)
+	return output
+}
+
+func TestDetect(t *testing.T) {
+	primary := toChan(
+		respreader.RespRecord{ReqUrl: "http://a/users/1", ReqMethod: "GET", ReqHash: 1, RespStatus: "200",
+			RespBody: `{"id":1,"createdAt":"10:00","items":[{"id":"a","name":"x"},{"id":"b","name":"y"}],"meta":{}}`},
+		respreader.RespRecord{ReqUrl: "http://a/users/2", ReqMethod: "GET", ReqHash: 2, RespStatus: "200",
+			RespBody: `{"id":2,"createdAt":"11:00","items":[],"meta":{"total":1}}`},
+		respreader.RespRecord{ReqUrl: "http://a/health", ReqMethod: "GET", ReqHash: 3, RespStatus: "200", RespBody: "ok"},
+		respreader.RespRecord{ReqUrl: "http://a/random", ReqMethod: "GET", ReqHash: 4, RespStatus: "200", RespBody: "foo"},
+		respreader.RespRecord{ReqUrl: "http://a/failed", ReqMethod: "GET", ReqHash: 5, RespError: "timeout"},
+	)
+	secondary := toChan(
+		respreader.RespRecord{ReqUrl: "http://b/users/1", ReqMethod: "GET", ReqHash: 1, RespStatus: "200",
+			RespBody: `{"id":1,"createdAt":"10:01","items":[{"id":"c","name":"x"},{"id":"b","name":"y"}],"meta":{}}`},
+		respreader.RespRecord{ReqUrl: "http://b/users/2", ReqMethod: "GET", ReqHash: 2, RespStatus: "200",
+			RespBody: `{"id":2,"createdAt":"11:00","items":[],"meta":null}`},
+		respreader.RespRecord{ReqUrl: "http://b/health", ReqMethod: "GET", ReqHash: 3, RespStatus: "503", RespBody: "ok"},
+		respreader.RespRecord{ReqUrl: "http://b/random", ReqMethod: "GET", ReqHash: 4, RespStatus: "200", RespBody: "bar"},
+		respreader.RespRecord{ReqUrl: "http://b/failed", ReqMethod: "GET", ReqHash: 5, RespStatus: "200", RespBody: "ok"},
+	)
+
+	n := noise.Detect(primary, secondary)
+
+	expected := map[string][]string{
+		"GET /users/:id": {"body.createdAt", "body.items[*].id", "body.meta", "body.meta.total"},
+		"GET /health":    {"status"},
+		"GET /random":    {"body"},
+	}
+	if diff := cmp.Diff(expected, n.Paths()); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	n := noise.New()
+	n.Add(
+		respreader.RespRecord{ReqUrl: "http://a/users/1", ReqMethod: "GET", RespStatus: "200", RespBody: `{"a":1,"b":{"c":[1,2]},"d":[{"e":1,"f":1}]}`},
+		respreader.RespRecord{ReqUrl: "http://a/users/1", ReqMethod: "GET", RespStatus: "200", RespBody: `{"a":2,"b":{"c":[1]},"d":[{"e":2,"f":1}]}`},
+	)
+
+	rec := respreader.RespRecord{ReqUrl: "http://c/users/5", ReqMethod: "GET", RespStatus: "200", RespBody: `{"a":3,"b":{"c":[3],"g":1},"d":[{"e":3,"f":2}]}`}
+	actual := n.Remove(rec, "GET /users/:id")
+	if actual.RespBody != `{"b":{"c":[],"g":1},"d":[{"f":2}]}` {
+		t.Errorf("incorrect body: %v", actual.RespBody)
+	}
+	if other := n.Remove(rec, "GET /other"); other != rec {
+		t.Errorf("the record of another endpoint has been changed: %v", other)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	n := noise.New()
+	n.Add(
+		respreader.RespRecord{ReqUrl: "http://a/users/1", ReqMethod: "GET", RespStatus: "200", RespBody: `{"id":1,"time":"10:00"}`},
+		respreader.RespRecord{ReqUrl: "http://b/users/1", ReqMethod: "GET", RespStatus: "200", RespBody: `{"id":1,"time":"10:01"}`},
+	)
+
+	comp := comparator.NewDefaultComparator(false)
+	records1 := toChan(
+		respreader.RespRecord{ReqUrl: "http://a/users/2", ReqMethod: "GET", ReqHash: 2, RespStatus: "200", RespBody: `{"id":2,"time":"12:00"}`},
+		respreader.RespRecord{ReqUrl: "http://a/users/3", ReqMethod: "GET", ReqHash: 3, RespStatus: "200", RespBody: `{"id":3,"time":"12:00"}`},
+	)
+	records2 := toChan(
+		respreader.RespRecord{ReqUrl: "http://c/users/2", ReqMethod: "GET", ReqHash: 2, RespStatus: "200", RespBody: `{"id":2,"time":"12:05"}`},
+		respreader.RespRecord{ReqUrl: "http://c/users/3", ReqMethod: "GET", ReqHash: 3, RespStatus: "200", RespBody: `{"id":4,"time":"12:05"}`},
+	)
+
+	diffs := testutils.ChanToSlice(n.Filter(comparator.CompareResponses(records1, records2, 0, comp, 1), comp))
+
+	if len(diffs) != 1 {
+		t.Fatalf("incorrect number of mismatches: expected 1, got %v", len(diffs))
+	}
+	if diffs[0].Rec1.ReqHash != 3 || diffs[0].Rec1.RespBody != `{"id":3,"time":"12:00"}` {
+		t.Errorf("incorrect mismatch: %v", diffs[0])
+	}
+	if n.Suppressed() != 1 {
+		t.Errorf("incorrect number of suppressed mismatches: expected 1, got %v", n.Suppressed())
+	}
+}
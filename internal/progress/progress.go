@@ -0,0 +1,336 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Progress keeps track of how far a command has got and reports it periodically.
+// On a terminal, it's a line that is redrawn in place, otherwise it's a log line every interval.
+type Progress struct {
+	mu       sync.Mutex
+	unit     string
+	out      io.Writer
+	live     bool
+	interval time.Duration
+
+	start      time.Time
+	total      int
+	done       int
+	mismatches int
+	// showMismatches is set for the commands that compare something
+	showMismatches bool
+	targets        map[string]*targetStats
+	targetOrder    []string
+
+	logOutput io.Writer
+	stop      chan struct{}
+	stopped   chan struct{}
+}
+
+// targetStats counts the outcomes for a target: the responses by status class and the errors.
+type targetStats struct {
+	classes [6]int
+	other   int
+	errors  int
+}
+
+// Option configures the progress.
+type Option func(p *Progress)
+
+// WithOutput sets where the progress is written, it's the standard error by default.
+// The progress is shown as a live line only if the output is a terminal.
+func WithOutput(out io.Writer) Option {
+	return func(p *Progress) {
+		p.out = out
+	}
+}
+
+// WithInterval sets how often the progress is reported.
+func WithInterval(interval time.Duration) Option {
+	return func(p *Progress) {
+		p.interval = interval
+	}
+}
+
+// WithMismatches makes the progress show the number of mismatches.
+func WithMismatches() Option {
+	return func(p *Progress) {
+		p.showMismatches = true
+	}
+}
+
+// New creates a progress that counts the input in the given units, e.g. records.
+func New(unit string, opts ...Option) *Progress {
+	p := &Progress{unit: unit, out: os.Stderr, total: -1, targets: make(map[string]*targetStats)}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.live = isTerminal(p.out)
+	if p.interval == 0 {
+		if p.live {
+			p.interval = 500 * time.Millisecond
+		} else {
+			p.interval = 10 * time.Second
+		}
+	}
+	return p
+}
+
+// SetTotal sets the total size of the input, so the progress can estimate the remaining time.
+func (p *Progress) SetTotal(total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total = total
+}
+
+// Add counts the processed input.
+func (p *Progress) Add(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done += n
+}
+
+// Finish counts the rest of the input as processed, it's called when the rest of it turned out to need no processing.
+func (p *Progress) Finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done = max(p.done, p.total)
+}
+
+// Result counts the outcome for the target: either the response status or the kind of the error.
+func (p *Progress) Result(target string, status string, err string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats, ok := p.targets[target]
+	if !ok {
+		stats = &targetStats{}
+		p.targets[target] = stats
+		p.targetOrder = append(p.targetOrder, target)
+	}
+	switch {
+	case err != "":
+		stats.errors++
+	case len(status) == 3 && status[0] >= '1' && status[0] <= '5':
+		stats.classes[status[0]-'0']++
+	default:
+		stats.other++
+	}
+}
+
+// Mismatch counts a mismatch.
+func (p *Progress) Mismatch() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.mismatches++
+}
+
+// Start starts reporting the progress until Stop is called.
+// On a terminal, the log output is redirected through the progress, so the log lines don't break the live line.
+func (p *Progress) Start() {
+	p.mu.Lock()
+	p.start = time.Now()
+	p.stop = make(chan struct{})
+	p.stopped = make(chan struct{})
+	if p.live {
+		p.logOutput = log.Writer()
+		log.SetOutput(logWriter{p})
+	}
+	p.mu.Unlock()
+
+	go func() {
+		defer close(p.stopped)
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.report()
+			}
+		}
+	}()
+}
+
+// Stop stops reporting and shows the final progress.
+func (p *Progress) Stop() {
+	close(p.stop)
+	<-p.stopped
+
+	p.report()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.live {
+		_, _ = fmt.Fprintln(p.out)
+		log.SetOutput(p.logOutput)
+	}
+}
+
+func (p *Progress) report() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.live {
+		p.draw()
+		return
+	}
+	log.Print(p.logLine())
+	for _, target := range p.targetOrder {
+		log.Printf("progress: target=%v %v", target, p.targets[target].fields())
+	}
+}
+
+// draw redraws the live line, the mutex must be held.
+func (p *Progress) draw() {
+	_, _ = fmt.Fprint(p.out, "\r\033[K"+p.liveLine())
+}
+
+// clear removes the live line, the mutex must be held.
+func (p *Progress) clear() {
+	_, _ = fmt.Fprint(p.out, "\r\033[K")
+}
+
+func (p *Progress) liveLine() string {
+	parts := []string{p.doneString()}
+	elapsed := time.Since(p.start)
+	parts = append(parts, fmt.Sprintf("%.1f %v/s", rate(p.done, elapsed), p.unit))
+	if eta, ok := p.eta(elapsed); ok {
+		parts = append(parts, "ETA "+eta.String())
+	}
+	if p.showMismatches {
+		parts = append(parts, fmt.Sprintf("%v mismatches", p.mismatches))
+	}
+	line := strings.Join(parts, ", ")
+	for _, target := range p.targetOrder {
+		line += " | " + target + ": " + p.targets[target].String()
+	}
+	return line
+}
+
+func (p *Progress) logLine() string {
+	elapsed := time.Since(p.start)
+	line := fmt.Sprintf("progress: %v=%v", p.unit, p.done)
+	if p.total >= 0 {
+		line += fmt.Sprintf(" total=%v percent=%.1f", p.total, percent(p.done, p.total))
+	}
+	line += fmt.Sprintf(" rate=%.1f/s", rate(p.done, elapsed))
+	if eta, ok := p.eta(elapsed); ok {
+		line += " eta=" + eta.String()
+	}
+	if p.showMismatches {
+		line += fmt.Sprintf(" mismatches=%v", p.mismatches)
+	}
+	return line
+}
+
+func (p *Progress) doneString() string {
+	if p.total < 0 {
+		return fmt.Sprintf("%v %v", p.done, p.unit)
+	}
+	return fmt.Sprintf("%v/%v %v (%.1f%%)", p.done, p.total, p.unit, percent(p.done, p.total))
+}
+
+// eta estimates the remaining time from the average rate so far.
+func (p *Progress) eta(elapsed time.Duration) (time.Duration, bool) {
+	if p.total < 0 || p.done == 0 {
+		return 0, false
+	}
+	remaining := max(p.total-p.done, 0)
+	eta := time.Duration(float64(elapsed) * float64(remaining) / float64(p.done))
+	return eta.Round(time.Second), true
+}
+
+func rate(done int, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(done) / elapsed.Seconds()
+}
+
+func percent(done int, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return min(float64(done)/float64(total)*100, 100)
+}
+
+func (s *targetStats) String() string {
+	var parts []string
+	for class, n := range s.classes {
+		if n != 0 {
+			parts = append(parts, fmt.Sprintf("%v %vxx", n, class))
+		}
+	}
+	if s.other != 0 {
+		parts = append(parts, fmt.Sprintf("%v other", s.other))
+	}
+	parts = append(parts, fmt.Sprintf("%v errors", s.errors))
+	return strings.Join(parts, ", ")
+}
+
+func (s *targetStats) fields() string {
+	var fields []string
+	for class, n := range s.classes {
+		if n != 0 {
+			fields = append(fields, fmt.Sprintf("%vxx=%v", class, n))
+		}
+	}
+	if s.other != 0 {
+		fields = append(fields, fmt.Sprintf("other=%v", s.other))
+	}
+	fields = append(fields, fmt.Sprintf("errors=%v", s.errors))
+	return strings.Join(fields, " ")
+}
+
+// logWriter writes the log lines above the live line.
+type logWriter struct {
+	p *Progress
+}
+
+func (w logWriter) Write(b []byte) (int, error) {
+	w.p.mu.Lock()
+	defer w.p.mu.Unlock()
+
+	w.p.clear()
+	n, err := w.p.logOutput.Write(b)
+	w.p.draw()
+	return n, err
+}
+
+// isTerminal checks whether the output is a terminal rather than a file or a pipe.
+func isTerminal(out io.Writer) bool {
+	f, ok := out.(*os.File)
+	if !ok {
+		return false
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
+
+// Tap calls the function for each item that goes through the channel.
+func Tap[T any](input <-chan T, f func(T)) <-chan T {
+	output := make(chan T)
+
+	go func() {
+		defer close(output)
+
+		for item := range input {
+			f(item)
+			output <- item
+		}
+	}()
+
+	return output
+}
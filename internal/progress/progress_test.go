@@ -0,0 +1,94 @@
+package progress_test
+
+import (
+	"bytes"
+	"github.com/nikitakuchur/testpoint/internal/progress"
+	testutils "github.com/nikitakuchur/testpoint/internal/utils/testing"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgress(t *testing.T) {
+	var buf bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&buf)
+
+	// the output isn't a terminal, so the progress is logged
+	p := progress.New("records", progress.WithOutput(&bytes.Buffer{}), progress.WithInterval(time.Hour), progress.WithMismatches())
+	p.SetTotal(4)
+	p.Start()
+	p.Add(2)
+	p.Result("http://foo.com", "200", "")
+	p.Result("http://foo.com", "503", "")
+	p.Result("http://bar.com", "", "timeout")
+	p.Mismatch()
+	p.Stop()
+
+	actual := buf.String()
+	expected := []string{
+		"progress: records=2 total=4 percent=50.0 rate=",
+		"mismatches=1",
+		"progress: target=http://foo.com 2xx=1 5xx=1 errors=0",
+		"progress: target=http://bar.com errors=1",
+	}
+	for _, e := range expected {
+		if !strings.Contains(actual, e) {
+			t.Errorf("incorrect result: expected the output to contain '%v', got:\n%v", e, actual)
+		}
+	}
+}
+
+func TestProgressWithUnknownTotal(t *testing.T) {
+	var buf bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&buf)
+
+	p := progress.New("records", progress.WithOutput(&bytes.Buffer{}), progress.WithInterval(time.Hour))
+	p.Start()
+	p.Add(3)
+	p.Stop()
+
+	actual := buf.String()
+	if !strings.Contains(actual, "progress: records=3 rate=") || strings.Contains(actual, "eta=") || strings.Contains(actual, "mismatches=") {
+		t.Error("incorrect result: unexpected progress", actual)
+	}
+}
+
+func TestProgressWithFinish(t *testing.T) {
+	var buf bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&buf)
+
+	p := progress.New("records", progress.WithOutput(&bytes.Buffer{}), progress.WithInterval(time.Hour))
+	p.SetTotal(4)
+	p.Start()
+	p.Add(3)
+	p.Finish()
+	p.Stop()
+
+	actual := buf.String()
+	if !strings.Contains(actual, "progress: records=4 total=4 percent=100.0 rate=") {
+		t.Error("incorrect result: unexpected progress", actual)
+	}
+}
+
+func TestTap(t *testing.T) {
+	input := make(chan int)
+	go func() {
+		for i := 1; i <= 3; i++ {
+			input <- i
+		}
+		close(input)
+	}()
+
+	sum := 0
+	actual := testutils.ChanToSlice(progress.Tap(input, func(i int) {
+		sum += i
+	}))
+
+	if len(actual) != 3 || sum != 6 {
+		t.Errorf("incorrect result: expected 3 items with the sum of 6, got %v and %v", actual, sum)
+	}
+}
@@ -0,0 +1,87 @@
+package progress
+
+import (
+	"sync"
+)
+
+// Records counts the records as processed only when all of their responses have arrived, not when they are read,
+// so the rate and the estimated time describe the targets rather than the reader, which can run ahead of them.
+// The records must get their requests in the order they were read.
+type Records struct {
+	mu       sync.Mutex
+	progress *Progress
+	// read has the hashes of the records that have been read, but haven't got their requests yet
+	read []uint64
+	// pending has the records that are waiting for their responses
+	pending map[uint64]*pendingRecord
+}
+
+type pendingRecord struct {
+	// records is the number of the records with the same hash, since the same records can be in the input more than once
+	records   int
+	responses int
+}
+
+func NewRecords(p *Progress) *Records {
+	return &Records{progress: p, pending: make(map[uint64]*pendingRecord)}
+}
+
+// Read registers the record that has just been read.
+func (r *Records) Read(hash uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.read = append(r.read, hash)
+}
+
+// Requested registers the number of the responses the record with the given hash is waiting for.
+// The records that were read before it and haven't got any requests, for example, because they were filtered out,
+// are counted as processed right away.
+func (r *Records) Requested(hash uint64, responses int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for len(r.read) > 0 {
+		h := r.read[0]
+		r.read = r.read[1:]
+		if h == hash {
+			break
+		}
+		r.progress.Add(1)
+	}
+	if responses <= 0 {
+		r.progress.Add(1)
+		return
+	}
+
+	rec, ok := r.pending[hash]
+	if !ok {
+		rec = &pendingRecord{}
+		r.pending[hash] = rec
+	}
+	rec.records++
+	rec.responses += responses
+}
+
+// Received counts a response to the record with the given hash.
+func (r *Records) Received(hash uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rec, ok := r.pending[hash]
+	if !ok {
+		return
+	}
+	rec.responses--
+	if rec.responses <= 0 {
+		r.progress.Add(rec.records)
+		delete(r.pending, hash)
+	}
+}
+
+// Finish counts the rest of the read records that haven't got any requests, it's called when there are no more requests.
+func (r *Records) Finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.progress.Add(len(r.read))
+	r.read = nil
+}
@@ -0,0 +1,38 @@
+package progress_test
+
+import (
+	"bytes"
+	"github.com/nikitakuchur/testpoint/internal/progress"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecords(t *testing.T) {
+	var buf bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&buf)
+
+	p := progress.New("records", progress.WithOutput(&bytes.Buffer{}), progress.WithInterval(time.Hour))
+	p.Start()
+
+	records := progress.NewRecords(p)
+	for _, hash := range []uint64{1, 2, 3, 4} {
+		records.Read(hash)
+	}
+	// the second record has no requests, so it's processed once the third one gets its requests
+	records.Requested(1, 2)
+	records.Requested(3, 2)
+	records.Received(1)
+	records.Received(1)
+	// the third record is still waiting for one more response
+	records.Received(3)
+	records.Finish()
+	p.Stop()
+
+	actual := buf.String()
+	if !strings.Contains(actual, "progress: records=3 rate=") {
+		t.Error("incorrect result: unexpected progress", actual)
+	}
+}
@@ -0,0 +1,272 @@
+package proxy
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/nikitakuchur/testpoint/internal/comparator"
+	"github.com/nikitakuchur/testpoint/internal/io/readers/respreader"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Config contains the settings of the proxy.
+type Config struct {
+	// Primary is the URL of the target whose responses are returned to the callers.
+	Primary string
+	// Candidates are the URLs of the targets the requests are mirrored to.
+	Candidates []string
+	// QueueSize is the maximum number of requests waiting to be sent to the candidates,
+	// the requests that don't fit into the queue are not mirrored.
+	QueueSize int
+}
+
+// Proxy is a reverse proxy that sends the incoming requests to the primary target and returns its responses.
+// The same requests are sent to the candidates in the background, so the callers never wait for them.
+type Proxy struct {
+	sender sender.Sender
+	conf   Config
+
+	// shadow has the requests that have been answered by the primary target and need to be sent to the candidates
+	shadow  chan sender.RequestResponse
+	seq     atomic.Uint64
+	dropped atomic.Uint64
+
+	// mu protects the shadow channel from being closed while a request is put in it
+	mu     sync.RWMutex
+	closed bool
+}
+
+func New(s sender.Sender, conf Config) *Proxy {
+	return &Proxy{sender: s, conf: conf, shadow: make(chan sender.RequestResponse, conf.QueueSize)}
+}
+
+// hopHeaders are the headers that only make sense for a single connection, so they aren't forwarded.
+// The Host header is set by the targets, and the Accept-Encoding header is left out,
+// so the responses are decompressed before they are compared.
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization", "Proxy-Connection",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade", "Host", "Content-Length", "Accept-Encoding",
+}
+
+func (p *Proxy) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(rw, "cannot read the request body", http.StatusBadRequest)
+		return
+	}
+
+	hash := p.seq.Add(1)
+	req := newRequest(p.conf.Primary, r, string(body), hash)
+	resp := p.sender.SendRequest(r.Context(), req)
+	// the caller has gone away, so there's nothing to compare
+	if r.Context().Err() != nil {
+		return
+	}
+	// the queue never blocks, so the request is queued before the response is written, and it's never lost on Close
+	p.enqueue(sender.RequestResponse{Request: req, Response: resp})
+	writeResponse(rw, resp)
+}
+
+// newRequest creates a request to the given target, the path and the query of the incoming request are added to the target URL.
+func newRequest(target string, r *http.Request, body string, hash uint64) sender.Request {
+	headers := r.Header.Clone()
+	for _, h := range hopHeaders {
+		headers.Del(h)
+	}
+	return sender.Request{
+		Url:     strings.TrimSuffix(target, "/") + r.URL.RequestURI(),
+		Method:  r.Method,
+		Headers: sender.HeadersToJson(headers),
+		Body:    body,
+		UserUrl: target,
+		Hash:    hash,
+	}
+}
+
+// enqueue puts the request in the queue of the requests for the candidates, unless the queue is full.
+func (p *Proxy) enqueue(rr sender.RequestResponse) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return
+	}
+	select {
+	case p.shadow <- rr:
+	default:
+		if p.dropped.Add(1) == 1 {
+			log.Println("the queue of the requests for the candidates is full, some of the requests will not be compared")
+		}
+	}
+}
+
+// Close stops mirroring the requests, the requests in the queue are still sent to the candidates.
+func (p *Proxy) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		close(p.shadow)
+	}
+}
+
+// Dropped returns the number of requests that weren't sent to the candidates because the queue was full.
+func (p *Proxy) Dropped() uint64 {
+	return p.dropped.Load()
+}
+
+// Compare sends the requests from the queue to the candidates and compares their responses with the responses of the primary target.
+// The output is closed after the proxy has been closed and all the requests in the queue have been compared.
+// The context is used for the requests to the candidates.
+func (p *Proxy) Compare(ctx context.Context, comp comparator.Comparator, workers int) <-chan comparator.RespDiff {
+	input := make(chan sender.Request)
+	responses := p.sender.SendRequests(ctx, input, workers)
+
+	primary := make(map[string]chan respreader.RespRecord, len(p.conf.Candidates))
+	candidates := make(map[string]chan respreader.RespRecord, len(p.conf.Candidates))
+	var diffs []<-chan comparator.RespDiff
+	for _, c := range p.conf.Candidates {
+		primary[c] = make(chan respreader.RespRecord)
+		candidates[c] = make(chan respreader.RespRecord)
+		diffs = append(diffs, comparator.CompareResponses(primary[c], candidates[c], 0, comp, workers))
+	}
+
+	go func() {
+		defer close(input)
+		defer closeAll(primary)
+		for rr := range p.shadow {
+			rec := toRecord(rr)
+			for _, c := range p.conf.Candidates {
+				req := rr.Request
+				req.Url = strings.TrimSuffix(c, "/") + strings.TrimPrefix(req.Url, strings.TrimSuffix(p.conf.Primary, "/"))
+				req.UserUrl = c
+				input <- req
+				primary[c] <- rec
+			}
+		}
+	}()
+
+	go func() {
+		defer closeAll(candidates)
+		for rr := range responses {
+			candidates[rr.Request.UserUrl] <- toRecord(rr)
+		}
+	}()
+
+	return merge(diffs)
+}
+
+func closeAll(chans map[string]chan respreader.RespRecord) {
+	for _, c := range chans {
+		close(c)
+	}
+}
+
+func merge(inputs []<-chan comparator.RespDiff) <-chan comparator.RespDiff {
+	output := make(chan comparator.RespDiff)
+	var wg sync.WaitGroup
+	for _, input := range inputs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for diff := range input {
+				output <- diff
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(output)
+	}()
+	return output
+}
+
+// writeResponse returns the response of the primary target to the caller.
+// If the request has failed, the caller gets 502 Bad Gateway, or 504 Gateway Timeout if it has timed out.
+func writeResponse(rw http.ResponseWriter, resp sender.Response) {
+	if resp.Error != "" {
+		status := http.StatusBadGateway
+		if resp.Error == sender.ErrorTimeout {
+			status = http.StatusGatewayTimeout
+		}
+		http.Error(rw, resp.ErrorMessage, status)
+		return
+	}
+
+	body := []byte(resp.Body)
+	if resp.BodyEncoding == sender.BodyEncodingBase64 {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(resp.Body); err != nil {
+			http.Error(rw, "invalid response body", http.StatusBadGateway)
+			return
+		}
+	}
+	status, err := strconv.Atoi(resp.Status)
+	if err != nil {
+		http.Error(rw, "invalid response status", http.StatusBadGateway)
+		return
+	}
+
+	// the original headers are used, since the JSON ones have the values of the repeated headers joined,
+	// and it would break the headers like Set-Cookie
+	for k, values := range resp.RawHeaders {
+		for _, v := range values {
+			rw.Header().Add(k, v)
+		}
+	}
+	for _, h := range hopHeaders {
+		rw.Header().Del(h)
+	}
+	rw.WriteHeader(status)
+	_, _ = rw.Write(body)
+}
+
+func parseHeaders(headers string) http.Header {
+	var headersMap map[string]string
+	_ = json.Unmarshal([]byte(headers), &headersMap)
+	result := make(http.Header, len(headersMap))
+	for k, v := range headersMap {
+		result.Set(k, v)
+	}
+	return result
+}
+
+// toRecord converts the request and its response to the record the comparator works with.
+func toRecord(rr sender.RequestResponse) respreader.RespRecord {
+	req, resp := rr.Request, rr.Response
+	return respreader.RespRecord{
+		ReqUrl:     req.Url,
+		ReqMethod:  req.Method,
+		ReqHeaders: req.Headers,
+		ReqBody:    req.Body,
+		ReqHash:    req.Hash,
+
+		RespStatus:       resp.Status,
+		RespBody:         resp.Body,
+		RespAttempts:     resp.Attempts,
+		RespError:        resp.Error,
+		RespErrorMessage: resp.ErrorMessage,
+
+		RespBodyEncoding:  resp.BodyEncoding,
+		RespBodyTruncated: resp.BodyTruncated,
+		RespBodyDigest:    resp.BodyDigest,
+
+		RespHeaders:         resp.Headers,
+		RespProto:           resp.Proto,
+		RespTimeToFirstByte: resp.TimeToFirstByte,
+		RespDuration:        resp.Duration,
+		RespSize:            resp.Size,
+		RespContentEncoding: resp.ContentEncoding,
+		RespTLSVersion:      resp.TLSVersion,
+		RespTLSCipher:       resp.TLSCipher,
+		RespRedirects:       resp.Redirects,
+		RespSkew:            resp.Skew,
+		RespTrailers:        resp.Trailers,
+	}
+}
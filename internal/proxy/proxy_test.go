@@ -0,0 +1,144 @@
+package proxy_test
+
+import (
+	"context"
+	"github.com/google/go-cmp/cmp"
+	"github.com/nikitakuchur/testpoint/internal/comparator"
+	"github.com/nikitakuchur/testpoint/internal/proxy"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTarget starts a server that responds with the method, the path and the body of the request.
+// The responses to the /users path get the given suffix, so the targets can differ.
+func newTarget(t *testing.T, suffix string) string {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		rw.Header().Set("X-Test", req.Header.Get("X-Test"))
+		if req.URL.Path == "/missing" {
+			rw.WriteHeader(http.StatusNotFound)
+		}
+		resp := req.Method + " " + req.URL.RequestURI() + " " + string(body)
+		if req.URL.Path == "/users" {
+			resp += suffix
+		}
+		_, _ = rw.Write([]byte(resp))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestProxy(t *testing.T) {
+	primary := newTarget(t, "")
+	candidate := newTarget(t, " new")
+
+	p := proxy.New(sender.NewSender(), proxy.Config{Primary: primary, Candidates: []string{candidate}, QueueSize: 10})
+	diffs := p.Compare(context.Background(), comparator.NewDefaultComparator(false), 2)
+
+	server := httptest.NewServer(p)
+	defer server.Close()
+
+	req, _ := http.NewRequest("POST", server.URL+"/users?id=1", strings.NewReader("foo"))
+	req.Header.Set("X-Test", "bar")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "POST /users?id=1 foo" || resp.Header.Get("X-Test") != "bar" {
+		t.Errorf("incorrect response: %v %q %v", resp.StatusCode, body, resp.Header)
+	}
+
+	resp, err = http.Get(server.URL + "/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("incorrect status: expected %v, got %v", http.StatusNotFound, resp.StatusCode)
+	}
+
+	p.Close()
+	var actual []comparator.RespDiff
+	for diff := range diffs {
+		actual = append(actual, diff)
+	}
+
+	if len(actual) != 1 {
+		t.Fatalf("incorrect result: expected 1 mismatch, got %v", len(actual))
+	}
+	if actual[0].Rec1.ReqUrl != primary+"/users?id=1" || actual[0].Rec2.ReqUrl != candidate+"/users?id=1" {
+		t.Errorf("incorrect URLs: %v and %v", actual[0].Rec1.ReqUrl, actual[0].Rec2.ReqUrl)
+	}
+	if _, ok := actual[0].Diffs["body"]; !ok {
+		t.Errorf("incorrect result: expected the body to differ, got %v", actual[0].Diffs)
+	}
+}
+
+func TestProxyWithRepeatedHeaders(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Add("Set-Cookie", "a=1; Path=/")
+		rw.Header().Add("Set-Cookie", "b=2; Expires=Wed, 21 Oct 2026 07:28:00 GMT")
+	}))
+	defer primary.Close()
+
+	p := proxy.New(sender.NewSender(), proxy.Config{Primary: primary.URL, QueueSize: 10})
+	server := httptest.NewServer(p)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	expected := []string{"a=1; Path=/", "b=2; Expires=Wed, 21 Oct 2026 07:28:00 GMT"}
+	if diff := cmp.Diff(expected, resp.Header.Values("Set-Cookie")); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestProxyWithUnavailablePrimary(t *testing.T) {
+	p := proxy.New(
+		sender.NewSender(sender.WithRetryPolicy(sender.RetryPolicy{MaxAttempts: 1})),
+		proxy.Config{Primary: "http://127.0.0.1:1", QueueSize: 10},
+	)
+	server := httptest.NewServer(p)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("incorrect status: expected %v, got %v", http.StatusBadGateway, resp.StatusCode)
+	}
+}
+
+func TestProxyWithFullQueue(t *testing.T) {
+	primary := newTarget(t, "")
+
+	p := proxy.New(sender.NewSender(), proxy.Config{Primary: primary, Candidates: []string{primary}, QueueSize: 1})
+	server := httptest.NewServer(p)
+	defer server.Close()
+
+	// nobody takes the requests from the queue, so only the first one fits
+	for i := 0; i < 3; i++ {
+		resp, err := http.Get(server.URL + "/users")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	if p.Dropped() != 2 {
+		t.Errorf("incorrect result: expected 2 dropped requests, got %v", p.Dropped())
+	}
+}
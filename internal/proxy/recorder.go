@@ -0,0 +1,154 @@
+package proxy
+
+import (
+	"github.com/nikitakuchur/testpoint/internal/io/readers/reqreader"
+	"github.com/nikitakuchur/testpoint/internal/io/writers/reqwriter"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// RecordFields are the fields of the recorded requests, the default transformation understands them.
+var RecordFields = []string{"url", "method", "headers", "body"}
+
+// redacted replaces the values of the redacted headers.
+const redacted = "REDACTED"
+
+// RecorderConfig contains the settings of the recorder.
+type RecorderConfig struct {
+	// Upstream is the URL the requests are forwarded to. If it's empty, the recorder works as a forward proxy,
+	// and the requests are sent to the URLs they have.
+	Upstream string
+	// Redact are the names of the headers whose values are replaced in the recorded requests.
+	Redact []string
+	// Methods are the methods of the requests that are recorded, empty means all the methods.
+	Methods []string
+	// Paths are the patterns of the paths of the requests that are recorded, empty means all the paths.
+	Paths []*regexp.Regexp
+}
+
+// Recorder is a proxy that forwards the requests to the upstream and records the ones that pass the filters.
+// The recorded requests can be sent with the send command later, and the responses can be kept as a baseline.
+type Recorder struct {
+	sender sender.Sender
+	conf   RecorderConfig
+	writer *reqwriter.Writer
+	count  atomic.Uint64
+
+	// mu makes sure the records are written one at a time, and the responses aren't sent after Close
+	mu        sync.Mutex
+	responses chan<- sender.RequestResponse
+}
+
+// NewRecorder creates a recorder that writes the requests with the given writer.
+// If the responses channel isn't nil, the responses of the upstream are sent to it along with the recorded requests.
+func NewRecorder(s sender.Sender, conf RecorderConfig, writer *reqwriter.Writer, responses chan<- sender.RequestResponse) *Recorder {
+	return &Recorder{sender: s, conf: conf, writer: writer, responses: responses}
+}
+
+func (rec *Recorder) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	target, url, ok := rec.target(rw, r)
+	if !ok {
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(rw, "cannot read the request body", http.StatusBadRequest)
+		return
+	}
+
+	req := newRequest(target, r, string(body), 0)
+	resp := rec.sender.SendRequest(r.Context(), req)
+
+	if rec.matches(r) {
+		rec.record(req, url, resp, r.Context().Err() == nil)
+	}
+	if r.Context().Err() == nil {
+		writeResponse(rw, resp)
+	}
+}
+
+// target finds out where the request needs to be sent, and which URL is recorded.
+// The reverse proxy records only the path and the query, so the requests can be sent to any target later.
+func (rec *Recorder) target(rw http.ResponseWriter, r *http.Request) (string, string, bool) {
+	if rec.conf.Upstream != "" {
+		return rec.conf.Upstream, r.URL.RequestURI(), true
+	}
+	if r.Method == http.MethodConnect {
+		http.Error(rw, "HTTPS requests cannot be recorded by a forward proxy, use the upstream URL instead", http.StatusNotImplemented)
+		return "", "", false
+	}
+	if !r.URL.IsAbs() {
+		http.Error(rw, "the request must have an absolute URL to be sent through a forward proxy", http.StatusBadRequest)
+		return "", "", false
+	}
+	return r.URL.Scheme + "://" + r.URL.Host, r.URL.String(), true
+}
+
+// matches checks whether the request passes the method and path filters.
+func (rec *Recorder) matches(r *http.Request) bool {
+	if len(rec.conf.Methods) != 0 && !slices.ContainsFunc(rec.conf.Methods, func(m string) bool {
+		return strings.EqualFold(m, r.Method)
+	}) {
+		return false
+	}
+	if len(rec.conf.Paths) != 0 && !slices.ContainsFunc(rec.conf.Paths, func(p *regexp.Regexp) bool {
+		return p.MatchString(r.URL.Path)
+	}) {
+		return false
+	}
+	return true
+}
+
+// record writes the request and sends its response to the baseline.
+// The record hash is the one the request gets when it's read from the file, so the baseline can be compared
+// with the responses collected by the send command.
+func (rec *Recorder) record(req sender.Request, url string, resp sender.Response, withResponse bool) {
+	req.Headers = rec.redact(req.Headers)
+	values := []string{url, req.Method, req.Headers, req.Body}
+	req.Hash = reqreader.Hash(reqreader.ReqRecord{Fields: RecordFields, Values: values})
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if err := rec.writer.Write(values); err != nil {
+		log.Fatalln("cannot write the request:", err)
+	}
+	rec.count.Add(1)
+	if withResponse && rec.responses != nil {
+		rec.responses <- sender.RequestResponse{Request: req, Response: resp}
+	}
+}
+
+func (rec *Recorder) redact(headers string) string {
+	if headers == "" || len(rec.conf.Redact) == 0 {
+		return headers
+	}
+	h := parseHeaders(headers)
+	for _, name := range rec.conf.Redact {
+		if h.Get(name) != "" {
+			h.Set(name, redacted)
+		}
+	}
+	return sender.HeadersToJson(h)
+}
+
+// Close stops sending the responses, it must be called after all the requests have been handled.
+func (rec *Recorder) Close() {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.responses != nil {
+		close(rec.responses)
+		rec.responses = nil
+	}
+}
+
+// Count returns the number of the recorded requests.
+func (rec *Recorder) Count() uint64 {
+	return rec.count.Load()
+}
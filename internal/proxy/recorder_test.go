@@ -0,0 +1,122 @@
+package proxy_test
+
+import (
+	"context"
+	"github.com/google/go-cmp/cmp"
+	"github.com/nikitakuchur/testpoint/internal/io/readers/reqreader"
+	"github.com/nikitakuchur/testpoint/internal/io/writers/reqwriter"
+	"github.com/nikitakuchur/testpoint/internal/proxy"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	testutils "github.com/nikitakuchur/testpoint/internal/utils/testing"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func startRecorder(t *testing.T, conf proxy.RecorderConfig, responses chan<- sender.RequestResponse) (*proxy.Recorder, string, string) {
+	filename := filepath.Join(t.TempDir(), "requests.csv")
+	w, err := reqwriter.NewWriter(filename, proxy.RecordFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+
+	rec := proxy.NewRecorder(sender.NewSender(), conf, w, responses)
+	server := httptest.NewServer(rec)
+	t.Cleanup(server.Close)
+	return rec, server.URL, filename
+}
+
+func TestRecorder(t *testing.T) {
+	upstream := newTarget(t, "")
+	responses := make(chan sender.RequestResponse, 10)
+	rec, recorderUrl, filename := startRecorder(t, proxy.RecorderConfig{
+		Upstream: upstream,
+		Redact:   []string{"authorization"},
+		Methods:  []string{"post", "PUT"},
+		Paths:    []*regexp.Regexp{regexp.MustCompile(`^/users`)},
+	}, responses)
+
+	send := func(method string, path string, body string) string {
+		req, _ := http.NewRequest(method, recorderUrl+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return string(data)
+	}
+
+	if actual := send("POST", "/users?id=1", "foo"); actual != "POST /users?id=1 foo" {
+		t.Errorf("incorrect response: %v", actual)
+	}
+	// the requests that don't pass the filters are still forwarded
+	if actual := send("GET", "/users", ""); actual != "GET /users " {
+		t.Errorf("incorrect response: %v", actual)
+	}
+	send("POST", "/orders", "bar")
+	rec.Close()
+
+	records := testutils.ChanToSlice(reqreader.ReadRequests(context.Background(), filename, true, 0))
+	expected := []reqreader.ReqRecord{{
+		Fields: proxy.RecordFields,
+		Values: []string{"/users?id=1", "POST", `{"Authorization":"REDACTED","User-Agent":"Go-http-client/1.1"}`, "foo"},
+	}}
+	expected[0].Hash = reqreader.Hash(expected[0])
+	if diff := cmp.Diff(expected, records); diff != "" {
+		t.Error(diff)
+	}
+	if rec.Count() != 1 {
+		t.Errorf("incorrect result: expected 1 recorded request, got %v", rec.Count())
+	}
+
+	baseline := testutils.ChanToSlice(responses)
+	if len(baseline) != 1 {
+		t.Fatalf("incorrect result: expected 1 response, got %v", len(baseline))
+	}
+	if baseline[0].Request.Url != upstream+"/users?id=1" || baseline[0].Request.Hash != records[0].Hash {
+		t.Errorf("incorrect request: %+v", baseline[0].Request)
+	}
+	if baseline[0].Response.Body != "POST /users?id=1 foo" {
+		t.Errorf("incorrect response: %+v", baseline[0].Response)
+	}
+}
+
+func TestRecorderAsForwardProxy(t *testing.T) {
+	upstream := newTarget(t, "")
+	_, recorderUrl, filename := startRecorder(t, proxy.RecorderConfig{}, nil)
+
+	proxyUrl, _ := url.Parse(recorderUrl)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyUrl)}}
+	resp, err := client.Get(upstream + "/users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(data) != "GET /users " {
+		t.Errorf("incorrect response: %v", string(data))
+	}
+
+	records := testutils.ChanToSlice(reqreader.ReadRequests(context.Background(), filename, true, 0))
+	if len(records) != 1 || records[0].Values[0] != upstream+"/users" {
+		t.Errorf("incorrect result: %v", records)
+	}
+
+	// the request to the recorder itself doesn't say where it needs to go
+	resp, err = http.Get(recorderUrl + "/users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("incorrect status: expected %v, got %v", http.StatusBadRequest, resp.StatusCode)
+	}
+}
@@ -0,0 +1,117 @@
+package repeats
+
+import (
+	"github.com/nikitakuchur/testpoint/internal/comparator"
+	"github.com/nikitakuchur/testpoint/internal/endpoint"
+	"github.com/nikitakuchur/testpoint/internal/io/readers/respreader"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"github.com/nikitakuchur/testpoint/internal/strdiff"
+	"sync/atomic"
+)
+
+// NonDeterministicField is the name of the field that marks the mismatches of the non-deterministic requests.
+const NonDeterministicField = "non-deterministic"
+
+// NonDeterministic keeps the requests whose responses differ across the repeats on the same target.
+// Such endpoints give different responses even when nothing has changed, so their mismatches can't be trusted.
+type NonDeterministic struct {
+	// records has the first response to each non-deterministic request
+	records  map[uint64]respreader.RespRecord
+	excluded atomic.Uint64
+}
+
+func New() *NonDeterministic {
+	return &NonDeterministic{records: make(map[uint64]respreader.RespRecord)}
+}
+
+// Check compares the repeated responses from the same target with the first one using the given comparator,
+// and keeps the requests whose responses differ. It returns whether there were any repeated responses,
+// and the number of non-deterministic requests that were found.
+func (n *NonDeterministic) Check(records <-chan respreader.RespRecord, comp comparator.Comparator) (bool, int) {
+	first := make(map[uint64]respreader.RespRecord)
+	repeated := false
+	found := make(map[uint64]bool)
+	for rec := range records {
+		if rec.ReqRepeat > 0 {
+			repeated = true
+		}
+		prev, ok := first[rec.ReqHash]
+		if !ok {
+			first[rec.ReqHash] = rec
+			continue
+		}
+		if found[rec.ReqHash] {
+			continue
+		}
+		if len(comparator.CompareRecords(prev, rec, comp)) != 0 {
+			found[rec.ReqHash] = true
+			if _, ok := n.records[rec.ReqHash]; !ok {
+				n.records[rec.ReqHash] = prev
+			}
+		}
+	}
+	return repeated, len(found)
+}
+
+// Contains checks whether the request with the given hash is non-deterministic.
+func (n *NonDeterministic) Contains(hash uint64) bool {
+	_, ok := n.records[hash]
+	return ok
+}
+
+// Groups returns the number of non-deterministic requests per endpoint.
+func (n *NonDeterministic) Groups() map[string]int {
+	groups := make(map[string]int)
+	for _, rec := range n.records {
+		groups[endpoint.Group(sender.Request{Url: rec.ReqUrl, Method: rec.ReqMethod, Body: rec.ReqBody})]++
+	}
+	return groups
+}
+
+// Filter removes the mismatches of the non-deterministic requests, or marks them if annotate is true.
+func (n *NonDeterministic) Filter(input <-chan comparator.RespDiff, annotate bool) <-chan comparator.RespDiff {
+	output := make(chan comparator.RespDiff)
+
+	go func() {
+		defer close(output)
+
+		for diff := range input {
+			if !n.Contains(diff.Rec1.ReqHash) {
+				output <- diff
+				continue
+			}
+			if !annotate {
+				n.excluded.Add(1)
+				continue
+			}
+			diff.Diffs[NonDeterministicField] = []strdiff.Diff{
+				{Operation: strdiff.DiffEqual, Text: "the responses differ across the repeats on the same target"},
+			}
+			output <- diff
+		}
+	}()
+
+	return output
+}
+
+// Excluded returns the number of mismatches that were removed by the filter.
+func (n *NonDeterministic) Excluded() uint64 {
+	return n.excluded.Load()
+}
+
+// FirstOnly passes on only the responses to the first repeat of each request.
+func FirstOnly(input <-chan respreader.RespRecord) <-chan respreader.RespRecord {
+	output := make(chan respreader.RespRecord)
+
+	go func() {
+		defer close(output)
+
+		for rec := range input {
+			if rec.ReqRepeat == 0 {
+				output <- rec
+			}
+		}
+	}()
+
+	return output
+}
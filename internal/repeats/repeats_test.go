@@ -0,0 +1,100 @@
+package repeats_test
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/nikitakuchur/testpoint/internal/comparator"
+	"github.com/nikitakuchur/testpoint/internal/io/readers/respreader"
+	"github.com/nikitakuchur/testpoint/internal/repeats"
+	"github.com/nikitakuchur/testpoint/internal/strdiff"
+	testutils "github.com/nikitakuchur/testpoint/internal/utils/testing"
+	"testing"
+)
+
+func toChan[T any](items ...T) <-chan T {
+	output := make(chan T, len(items))
+	for _, item := range items {
+		output <- item
+	}
+	close(output)
+	return output
+}
+
+func TestCheck(t *testing.T) {
+	n := repeats.New()
+	repeated, found := n.Check(toChan(
+		respreader.RespRecord{ReqUrl: "http://a/users/1", ReqMethod: "GET", ReqHash: 1, RespStatus: "200", RespBody: `{"a":1,"b":2}`},
+		respreader.RespRecord{ReqUrl: "http://a/users/2", ReqMethod: "GET", ReqHash: 2, RespStatus: "200", RespBody: "foo"},
+		respreader.RespRecord{ReqUrl: "http://a/users/1", ReqMethod: "GET", ReqHash: 1, ReqRepeat: 1, RespStatus: "200", RespBody: `{"b":2,"a":1}`},
+		respreader.RespRecord{ReqUrl: "http://a/users/2", ReqMethod: "GET", ReqHash: 2, ReqRepeat: 1, RespStatus: "200", RespBody: "bar"},
+		respreader.RespRecord{ReqUrl: "http://a/users/2", ReqMethod: "GET", ReqHash: 2, ReqRepeat: 2, RespStatus: "200", RespBody: "baz"},
+		respreader.RespRecord{ReqUrl: "http://a/health", ReqMethod: "GET", ReqHash: 3, RespStatus: "200", RespBody: "ok"},
+		respreader.RespRecord{ReqUrl: "http://a/health", ReqMethod: "GET", ReqHash: 3, ReqRepeat: 1, RespError: "timeout"},
+	), comparator.NewDefaultComparator(false))
+
+	if !repeated || found != 2 {
+		t.Errorf("incorrect result: repeated %v, found %v", repeated, found)
+	}
+	if n.Contains(1) || !n.Contains(2) || !n.Contains(3) {
+		t.Error("incorrect non-deterministic requests")
+	}
+	expected := map[string]int{"GET /users/:id": 1, "GET /health": 1}
+	if diff := cmp.Diff(expected, n.Groups()); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestCheckWithNoRepeats(t *testing.T) {
+	n := repeats.New()
+	repeated, found := n.Check(toChan(
+		respreader.RespRecord{ReqUrl: "http://a/users/1", ReqMethod: "GET", ReqHash: 1, RespStatus: "200", RespBody: "foo"},
+		respreader.RespRecord{ReqUrl: "http://a/users/2", ReqMethod: "GET", ReqHash: 2, RespStatus: "200", RespBody: "bar"},
+	), comparator.NewDefaultComparator(false))
+
+	if repeated || found != 0 {
+		t.Errorf("incorrect result: repeated %v, found %v", repeated, found)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	newDiffs := func() <-chan comparator.RespDiff {
+		return toChan(
+			comparator.RespDiff{Rec1: respreader.RespRecord{ReqHash: 1}, Diffs: map[string][]strdiff.Diff{"body": nil}},
+			comparator.RespDiff{Rec1: respreader.RespRecord{ReqHash: 2}, Diffs: map[string][]strdiff.Diff{"body": nil}},
+		)
+	}
+
+	n := repeats.New()
+	n.Check(toChan(
+		respreader.RespRecord{ReqHash: 2, RespStatus: "200", RespBody: "foo"},
+		respreader.RespRecord{ReqHash: 2, ReqRepeat: 1, RespStatus: "200", RespBody: "bar"},
+	), comparator.NewDefaultComparator(false))
+
+	excluded := testutils.ChanToSlice(n.Filter(newDiffs(), false))
+	if len(excluded) != 1 || excluded[0].Rec1.ReqHash != 1 || n.Excluded() != 1 {
+		t.Errorf("incorrect result: %v, excluded %v", excluded, n.Excluded())
+	}
+
+	annotated := testutils.ChanToSlice(n.Filter(newDiffs(), true))
+	if len(annotated) != 2 {
+		t.Fatalf("incorrect number of mismatches: expected 2, got %v", len(annotated))
+	}
+	if _, ok := annotated[0].Diffs[repeats.NonDeterministicField]; ok {
+		t.Error("the deterministic request was annotated")
+	}
+	if _, ok := annotated[1].Diffs[repeats.NonDeterministicField]; !ok {
+		t.Error("the non-deterministic request wasn't annotated")
+	}
+}
+
+func TestFirstOnly(t *testing.T) {
+	actual := testutils.ChanToSlice(repeats.FirstOnly(toChan(
+		respreader.RespRecord{ReqHash: 1},
+		respreader.RespRecord{ReqHash: 1, ReqRepeat: 1},
+		respreader.RespRecord{ReqHash: 2},
+	)))
+
+	expected := []respreader.RespRecord{{ReqHash: 1}, {ReqHash: 2}}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,118 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask is the string that replaces secret values in logs and output files.
+const Mask = "*****"
+
+// placeholderRegexp also matches percent-encoded braces, because placeholders in URL paths get escaped.
+var placeholderRegexp = regexp.MustCompile(`\$(?:\{|%7[Bb])([^${}%]+)(?:}|%7[Dd])`)
+
+var registry = struct {
+	mu       sync.RWMutex
+	values   map[string]struct{}
+	replacer *strings.Replacer
+}{values: map[string]struct{}{}}
+
+// Expand replaces all the ${ENV_VAR} and ${file:/path/to/secret} placeholders in the given string
+// with the value of the environment variable or the content of the file respectively.
+// Every substituted value is considered a secret and will be hidden by MaskSecrets.
+func Expand(s string) (string, error) {
+	return ExpandFunc(s, nil)
+}
+
+// ExpandFunc works the same way as Expand, but passes every substituted value through the given escape function.
+// It can be used when the placeholder is a part of a structured value, for example, a JSON string.
+func ExpandFunc(s string, escape func(string) string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var expandErr error
+	result := placeholderRegexp.ReplaceAllStringFunc(s, func(placeholder string) string {
+		name := placeholderRegexp.FindStringSubmatch(placeholder)[1]
+		value, err := resolve(name)
+		if err != nil {
+			if expandErr == nil {
+				expandErr = err
+			}
+			return placeholder
+		}
+		register(value)
+		if escape != nil {
+			value = escape(value)
+			register(value)
+		}
+		return value
+	})
+
+	if expandErr != nil {
+		return "", expandErr
+	}
+	return result, nil
+}
+
+func resolve(name string) (string, error) {
+	if path, ok := strings.CutPrefix(name, "file:"); ok {
+		bytes, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("cannot read the secret file '%v': %w", path, err)
+		}
+		// files with secrets usually end with a newline, and it's never a part of the secret
+		return strings.TrimRight(string(bytes), "\r\n"), nil
+	}
+
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable '%v' is not set", name)
+	}
+	return value, nil
+}
+
+func register(value string) {
+	if value == "" {
+		return
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if _, ok := registry.values[value]; ok {
+		return
+	}
+	registry.values[value] = struct{}{}
+
+	values := make([]string, 0, len(registry.values))
+	for v := range registry.values {
+		values = append(values, v)
+	}
+	// longer values go first, so a secret that contains another secret is masked as a whole
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+
+	oldnew := make([]string, 0, 2*len(values))
+	for _, v := range values {
+		oldnew = append(oldnew, v, Mask)
+	}
+	registry.replacer = strings.NewReplacer(oldnew...)
+}
+
+// MaskSecrets replaces all the secret values that have been expanded so far with a mask.
+func MaskSecrets(s string) string {
+	registry.mu.RLock()
+	replacer := registry.replacer
+	registry.mu.RUnlock()
+
+	if replacer == nil {
+		return s
+	}
+	return replacer.Replace(s)
+}
@@ -0,0 +1,83 @@
+package secrets_test
+
+import (
+	"github.com/nikitakuchur/testpoint/internal/secrets"
+	testutils "github.com/nikitakuchur/testpoint/internal/utils/testing"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	t.Setenv("TESTPOINT_TOKEN", "qwerty")
+	filename := testutils.CreateTempFile(t.TempDir(), "secret", "s3cr3t\n")
+
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"no_placeholders", "http://test.com/api/test", "http://test.com/api/test"},
+		{"env_variable", "Bearer ${TESTPOINT_TOKEN}", "Bearer qwerty"},
+		{"file", "key=${file:" + filename + "}", "key=s3cr3t"},
+		{"multiple", "${TESTPOINT_TOKEN}:${file:" + filename + "}", "qwerty:s3cr3t"},
+		{"escaped_braces", "/api/$%7BTESTPOINT_TOKEN%7D", "/api/qwerty"},
+		{"dollar_sign", "price: $100", "price: $100"},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			actual, err := secrets.Expand(d.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != d.expected {
+				t.Errorf("incorrect result: expected %v, got %v", d.expected, actual)
+			}
+		})
+	}
+}
+
+func TestExpandWithMissingValues(t *testing.T) {
+	inputs := []string{
+		"${TESTPOINT_MISSING_VARIABLE}",
+		"${file:/testpoint/missing/file}",
+	}
+	for _, input := range inputs {
+		_, err := secrets.Expand(input)
+		if err == nil {
+			t.Errorf("incorrect result: expected an error for %v", input)
+		}
+	}
+}
+
+func TestExpandFunc(t *testing.T) {
+	t.Setenv("TESTPOINT_QUOTED", `a"b`)
+
+	actual, err := secrets.ExpandFunc(`{"header":"${TESTPOINT_QUOTED}"}`, func(s string) string {
+		return `a\"b`
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"header":"a\"b"}`
+	if actual != expected {
+		t.Errorf("incorrect result: expected %v, got %v", expected, actual)
+	}
+}
+
+func TestMaskSecrets(t *testing.T) {
+	t.Setenv("TESTPOINT_PASSWORD", "p4ssw0rd")
+	t.Setenv("TESTPOINT_LONG_PASSWORD", "p4ssw0rd-123")
+
+	_, err := secrets.Expand("${TESTPOINT_PASSWORD} ${TESTPOINT_LONG_PASSWORD}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := secrets.MaskSecrets("http://test.com/api/test?password=p4ssw0rd-123&other=p4ssw0rd")
+
+	expected := "http://test.com/api/test?password=" + secrets.Mask + "&other=" + secrets.Mask
+	if actual != expected {
+		t.Errorf("incorrect result: expected %v, got %v", expected, actual)
+	}
+}
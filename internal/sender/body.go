@@ -0,0 +1,109 @@
+package sender
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"mime"
+	"strings"
+	"unicode/utf8"
+)
+
+// BodyEncodingBase64 is the encoding of the bodies that aren't text, so they can be safely stored in CSV files.
+const BodyEncodingBase64 = "base64"
+
+// body is the response body as we keep it, along with the information about the whole body.
+type body struct {
+	data      []byte
+	size      int
+	digest    string
+	truncated bool
+}
+
+// readBody reads the whole body, but only keeps the first maxSize bytes of it, zero means there's no limit.
+// The digest is calculated over the whole body, so the bodies can be compared even if they were truncated.
+func readBody(r io.Reader, maxSize int) (body, error) {
+	hash := sha256.New()
+	buf := &limitedBuffer{maxSize: maxSize}
+	n, err := io.Copy(io.MultiWriter(hash, buf), r)
+	if err != nil {
+		return body{}, err
+	}
+
+	data := buf.Bytes()
+	if buf.truncated {
+		data = trimIncompleteRune(data)
+	}
+	return body{data, int(n), hex.EncodeToString(hash.Sum(nil)), buf.truncated}, nil
+}
+
+// encode converts the body to a string, the bodies that aren't text are encoded with base64.
+// It returns the encoded body and the name of the encoding, which is empty for text.
+func (b body) encode(contentType string) (string, string) {
+	if isText(contentType, b.data) {
+		return string(b.data), ""
+	}
+	return base64.StdEncoding.EncodeToString(b.data), BodyEncodingBase64
+}
+
+// DecodeBody converts the body back to bytes according to its encoding.
+func DecodeBody(body string, encoding string) ([]byte, error) {
+	if encoding == BodyEncodingBase64 {
+		return base64.StdEncoding.DecodeString(body)
+	}
+	return []byte(body), nil
+}
+
+// isText checks whether the body is text that can be stored as is.
+func isText(contentType string, data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+	if strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript", "application/x-www-form-urlencoded",
+		"application/graphql", "application/x-ndjson", "application/yaml":
+		return true
+	}
+	return false
+}
+
+// trimIncompleteRune removes the last rune if it was cut in the middle.
+func trimIncompleteRune(data []byte) []byte {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return data[:i]
+			}
+			break
+		}
+	}
+	return data
+}
+
+// limitedBuffer is a buffer that silently drops everything after the first maxSize bytes.
+type limitedBuffer struct {
+	bytes.Buffer
+	maxSize   int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.maxSize > 0 && b.Len()+len(p) > b.maxSize {
+		b.truncated = true
+		_, _ = b.Buffer.Write(p[:b.maxSize-b.Len()])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,72 @@
+package sender_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSendRequestsWithBodies(t *testing.T) {
+	binary := []byte{0x89, 'P', 'N', 'G', 0x00, 0xff}
+	text := strings.Repeat("Привет!", 10)
+
+	data := []struct {
+		name              string
+		contentType       string
+		body              []byte
+		maxBodySize       int
+		expectedBody      string
+		expectedEncoding  string
+		expectedTruncated bool
+	}{
+		{"text", "text/plain; charset=utf-8", []byte(text), 0, text, "", false},
+		{"json", "application/problem+json", []byte(`{"a":1}`), 0, `{"a":1}`, "", false},
+		{"binary", "image/png", binary, 0, "iVBORwD/", sender.BodyEncodingBase64, false},
+		{"binary_without_content_type", "", binary, 0, "iVBORwD/", sender.BodyEncodingBase64, false},
+		{"utf8_with_binary_content_type", "application/octet-stream", []byte("foo"), 0, "Zm9v", sender.BodyEncodingBase64, false},
+		// the limit falls in the middle of the second letter, which takes two bytes
+		{"truncated_text", "text/plain", []byte(text), 3, "П", "", true},
+		{"truncated_binary", "image/png", binary, 4, "iVBORw==", sender.BodyEncodingBase64, true},
+		{"body_within_limit", "text/plain", []byte("foo"), 3, "foo", "", false},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header()["Content-Type"] = []string{d.contentType}
+				_, _ = rw.Write(d.body)
+			}))
+			defer server.Close()
+
+			resp := sendOneRequest(server.URL, sender.TargetConfig{MaxBodySize: d.maxBodySize})
+
+			digest := sha256.Sum256(d.body)
+			if resp.Body != d.expectedBody || resp.BodyEncoding != d.expectedEncoding || resp.BodyTruncated != d.expectedTruncated {
+				t.Errorf("incorrect result: expected body '%v' (encoding '%v', truncated %v), got '%v' (encoding '%v', truncated %v)",
+					d.expectedBody, d.expectedEncoding, d.expectedTruncated, resp.Body, resp.BodyEncoding, resp.BodyTruncated)
+			}
+			if resp.BodyDigest != hex.EncodeToString(digest[:]) || resp.Size != len(d.body) {
+				t.Errorf("incorrect result: expected the digest and the size of the whole body, got %v and %v", resp.BodyDigest, resp.Size)
+			}
+		})
+	}
+}
+
+func TestDecodeBody(t *testing.T) {
+	actual, err := sender.DecodeBody("iVBORwD/", sender.BodyEncodingBase64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(actual) != "\x89PNG\x00\xff" {
+		t.Errorf("incorrect result: expected the PNG signature, got %q", actual)
+	}
+
+	actual, _ = sender.DecodeBody("foo", "")
+	if string(actual) != "foo" {
+		t.Errorf("incorrect result: expected foo, got %q", actual)
+	}
+}
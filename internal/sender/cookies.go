@@ -0,0 +1,132 @@
+package sender
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cookie is a cookie along with the URL that it's set for.
+type Cookie struct {
+	Url    *url.URL
+	Cookie *http.Cookie
+}
+
+// LoadCookieFile reads cookies from a file in the Netscape format, the one that curl and browser extensions use.
+func LoadCookieFile(filename string) ([]Cookie, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open the cookie file: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var cookies []Cookie
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		httpOnly := false
+		if rest, ok := strings.CutPrefix(line, "#HttpOnly_"); ok {
+			line, httpOnly = rest, true
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		cookie, err := parseCookieLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%v:%v: %w", filename, lineNum, err)
+		}
+		cookie.Cookie.HttpOnly = httpOnly
+		cookies = append(cookies, cookie)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read the cookie file: %w", err)
+	}
+	return cookies, nil
+}
+
+// parseCookieLine parses a line with the following tab-separated fields:
+// domain, include subdomains, path, secure, expiration time, name, and value.
+func parseCookieLine(line string) (Cookie, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) != 7 {
+		return Cookie{}, fmt.Errorf("expected 7 tab-separated fields, got %v", len(fields))
+	}
+
+	expiration, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil {
+		return Cookie{}, fmt.Errorf("invalid expiration time: %w", err)
+	}
+
+	host := strings.TrimPrefix(fields[0], ".")
+	secure := strings.EqualFold(fields[3], "TRUE")
+	scheme := "http"
+	if secure {
+		scheme = "https"
+	}
+
+	cookie := &http.Cookie{
+		Name:   fields[5],
+		Value:  fields[6],
+		Path:   fields[2],
+		Secure: secure,
+	}
+	// without the domain, the cookie is only sent to the host that set it
+	if strings.EqualFold(fields[1], "TRUE") {
+		cookie.Domain = host
+	}
+	// zero means it's a session cookie
+	if expiration > 0 {
+		cookie.Expires = time.Unix(expiration, 0)
+	}
+
+	return Cookie{&url.URL{Scheme: scheme, Host: host, Path: fields[2]}, cookie}, nil
+}
+
+// cookieJars keeps the cookies of a target.
+// The requests that don't belong to any session share the same jar, while each session has its own one.
+type cookieJars struct {
+	initial []Cookie
+
+	mu       sync.Mutex
+	shared   http.CookieJar
+	sessions map[string]http.CookieJar
+}
+
+func newCookieJars(initial []Cookie) *cookieJars {
+	return &cookieJars{initial: initial, shared: newCookieJar(initial), sessions: make(map[string]http.CookieJar)}
+}
+
+func (j *cookieJars) jar(session string) http.CookieJar {
+	if session == "" {
+		return j.shared
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	jar, ok := j.sessions[session]
+	if !ok {
+		jar = newCookieJar(j.initial)
+		j.sessions[session] = jar
+	}
+	return jar
+}
+
+func newCookieJar(initial []Cookie) http.CookieJar {
+	// it never returns an error
+	jar, _ := cookiejar.New(nil)
+	for _, c := range initial {
+		jar.SetCookies(c.Url, []*http.Cookie{c.Cookie})
+	}
+	return jar
+}
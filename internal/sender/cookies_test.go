@@ -0,0 +1,143 @@
+package sender_test
+
+import (
+	"context"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	testutils "github.com/nikitakuchur/testpoint/internal/utils/testing"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+const cookieFile = `# Netscape HTTP Cookie File
+
+.test.com	TRUE	/	FALSE	0	theme	dark
+#HttpOnly_secure.test.com	FALSE	/api	TRUE	4102444800	session	qwerty
+`
+
+func TestLoadCookieFile(t *testing.T) {
+	filename := testutils.CreateTempFile(t.TempDir(), "cookies.txt", cookieFile)
+
+	cookies, err := sender.LoadCookieFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cookies) != 2 {
+		t.Fatal("incorrect result: expected number of cookies is 2, got", len(cookies))
+	}
+
+	theme := cookies[0]
+	if theme.Url.String() != "http://test.com/" || theme.Cookie.Domain != "test.com" ||
+		theme.Cookie.Name != "theme" || theme.Cookie.Value != "dark" || !theme.Cookie.Expires.IsZero() {
+		t.Errorf("incorrect result: unexpected cookie %v for %v", theme.Cookie, theme.Url)
+	}
+
+	session := cookies[1]
+	if session.Url.String() != "https://secure.test.com/api" || session.Cookie.Domain != "" ||
+		!session.Cookie.Secure || !session.Cookie.HttpOnly || !session.Cookie.Expires.Equal(time.Unix(4102444800, 0)) {
+		t.Errorf("incorrect result: unexpected cookie %v for %v", session.Cookie, session.Url)
+	}
+}
+
+func TestLoadCookieFileWithIncorrectLine(t *testing.T) {
+	filename := testutils.CreateTempFile(t.TempDir(), "cookies.txt", "test.com\tTRUE\t/\tFALSE\tnever\ttheme\tdark\n")
+
+	_, err := sender.LoadCookieFile(filename)
+	if err == nil {
+		t.Error("incorrect result: expected an error")
+	}
+}
+
+// newSessionServer starts a server that sets the user cookie on login and returns the user and the theme otherwise.
+func newSessionServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if user := req.URL.Query().Get("login"); user != "" {
+			http.SetCookie(rw, &http.Cookie{Name: "user", Value: user})
+			return
+		}
+		var values []string
+		for _, name := range []string{"user", "theme"} {
+			if c, err := req.Cookie(name); err == nil {
+				values = append(values, c.Value)
+			}
+		}
+		_, _ = rw.Write([]byte(strings.Join(values, ",")))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSendRequestsWithCookies(t *testing.T) {
+	server := newSessionServer(t)
+	serverUrl, _ := url.Parse(server.URL)
+
+	data := []struct {
+		name     string
+		conf     sender.TargetConfig
+		expected string
+	}{
+		{"disabled", sender.TargetConfig{}, ""},
+		{"enabled", sender.TargetConfig{Cookies: true}, "alice"},
+		{"initial_cookies", sender.TargetConfig{Cookies: true, InitialCookies: []sender.Cookie{{
+			Url:    serverUrl,
+			Cookie: &http.Cookie{Name: "theme", Value: "dark"},
+		}}}, "alice,dark"},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			requests := make(chan sender.Request)
+			go func() {
+				requests <- sender.Request{Url: server.URL + "?login=alice", Method: "GET", UserUrl: server.URL}
+				requests <- sender.Request{Url: server.URL, Method: "GET", UserUrl: server.URL}
+				close(requests)
+			}()
+
+			s := sender.NewSender(sender.WithTargetConfig(server.URL, d.conf))
+			actual := chanToSlice(s.SendRequests(context.Background(), requests, 1))
+
+			if actual[1].Response.Body != d.expected {
+				t.Errorf("incorrect result: expected '%v', got '%v'", d.expected, actual[1].Response.Body)
+			}
+		})
+	}
+}
+
+func TestSendRequestsWithSessions(t *testing.T) {
+	server := newSessionServer(t)
+
+	users := []string{"alice", "bob", "carol", "dave", "eve"}
+	requests := make(chan sender.Request)
+	go func() {
+		for _, user := range users {
+			requests <- sender.Request{Url: server.URL + "?login=" + user, Method: "GET", UserUrl: server.URL, Session: user}
+		}
+		for _, user := range users {
+			requests <- sender.Request{Url: server.URL, Method: "GET", UserUrl: server.URL, Session: user}
+		}
+		close(requests)
+	}()
+
+	s := sender.NewSender(sender.WithTargetConfig(server.URL, sender.TargetConfig{Cookies: true}))
+	actual := chanToSlice(s.SendRequests(context.Background(), requests, 3))
+
+	var sessions []string
+	for _, rr := range actual {
+		if rr.Request.Url != server.URL {
+			continue
+		}
+		if rr.Response.Body != rr.Request.Session {
+			t.Errorf("incorrect result: expected the cookie of %v, got '%v'", rr.Request.Session, rr.Response.Body)
+		}
+		sessions = append(sessions, rr.Request.Session)
+	}
+	sort.Strings(sessions)
+	if strings.Join(sessions, ",") != strings.Join(users, ",") {
+		t.Errorf("incorrect result: expected responses for %v, got %v", users, sessions)
+	}
+}
@@ -0,0 +1,149 @@
+package sender
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const schemeUnix = "unix"
+
+// unixHostSuffix marks the hosts that stand for Unix sockets, the rest of the host is the hex-encoded socket path.
+// Every socket gets its own host, so the connections to different sockets are never mixed up.
+const unixHostSuffix = ".unix.invalid"
+
+// unixHostHeader is the Host header of the requests sent to the unix URLs.
+const unixHostHeader = "localhost"
+
+// SplitUnixUrl splits a URL like unix:///var/run/app.sock:/api/users into the socket path, /var/run/app.sock,
+// and the HTTP URL, http://localhost/api/users. If there's no colon after the socket path, the HTTP path is empty.
+func SplitUnixUrl(u string) (socket string, httpUrl string, ok bool) {
+	rest, ok := strings.CutPrefix(u, schemeUnix+"://")
+	if !ok {
+		return "", "", false
+	}
+	socket, path, _ := strings.Cut(rest, ":")
+	if socket == "" {
+		return "", "", false
+	}
+	return socket, "http://" + unixHostHeader + path, true
+}
+
+// JoinUnixUrl is the opposite of SplitUnixUrl, only the path and the query of the HTTP URL are kept.
+func JoinUnixUrl(socket string, httpUrl string) (string, error) {
+	u, err := url.Parse(httpUrl)
+	if err != nil {
+		return "", err
+	}
+	path := u.EscapedPath()
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return schemeUnix + "://" + socket + ":" + path, nil
+}
+
+// unixRequestUrl turns the unix URL into an HTTP URL with the host that stands for the socket.
+func unixRequestUrl(u string) (string, bool) {
+	socket, httpUrl, ok := SplitUnixUrl(u)
+	if !ok {
+		return u, false
+	}
+	parsed, err := url.Parse(httpUrl)
+	if err != nil {
+		return u, false
+	}
+	parsed.Host = hex.EncodeToString([]byte(socket)) + unixHostSuffix
+	return parsed.String(), true
+}
+
+// unixSocket returns the socket path if the given host stands for a Unix socket.
+func unixSocket(host string) (string, bool) {
+	encoded, ok := strings.CutSuffix(host, unixHostSuffix)
+	if !ok {
+		return "", false
+	}
+	socket, err := hex.DecodeString(encoded)
+	if err != nil {
+		return "", false
+	}
+	return string(socket), true
+}
+
+// ParseResolve parses the entries in the host:port:address format, where the address is an IP address
+// the connections to host:port are made to. It returns a map from host:port to the address with the port.
+func ParseResolve(entries []string) (map[string]string, error) {
+	result := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid entry '%v', must be <host>:<port>:<address>", entry)
+		}
+		host, port, address := parts[0], parts[1], strings.Trim(parts[2], "[]")
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return nil, fmt.Errorf("invalid port in the entry '%v'", entry)
+		}
+		if net.ParseIP(address) == nil {
+			return nil, fmt.Errorf("invalid IP address in the entry '%v'", entry)
+		}
+		result[net.JoinHostPort(strings.ToLower(host), port)] = net.JoinHostPort(address, port)
+	}
+	return result, nil
+}
+
+// dialer makes the connections to a target. Instead of the address from the URL, it can connect
+// to a Unix socket or to the address the host is resolved to by the user.
+type dialer struct {
+	net.Dialer
+	unixSocket string
+	resolve    map[string]string
+}
+
+func newDialer(conf TargetConfig) *dialer {
+	d := &dialer{unixSocket: conf.UnixSocket, resolve: conf.Resolve}
+	d.Timeout = defaultConnectTimeout
+	if conf.Timeouts.Connect > 0 {
+		d.Timeout = conf.Timeouts.Connect
+	}
+	d.KeepAlive = defaultKeepAlive
+	return d
+}
+
+// redirected checks whether the connections to the given address don't go where the URL says.
+func (d *dialer) redirected(addr string) bool {
+	host, _, _ := net.SplitHostPort(addr)
+	_, isUnix := unixSocket(host)
+	_, isResolved := d.resolve[strings.ToLower(addr)]
+	return d.unixSocket != "" || isUnix || isResolved
+}
+
+func (d *dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		if socket, ok := unixSocket(host); ok {
+			return d.Dialer.DialContext(ctx, "unix", socket)
+		}
+	}
+	if d.unixSocket != "" {
+		return d.Dialer.DialContext(ctx, "unix", d.unixSocket)
+	}
+	if resolved, ok := d.resolve[strings.ToLower(addr)]; ok {
+		addr = resolved
+	}
+	return d.Dialer.DialContext(ctx, network, addr)
+}
+
+// canonicalAddr returns the host and the port of the URL, the port is taken from the scheme if it's not set.
+func canonicalAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		// gRPC uses 443 by default, even without TLS
+		if u.Scheme == "https" || u.Scheme == schemeGrpc || u.Scheme == schemeGrpcs {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
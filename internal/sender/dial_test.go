@@ -0,0 +1,142 @@
+package sender_test
+
+import (
+	"context"
+	"github.com/google/go-cmp/cmp"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// startUnixServer starts an HTTP server on a Unix socket, it responds with the Host header and the path of the request.
+func startUnixServer(t *testing.T) string {
+	// the socket paths are limited to about 100 bytes, and the temporary directories of the tests can be longer
+	dir, err := os.MkdirTemp("", "testpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "app.sock")
+
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(req.Host + " " + req.URL.RequestURI()))
+	}))
+	server.Listener = lis
+	server.Start()
+	t.Cleanup(server.Close)
+	return socket
+}
+
+func TestSendRequestsToUnixUrl(t *testing.T) {
+	socket := startUnixServer(t)
+	url := "unix://" + socket + ":/api/users?id=1"
+
+	resp := sendOneRequest(url, sender.TargetConfig{})
+
+	if resp.Error != "" || resp.Body != "localhost /api/users?id=1" {
+		t.Errorf("incorrect result: %+v", resp)
+	}
+}
+
+func TestSendRequestsWithUnixSocket(t *testing.T) {
+	socket := startUnixServer(t)
+
+	resp := sendOneRequest("http://app.test/api", sender.TargetConfig{UnixSocket: socket})
+
+	if resp.Error != "" || resp.Body != "app.test /api" {
+		t.Errorf("incorrect result: %+v", resp)
+	}
+}
+
+func TestSendRequestsWithResolve(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(req.Host))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	resolve, err := sender.ParseResolve([]string{"app.test:" + port + ":127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := sendOneRequest("http://app.test:"+port, sender.TargetConfig{Resolve: resolve})
+
+	if resp.Error != "" || resp.Body != "app.test:"+port {
+		t.Errorf("incorrect result: %+v", resp)
+	}
+}
+
+func TestSendRequestsWithHostHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(req.Host))
+	}))
+	defer server.Close()
+	socket := startUnixServer(t)
+
+	tests := []struct {
+		name     string
+		url      string
+		expected string
+	}{
+		{name: "regular target", url: server.URL, expected: strings.TrimPrefix(server.URL, "http://")},
+		{name: "unix target", url: "unix://" + socket + ":/api", expected: "old.example.com /api"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := sender.NewSender(sender.WithRetryPolicy(sender.RetryPolicy{MaxAttempts: 1}))
+			resp := s.SendRequest(context.Background(), sender.Request{
+				Url: test.url, Method: "GET", Headers: `{"Host":"old.example.com"}`, UserUrl: test.url,
+			})
+			if resp.Error != "" || resp.Body != test.expected {
+				t.Errorf("incorrect result: expected %q, got %+v", test.expected, resp)
+			}
+		})
+	}
+}
+
+func TestSplitUnixUrl(t *testing.T) {
+	tests := []struct {
+		url             string
+		expectedSocket  string
+		expectedHttpUrl string
+		expectedOk      bool
+	}{
+		{"unix:///var/run/app.sock:/api", "/var/run/app.sock", "http://localhost/api", true},
+		{"unix:///var/run/app.sock", "/var/run/app.sock", "http://localhost", true},
+		{"unix://app.sock:/api?id=1", "app.sock", "http://localhost/api?id=1", true},
+		{"unix://", "", "", false},
+		{"http://localhost/api", "", "", false},
+	}
+	for _, tt := range tests {
+		socket, httpUrl, ok := sender.SplitUnixUrl(tt.url)
+		if socket != tt.expectedSocket || httpUrl != tt.expectedHttpUrl || ok != tt.expectedOk {
+			t.Errorf("incorrect result for %v: got %v, %v, %v", tt.url, socket, httpUrl, ok)
+		}
+	}
+}
+
+func TestParseResolve(t *testing.T) {
+	actual, err := sender.ParseResolve([]string{"Example.com:443:10.0.0.1", "example.com:80:[::1]"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"example.com:443": "10.0.0.1:443", "example.com:80": "[::1]:80"}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+
+	for _, entry := range []string{"example.com:443", "example.com:port:10.0.0.1", "example.com:443:backend", ":443:10.0.0.1"} {
+		if _, err := sender.ParseResolve([]string{entry}); err == nil {
+			t.Errorf("incorrect result for %v: expected an error", entry)
+		}
+	}
+}
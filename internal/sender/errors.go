@@ -0,0 +1,64 @@
+package sender
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+)
+
+// The kinds of errors that are recorded instead of the responses when the requests fail.
+const (
+	ErrorTimeout        = "timeout"
+	ErrorTLS            = "tls"
+	ErrorDNS            = "dns"
+	ErrorConnection     = "connection"
+	ErrorInvalidRequest = "invalid_request"
+	ErrorAuth           = "auth"
+	ErrorUnknown        = "unknown"
+)
+
+// errAuth wraps the errors that happen when we can't get the credentials for a request.
+var errAuth = errors.New("cannot authenticate the request")
+
+// classifyError finds out the kind of the given error.
+// The kind is what we compare between the targets, since the error messages usually contain addresses and ports.
+func classifyError(err error) string {
+	// the token endpoint can fail in many ways, but for the comparison it only matters that the auth has failed
+	if errors.Is(err, errAuth) {
+		return ErrorAuth
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrorTimeout
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrorDNS
+	}
+
+	var recordHeaderErr tls.RecordHeaderError
+	var certVerificationErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certInvalidErr x509.CertificateInvalidError
+	var alertErr tls.AlertError
+	if errors.As(err, &recordHeaderErr) || errors.As(err, &certVerificationErr) ||
+		errors.As(err, &unknownAuthorityErr) || errors.As(err, &hostnameErr) ||
+		errors.As(err, &certInvalidErr) || errors.As(err, &alertErr) {
+		return ErrorTLS
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return ErrorConnection
+	}
+
+	return ErrorUnknown
+}
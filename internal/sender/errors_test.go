@@ -0,0 +1,39 @@
+package sender
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"syscall"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	data := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"deadline", &url.Error{Op: "Get", URL: "http://test.com", Err: context.DeadlineExceeded}, ErrorTimeout},
+		{"dns", &url.Error{Op: "Get", URL: "http://test.com", Err: &net.DNSError{Err: "no such host", Name: "test.com"}}, ErrorDNS},
+		{"unknown_authority", &url.Error{Op: "Get", URL: "https://test.com", Err: x509.UnknownAuthorityError{}}, ErrorTLS},
+		{"connection_refused", &url.Error{Op: "Get", URL: "http://test.com", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}, ErrorConnection},
+		{"connection_reset", fmt.Errorf("cannot read an http body: %w", syscall.ECONNRESET), ErrorConnection},
+		{"eof", &url.Error{Op: "Get", URL: "http://test.com", Err: io.EOF}, ErrorConnection},
+		{"auth", fmt.Errorf("%w: %w", errAuth, &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}), ErrorAuth},
+		{"unknown", errors.New("something went wrong"), ErrorUnknown},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			actual := classifyError(d.err)
+			if actual != d.expected {
+				t.Errorf("incorrect result: expected %v, got %v", d.expected, actual)
+			}
+		})
+	}
+}
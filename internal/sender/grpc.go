@@ -0,0 +1,511 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nikitakuchur/testpoint/internal/secrets"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The URL schemes of gRPC targets: grpc is plaintext HTTP/2, and grpcs is HTTP/2 over TLS.
+const (
+	schemeGrpc  = "grpc"
+	schemeGrpcs = "grpcs"
+)
+
+// isGrpcUrl checks whether the request needs to be sent as a gRPC call.
+func isGrpcUrl(u string) bool {
+	return strings.HasPrefix(u, schemeGrpc+"://") || strings.HasPrefix(u, schemeGrpcs+"://")
+}
+
+// LoadDescriptorSet reads the file descriptor set generated by protoc with the --descriptor_set_out and --include_imports flags.
+func LoadDescriptorSet(filename string) (*protoregistry.Files, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the descriptor set: %w", err)
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("cannot parse the descriptor set: %w", err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor set: %w", err)
+	}
+	return files, nil
+}
+
+// grpcClients keeps a connection for every gRPC server the target sends the requests to.
+type grpcClients struct {
+	mu      sync.Mutex
+	conf    TargetConfig
+	clients map[string]*grpcClient
+}
+
+func newGrpcClients(conf TargetConfig) *grpcClients {
+	return &grpcClients{conf: conf, clients: make(map[string]*grpcClient)}
+}
+
+// get returns the client for the scheme and the host of the given URL.
+// The connection is established lazily by the first call.
+func (c *grpcClients) get(u *url.URL) (*grpcClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := u.Scheme + "://" + u.Host
+	if client, ok := c.clients[key]; ok {
+		return client, nil
+	}
+
+	var creds credentials.TransportCredentials
+	if u.Scheme == schemeGrpcs {
+		tlsConfig := c.conf.TLSConfig
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	} else {
+		creds = insecure.NewCredentials()
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds), grpc.WithStatsHandler(grpcTimingHandler{})}
+	if c.conf.Timeouts.Connect > 0 {
+		opts = append(opts, grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           backoff.DefaultConfig,
+			MinConnectTimeout: c.conf.Timeouts.Connect,
+		}))
+	}
+
+	target := u.Host
+	// the name resolution of gRPC doesn't know about the sockets and the resolved addresses, so we dial them ourselves
+	if d := newDialer(c.conf); d.redirected(canonicalAddr(u)) {
+		target = "passthrough:///" + canonicalAddr(u)
+		opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return d.DialContext(ctx, "tcp", addr)
+		}))
+	}
+
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	client := &grpcClient{
+		conn:     conn,
+		files:    c.conf.Descriptors,
+		services: make(map[string]*serviceFiles),
+		methods:  make(map[string]protoreflect.MethodDescriptor),
+	}
+	c.clients[key] = client
+	return client, nil
+}
+
+// grpcClient calls the methods of a gRPC server.
+// The method descriptors are taken from the descriptor set, or from the server itself through the reflection service.
+type grpcClient struct {
+	conn *grpc.ClientConn
+
+	mu sync.Mutex
+	// files are the descriptors from the descriptor set, nil means the server reflection is used
+	files *protoregistry.Files
+	// services are the descriptors got through the reflection, including the ones that are still being got
+	services map[string]*serviceFiles
+	// methods are the descriptors of the methods that have been called so far
+	methods map[string]protoreflect.MethodDescriptor
+	// types resolve the messages packed into google.protobuf.Any, including the ones in the status details
+	types []*dynamicpb.Types
+}
+
+// serviceFiles are the descriptors of a service got through the reflection, done is closed once they're there.
+type serviceFiles struct {
+	done  chan struct{}
+	files *protoregistry.Files
+	err   error
+}
+
+// method finds the descriptor of the method with the given full name, e.g. /package.Service/Method.
+func (c *grpcClient) method(ctx context.Context, fullName string) (protoreflect.MethodDescriptor, error) {
+	c.mu.Lock()
+	md, ok := c.methods[fullName]
+	c.mu.Unlock()
+	if ok {
+		return md, nil
+	}
+
+	serviceName, methodName, ok := strings.Cut(strings.TrimPrefix(fullName, "/"), "/")
+	if !ok || serviceName == "" || methodName == "" {
+		return nil, fmt.Errorf("%w '%v', must be /<package>.<service>/<method>", errInvalidMethod, fullName)
+	}
+
+	files, err := c.serviceFiles(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := files.FindDescriptorByName(protoreflect.FullName(serviceName))
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot find the service %v: %w", errInvalidMethod, serviceName, err)
+	}
+	service, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%w: %v is not a service", errInvalidMethod, serviceName)
+	}
+	md = service.Methods().ByName(protoreflect.Name(methodName))
+	if md == nil {
+		return nil, fmt.Errorf("%w: the service %v has no method %v", errInvalidMethod, serviceName, methodName)
+	}
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		return nil, fmt.Errorf("%w: %v is a streaming method, only unary methods are supported", errInvalidMethod, fullName)
+	}
+
+	c.mu.Lock()
+	c.methods[fullName] = md
+	c.mu.Unlock()
+	return md, nil
+}
+
+// serviceFiles returns the descriptors the service can be found in.
+// The reflection is called without the lock, so a slow server doesn't hold back the calls of the methods we already know.
+// The concurrent callers of the same service wait for the first one, and the lookup is tried again if it has failed.
+func (c *grpcClient) serviceFiles(ctx context.Context, serviceName string) (*protoregistry.Files, error) {
+	c.mu.Lock()
+	if c.files != nil {
+		if len(c.types) == 0 {
+			c.types = append(c.types, dynamicpb.NewTypes(c.files))
+		}
+		c.mu.Unlock()
+		return c.files, nil
+	}
+	sf, ok := c.services[serviceName]
+	if !ok {
+		sf = &serviceFiles{done: make(chan struct{})}
+		c.services[serviceName] = sf
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		sf.files, sf.err = reflectFiles(ctx, c.conn, serviceName)
+		c.mu.Lock()
+		if sf.err != nil {
+			delete(c.services, serviceName)
+		} else {
+			c.types = append(c.types, dynamicpb.NewTypes(sf.files))
+		}
+		c.mu.Unlock()
+		close(sf.done)
+	}
+
+	select {
+	case <-sf.done:
+		return sf.files, sf.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// resolver returns the resolver of the message types known so far, the global registry is used as a fallback.
+func (c *grpcClient) resolver() grpcTypeResolver {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return grpcTypeResolver(c.types)
+}
+
+// errInvalidMethod wraps the errors that happen when the called method doesn't exist or can't be called.
+var errInvalidMethod = errors.New("invalid gRPC method")
+
+// grpcTypeResolver looks for the message types in the descriptors of the called services and then in the global registry.
+type grpcTypeResolver []*dynamicpb.Types
+
+func (r grpcTypeResolver) FindMessageByName(name protoreflect.FullName) (protoreflect.MessageType, error) {
+	for _, types := range r {
+		if mt, err := types.FindMessageByName(name); err == nil {
+			return mt, nil
+		}
+	}
+	return protoregistry.GlobalTypes.FindMessageByName(name)
+}
+
+func (r grpcTypeResolver) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	for _, types := range r {
+		if mt, err := types.FindMessageByURL(url); err == nil {
+			return mt, nil
+		}
+	}
+	return protoregistry.GlobalTypes.FindMessageByURL(url)
+}
+
+func (r grpcTypeResolver) FindExtensionByName(name protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	for _, types := range r {
+		if xt, err := types.FindExtensionByName(name); err == nil {
+			return xt, nil
+		}
+	}
+	return protoregistry.GlobalTypes.FindExtensionByName(name)
+}
+
+func (r grpcTypeResolver) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	for _, types := range r {
+		if xt, err := types.FindExtensionByNumber(message, field); err == nil {
+			return xt, nil
+		}
+	}
+	return protoregistry.GlobalTypes.FindExtensionByNumber(message, field)
+}
+
+// doSendGrpcRequest calls the unary gRPC method from the request URL with the JSON message from the body.
+// The status is recorded as the code name, and the body is the response message in the canonical JSON format,
+// or the google.rpc.Status message if the call has failed, so the responses can be compared as JSON.
+func (s Sender) doSendGrpcRequest(ctx context.Context, req Request, tm *timing) Response {
+	u, err := url.Parse(req.Url)
+	if err != nil {
+		return errorResponse(ErrorInvalidRequest, fmt.Errorf("cannot parse the gRPC url: %w", err), 0)
+	}
+	md, err := grpcMetadata(req.Headers)
+	if err != nil {
+		return errorResponse(ErrorInvalidRequest, err, 0)
+	}
+	tm.dispatched = time.Now()
+
+	t := s.target(req.UserUrl)
+	if err := t.acquire(ctx); err != nil {
+		return errorResponse(classifyError(err), err, 0)
+	}
+	defer t.release()
+
+	client, err := t.grpc.get(u)
+	if err != nil {
+		return errorResponse(ErrorInvalidRequest, fmt.Errorf("cannot create a gRPC client: %w", err), 0)
+	}
+	method, err := client.method(ctx, u.Path)
+	if err != nil {
+		return errorResponse(grpcErrorKind(err), err, 0)
+	}
+
+	in := dynamicpb.NewMessage(method.Input())
+	if req.Body != "" {
+		if err := (protojson.UnmarshalOptions{Resolver: client.resolver()}).Unmarshal([]byte(req.Body), in); err != nil {
+			return errorResponse(ErrorInvalidRequest, fmt.Errorf("cannot convert the body to %v: %w", method.Input().FullName(), err), 0)
+		}
+	}
+
+	out := dynamicpb.NewMessage(method.Output())
+	header, trailer, duration, attempts, err := s.doGrpcRequest(ctx, req, t, tm, client, u.Path, md, in, out)
+	st, ok := status.FromError(err)
+	if !ok || isGrpcError(ctx, st) {
+		return errorResponse(grpcErrorKind(err), err, attempts)
+	}
+
+	var message proto.Message = out
+	if st.Code() != codes.OK {
+		message = st.Proto()
+	}
+	data, err := marshalCanonicalJson(message, client.resolver())
+	if err != nil {
+		// the details of the status can't be converted without their types, but the code and the message still can
+		withoutDetails := st.Proto()
+		withoutDetails.Details = nil
+		data, err = marshalCanonicalJson(withoutDetails, client.resolver())
+	}
+	if err != nil {
+		return errorResponse(ErrorUnknown, fmt.Errorf("cannot convert the response to JSON: %w", err), attempts)
+	}
+
+	b, err := readBody(strings.NewReader(string(data)), t.maxBodySize)
+	if err != nil {
+		return errorResponse(ErrorUnknown, err, attempts)
+	}
+	body, bodyEncoding := b.encode("application/json")
+	return Response{
+		Status:          st.Code().String(),
+		Body:            body,
+		Attempts:        attempts,
+		BodyEncoding:    bodyEncoding,
+		BodyTruncated:   b.truncated,
+		BodyDigest:      b.digest,
+		Headers:         metadataToJson(header),
+		Trailers:        metadataToJson(trailer),
+		Proto:           "HTTP/2.0",
+		TimeToFirstByte: tm.firstByte.Sub(tm.start),
+		Duration:        duration,
+		Size:            b.size,
+	}
+}
+
+// doGrpcRequest calls the method and retries the call according to the retry policy.
+// Only the calls that have failed because the server is unavailable are retried,
+// since that's the gRPC counterpart of a connection error.
+func (s Sender) doGrpcRequest(ctx context.Context, req Request, t *target, tm *timing, client *grpcClient, method string, md metadata.MD, in proto.Message, out proto.Message) (metadata.MD, metadata.MD, time.Duration, int, error) {
+	policy := s.retryPolicy
+	maxAttempts := max(policy.MaxAttempts, 1)
+
+	for attempt := 1; ; attempt++ {
+		header, trailer, duration, err := t.invokeGrpc(ctx, req, tm, client, method, md, in, out)
+		if status.Code(err) != codes.Unavailable || attempt == maxAttempts || ctx.Err() != nil {
+			return header, trailer, duration, attempt, err
+		}
+
+		log.Print(secrets.MaskSecrets(fmt.Sprintf("%v, retry attempt=%v", err, attempt)))
+		if err := sleep(ctx, policy.delay(attempt, nil)); err != nil {
+			return nil, nil, 0, attempt, err
+		}
+	}
+}
+
+// invokeGrpc makes a single call with the credentials of the target.
+func (t *target) invokeGrpc(ctx context.Context, req Request, tm *timing, client *grpcClient, method string, md metadata.MD, in proto.Message, out proto.Message) (metadata.MD, metadata.MD, time.Duration, error) {
+	md = md.Copy()
+	if t.auth != nil {
+		// the auth providers work with HTTP requests, so the credentials are taken from the headers of a dummy one,
+		// its URL has the http or https scheme instead of grpc or grpcs
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.Replace(req.Url, schemeGrpc, "http", 1), nil)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		if err := t.auth.Apply(httpReq); err != nil {
+			return nil, nil, 0, fmt.Errorf("%w: %w", errAuth, err)
+		}
+		for k, v := range httpReq.Header {
+			md.Set(k, v...)
+		}
+	}
+
+	if err := t.wait(ctx); err != nil {
+		return nil, nil, 0, err
+	}
+	if t.grpcTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.grpcTimeout)
+		defer cancel()
+	}
+
+	var header, trailer metadata.MD
+	var firstByte atomic.Pointer[time.Time]
+	ctx = context.WithValue(metadata.NewOutgoingContext(ctx, md), firstByteKey{}, &firstByte)
+	tm.start = time.Now()
+	err := client.conn.Invoke(ctx, method, in, out, grpc.Header(&header), grpc.Trailer(&trailer))
+	duration := time.Since(tm.start)
+
+	// the responses without a message have no headers, the trailers are their first byte
+	tm.firstByte = tm.start.Add(duration)
+	if at := firstByte.Load(); at != nil {
+		tm.firstByte = *at
+	}
+	return header, trailer, duration, err
+}
+
+// firstByteKey is the context key of the time the response headers of a call have arrived.
+type firstByteKey struct{}
+
+// grpcTimingHandler records when the response headers of the calls arrive.
+// It's called from the goroutine that reads the connection, so the time is passed through an atomic pointer.
+type grpcTimingHandler struct{}
+
+func (grpcTimingHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (grpcTimingHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if _, ok := s.(*stats.InHeader); !ok {
+		return
+	}
+	if firstByte, ok := ctx.Value(firstByteKey{}).(*atomic.Pointer[time.Time]); ok {
+		now := time.Now()
+		firstByte.CompareAndSwap(nil, &now)
+	}
+}
+
+func (grpcTimingHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (grpcTimingHandler) HandleConn(context.Context, stats.ConnStats) {}
+
+// isGrpcError checks whether the status means that we couldn't get a response from the server at all.
+func isGrpcError(ctx context.Context, st *status.Status) bool {
+	switch st.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	case codes.Canceled:
+		return ctx.Err() != nil
+	}
+	return false
+}
+
+// grpcErrorKind finds out the kind of the error, the statuses that mean there's no response are mapped to the HTTP error kinds.
+func grpcErrorKind(err error) string {
+	if errors.Is(err, errInvalidMethod) {
+		return ErrorInvalidRequest
+	}
+	if st, ok := status.FromError(err); ok {
+		switch st.Code() {
+		case codes.Unavailable:
+			return ErrorConnection
+		case codes.DeadlineExceeded:
+			return ErrorTimeout
+		}
+	}
+	return classifyError(err)
+}
+
+// grpcMetadata converts the request headers in JSON format to the gRPC metadata.
+func grpcMetadata(headers string) (metadata.MD, error) {
+	md := metadata.MD{}
+	if headers == "" {
+		return md, nil
+	}
+	headersMap := map[string]string{}
+	if err := json.Unmarshal([]byte(headers), &headersMap); err != nil {
+		return nil, errors.New("cannot convert headers to a map")
+	}
+	for k, v := range headersMap {
+		md.Set(k, v)
+	}
+	return md, nil
+}
+
+func metadataToJson(md metadata.MD) string {
+	header := make(http.Header, len(md))
+	for k, v := range md {
+		header[k] = v
+	}
+	return HeadersToJson(header)
+}
+
+// marshalCanonicalJson converts the message to JSON according to the proto3 JSON mapping.
+// The protojson package adds random spaces to its output on purpose, so the result is compacted to be stable.
+func marshalCanonicalJson(message proto.Message, resolver grpcTypeResolver) ([]byte, error) {
+	data, err := protojson.MarshalOptions{Resolver: resolver}.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
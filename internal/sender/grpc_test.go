@@ -0,0 +1,299 @@
+package sender_test
+
+import (
+	"context"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// ignoreGrpcFields skips the response fields that change from one run to another.
+var ignoreGrpcFields = cmpopts.IgnoreFields(sender.Response{}, "Start", "TimeToFirstByte", "Duration", "BodyDigest", "ErrorMessage")
+
+// startGrpcServer starts the health service, it echoes the x-echo header in the response header and trailer.
+func startGrpcServer(t *testing.T, withReflection bool) string {
+	echo := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if v := md.Get("x-echo"); len(v) != 0 {
+			_ = grpc.SetHeader(ctx, metadata.Pairs("x-echo", v[0]))
+			_ = grpc.SetTrailer(ctx, metadata.Pairs("x-echo-trailer", v[0]))
+		}
+		return handler(ctx, req)
+	}
+	server := grpc.NewServer(grpc.UnaryInterceptor(echo))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	if withReflection {
+		reflection.Register(server)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	t.Cleanup(server.Stop)
+	return "grpc://" + lis.Addr().String()
+}
+
+func sendOne(s sender.Sender, req sender.Request) sender.Response {
+	requests := make(chan sender.Request, 1)
+	requests <- req
+	close(requests)
+	return chanToSlice(s.SendRequests(context.Background(), requests, 1))[0].Response
+}
+
+func TestSendGrpcRequest(t *testing.T) {
+	userUrl := startGrpcServer(t, true)
+
+	tests := []struct {
+		name     string
+		req      sender.Request
+		expected sender.Response
+	}{
+		{
+			name: "ok",
+			req: sender.Request{
+				Url:     userUrl + "/grpc.health.v1.Health/Check",
+				Headers: `{"x-echo":"foo"}`,
+				Body:    `{"service": ""}`,
+				UserUrl: userUrl,
+			},
+			expected: sender.Response{
+				Status:   "OK",
+				Body:     `{"status":"SERVING"}`,
+				Attempts: 1,
+				Headers:  `{"content-type":"application/grpc","x-echo":"foo"}`,
+				Trailers: `{"x-echo-trailer":"foo"}`,
+				Proto:    "HTTP/2.0",
+				Size:     20,
+			},
+		},
+		{
+			name: "error status",
+			req: sender.Request{
+				Url:     userUrl + "/grpc.health.v1.Health/Check",
+				Body:    `{"service": "unknown"}`,
+				UserUrl: userUrl,
+			},
+			expected: sender.Response{
+				Status:   "NotFound",
+				Body:     `{"code":5,"message":"unknown service"}`,
+				Attempts: 1,
+				// the failed calls usually have only the trailers
+				Trailers: `{"content-type":"application/grpc"}`,
+				Proto:    "HTTP/2.0",
+				Size:     38,
+			},
+		},
+		{
+			name: "unknown method",
+			req: sender.Request{
+				Url:     userUrl + "/grpc.health.v1.Health/Unknown",
+				UserUrl: userUrl,
+			},
+			expected: sender.Response{Error: sender.ErrorInvalidRequest},
+		},
+		{
+			name: "unknown service",
+			req: sender.Request{
+				Url:     userUrl + "/test.Unknown/Check",
+				UserUrl: userUrl,
+			},
+			expected: sender.Response{Error: sender.ErrorInvalidRequest},
+		},
+		{
+			name: "streaming method",
+			req: sender.Request{
+				Url:     userUrl + "/grpc.health.v1.Health/Watch",
+				UserUrl: userUrl,
+			},
+			expected: sender.Response{Error: sender.ErrorInvalidRequest},
+		},
+		{
+			name: "invalid body",
+			req: sender.Request{
+				Url:     userUrl + "/grpc.health.v1.Health/Check",
+				Body:    `{"unknown": 1}`,
+				UserUrl: userUrl,
+			},
+			expected: sender.Response{Error: sender.ErrorInvalidRequest},
+		},
+	}
+
+	s := sender.NewSender()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := sendOne(s, tt.req)
+			if diff := cmp.Diff(tt.expected, actual, ignoreGrpcFields); diff != "" {
+				t.Errorf("incorrect result (-expected +actual):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSendGrpcRequestWithDescriptorSet(t *testing.T) {
+	userUrl := startGrpcServer(t, false)
+
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(healthpb.File_grpc_health_v1_health_proto)},
+	}
+	data, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "health.pb")
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	descriptors, err := sender.LoadDescriptorSet(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := sender.Request{Url: userUrl + "/grpc.health.v1.Health/Check", UserUrl: userUrl}
+
+	// the server doesn't have the reflection, so the call fails without the descriptors
+	actual := sendOne(sender.NewSender(), req)
+	if actual.Error != sender.ErrorUnknown {
+		t.Errorf("incorrect error: expected %v, got %v (%v)", sender.ErrorUnknown, actual.Error, actual.ErrorMessage)
+	}
+
+	s := sender.NewSender(sender.WithTargetConfig(userUrl, sender.TargetConfig{Descriptors: descriptors}))
+	actual = sendOne(s, req)
+	if actual.Status != "OK" || actual.Body != `{"status":"SERVING"}` {
+		t.Errorf("incorrect result: %+v", actual)
+	}
+}
+
+func TestSendGrpcRequestWithConnectionError(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userUrl := "grpc://" + lis.Addr().String()
+	_ = lis.Close()
+
+	s := sender.NewSender(sender.WithRetryPolicy(sender.RetryPolicy{MaxAttempts: 1}))
+	actual := sendOne(s, sender.Request{Url: userUrl + "/grpc.health.v1.Health/Check", UserUrl: userUrl})
+
+	if actual.Error != sender.ErrorConnection {
+		t.Errorf("incorrect error: expected %v, got %v (%v)", sender.ErrorConnection, actual.Error, actual.ErrorMessage)
+	}
+}
+
+func TestSendGrpcRequestWithResolve(t *testing.T) {
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(startGrpcServer(t, true), "grpc://"))
+	userUrl := "grpc://grpc.test:" + port
+
+	resolve, err := sender.ParseResolve([]string{"grpc.test:" + port + ":127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := sender.NewSender(sender.WithTargetConfig(userUrl, sender.TargetConfig{Resolve: resolve}))
+	actual := sendOne(s, sender.Request{Url: userUrl + "/grpc.health.v1.Health/Check", UserUrl: userUrl})
+
+	if actual.Status != "OK" || actual.Body != `{"status":"SERVING"}` {
+		t.Errorf("incorrect result: %+v", actual)
+	}
+}
+
+func TestSendGrpcRequestWithTimings(t *testing.T) {
+	userUrl := startGrpcServer(t, true)
+
+	before := time.Now()
+	actual := sendOne(sender.NewSender(), sender.Request{Url: userUrl + "/grpc.health.v1.Health/Check", UserUrl: userUrl})
+
+	if actual.Status != "OK" {
+		t.Fatalf("incorrect result: %+v", actual)
+	}
+	if actual.Start.Before(before) || actual.TimeToFirstByte <= 0 || actual.TimeToFirstByte > actual.Duration {
+		t.Errorf("incorrect timings: start=%v, ttfb=%v, duration=%v", actual.Start, actual.TimeToFirstByte, actual.Duration)
+	}
+}
+
+func TestSendGroupsWithGrpcTargets(t *testing.T) {
+	first, second := startGrpcServer(t, true), startGrpcServer(t, true)
+
+	groups := make(chan []sender.Request, 1)
+	groups <- []sender.Request{
+		{Url: first + "/grpc.health.v1.Health/Check", UserUrl: first},
+		{Url: second + "/grpc.health.v1.Health/Check", UserUrl: second},
+	}
+	close(groups)
+
+	actual := chanToSlice(sender.NewSender().SendGroups(context.Background(), groups, 1))
+
+	if len(actual) != 2 || actual[0].Response.Status != "OK" || actual[1].Response.Status != "OK" {
+		t.Fatalf("incorrect result: %+v", actual)
+	}
+	// the skew is measured from the first request of the group, so only one of them can have no skew
+	if min(actual[0].Response.Skew, actual[1].Response.Skew) != 0 || max(actual[0].Response.Skew, actual[1].Response.Skew) == 0 {
+		t.Errorf("incorrect result: expected the skew of the gRPC requests, got %v and %v", actual[0].Response.Skew, actual[1].Response.Skew)
+	}
+}
+
+func TestSendGrpcRequestWithSlowReflection(t *testing.T) {
+	var slow atomic.Bool
+	release := make(chan struct{})
+	// the reflection stream hangs once the flag is set, like a server that doesn't answer
+	hang := func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if slow.Load() {
+			<-release
+		}
+		return handler(srv, ss)
+	}
+	server := grpc.NewServer(grpc.StreamInterceptor(hang))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	reflection.Register(server)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	defer server.Stop()
+	defer close(release)
+	userUrl := "grpc://" + lis.Addr().String()
+
+	s := sender.NewSender()
+	known := sender.Request{Url: userUrl + "/grpc.health.v1.Health/Check", UserUrl: userUrl}
+	if actual := s.SendRequest(context.Background(), known); actual.Status != "OK" {
+		t.Fatalf("incorrect result: %+v", actual)
+	}
+
+	slow.Store(true)
+	go s.SendRequest(context.Background(), sender.Request{Url: userUrl + "/test.Unknown/Check", UserUrl: userUrl})
+	// the lookup of the unknown service must be in progress before the known method is called
+	time.Sleep(100 * time.Millisecond)
+
+	done := make(chan sender.Response)
+	go func() {
+		done <- s.SendRequest(context.Background(), known)
+	}()
+	select {
+	case actual := <-done:
+		if actual.Status != "OK" {
+			t.Errorf("incorrect result: %+v", actual)
+		}
+	case <-time.After(time.Second):
+		t.Error("the call of the known method is held back by the reflection of another service")
+	}
+}
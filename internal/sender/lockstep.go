@@ -0,0 +1,64 @@
+package sender
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SendGroups works the same way as SendRequests, but it takes groups of requests, usually the requests
+// created from the same record for different targets, and sends the requests of each group at the same time.
+// This way, the targets are compared at the same moment, which matters when the data changes over time.
+// Each response gets the skew, which shows how much later its request was dispatched than the first request of the group.
+// The waiting for the limits of the targets and the retries aren't counted, the retries are in the number of attempts.
+func (s Sender) SendGroups(ctx context.Context, input <-chan []Request, workers int) <-chan RequestResponse {
+	session := func(group []Request) string {
+		if len(group) == 0 {
+			return ""
+		}
+		return group[0].Session
+	}
+	return runWorkers(ctx, input, workers, session, func(group []Request) []RequestResponse {
+		return s.repeated(ctx, func(repeat int) []RequestResponse {
+			repeatedGroup := make([]Request, len(group))
+			for i, req := range group {
+				req.Repeat = repeat
+				repeatedGroup[i] = req
+			}
+			return s.sendGroup(ctx, repeatedGroup)
+		})
+	})
+}
+
+func (s Sender) sendGroup(ctx context.Context, group []Request) []RequestResponse {
+	result := make([]RequestResponse, len(group))
+	timings := make([]timing, len(group))
+
+	// all the goroutines wait for the start signal, so none of them gets ahead while the others are being created
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, req := range group {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			result[i] = RequestResponse{req, s.sendRequest(ctx, req, &timings[i])}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	// the requests that failed before they were dispatched don't have the time, so they don't have the skew either
+	var first time.Time
+	for _, tm := range timings {
+		if !tm.dispatched.IsZero() && (first.IsZero() || tm.dispatched.Before(first)) {
+			first = tm.dispatched
+		}
+	}
+	for i, tm := range timings {
+		if !tm.dispatched.IsZero() {
+			result[i].Response.Skew = tm.dispatched.Sub(first)
+		}
+	}
+	return result
+}
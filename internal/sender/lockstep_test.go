@@ -0,0 +1,143 @@
+package sender_test
+
+import (
+	"context"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSendGroups(t *testing.T) {
+	var mu sync.Mutex
+	arrivals := make(map[string][]time.Time)
+	newServer := func(name string, delay time.Duration) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			arrivals[name] = append(arrivals[name], time.Now())
+			mu.Unlock()
+			// a slow target would make the other one get ahead if the requests were sent independently
+			time.Sleep(delay)
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	slow := newServer("slow", 100*time.Millisecond)
+	fast := newServer("fast", 0)
+
+	groups := make(chan []sender.Request)
+	go func() {
+		for i := 0; i < 3; i++ {
+			groups <- []sender.Request{
+				{Url: slow.URL, Method: "GET", UserUrl: slow.URL, Hash: uint64(i)},
+				{Url: fast.URL, Method: "GET", UserUrl: fast.URL, Hash: uint64(i)},
+			}
+		}
+		close(groups)
+	}()
+
+	s := sender.NewSender()
+	actual := chanToSlice(s.SendGroups(context.Background(), groups, 1))
+
+	if len(actual) != 6 {
+		t.Fatal("incorrect result: expected number of responses is 6, got", len(actual))
+	}
+	for i := 0; i < len(actual); i += 2 {
+		first, second := actual[i], actual[i+1]
+		if first.Request.Hash != second.Request.Hash {
+			t.Errorf("incorrect result: expected the responses of the same group to go together, got %v and %v", first.Request.Hash, second.Request.Hash)
+		}
+		if min(first.Response.Skew, second.Response.Skew) != 0 || max(first.Response.Skew, second.Response.Skew) > 50*time.Millisecond {
+			t.Errorf("incorrect result: expected a small skew, got %v and %v", first.Response.Skew, second.Response.Skew)
+		}
+	}
+	for i := range arrivals["slow"] {
+		if d := arrivals["fast"][i].Sub(arrivals["slow"][i]).Abs(); d > 50*time.Millisecond {
+			t.Errorf("incorrect result: expected the requests to arrive at the same time, got %v apart", d)
+		}
+	}
+}
+
+func TestSendGroupsWithRetries(t *testing.T) {
+	var attempts atomic.Int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if attempts.Add(1) == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer flaky.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+
+	groups := make(chan []sender.Request, 1)
+	groups <- []sender.Request{
+		{Url: flaky.URL, Method: "GET", UserUrl: flaky.URL},
+		{Url: server.URL, Method: "GET", UserUrl: server.URL},
+	}
+	close(groups)
+
+	policy := sender.RetryPolicy{MaxAttempts: 2, BaseDelay: 200 * time.Millisecond, MaxDelay: time.Second, RetryStatuses: []int{503}}
+	s := sender.NewSender(sender.WithRetryPolicy(policy))
+	actual := chanToSlice(s.SendGroups(context.Background(), groups, 1))
+
+	if len(actual) != 2 || actual[0].Response.Attempts != 2 {
+		t.Fatalf("incorrect result: %+v", actual)
+	}
+	// the retry is reported in the attempts, it isn't a skew
+	for _, rr := range actual {
+		if rr.Response.Skew > 50*time.Millisecond {
+			t.Errorf("incorrect result: expected a small skew, got %v", rr.Response.Skew)
+		}
+	}
+}
+
+func TestSendGroupsWithInvalidRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+
+	groups := make(chan []sender.Request)
+	go func() {
+		groups <- []sender.Request{
+			{Url: server.URL, Method: "GET"},
+			{Url: server.URL, Method: "GET", Headers: "not json"},
+		}
+		close(groups)
+	}()
+
+	s := sender.NewSender()
+	actual := chanToSlice(s.SendGroups(context.Background(), groups, 1))
+
+	if len(actual) != 2 || actual[0].Response.Skew != 0 || actual[1].Response.Error != sender.ErrorInvalidRequest {
+		t.Errorf("incorrect result: expected a successful response and an invalid request, got %v", actual)
+	}
+}
+
+func TestSendGroupsWithRepeat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+
+	groups := make(chan []sender.Request)
+	go func() {
+		groups <- []sender.Request{
+			{Url: server.URL + "/a", Method: "GET", UserUrl: server.URL + "/a"},
+			{Url: server.URL + "/b", Method: "GET", UserUrl: server.URL + "/b"},
+		}
+		close(groups)
+	}()
+
+	s := sender.NewSender(sender.WithRepeat(2, 0))
+	actual := chanToSlice(s.SendGroups(context.Background(), groups, 1))
+
+	if len(actual) != 4 {
+		t.Fatal("incorrect result: expected number of responses is 4, got", len(actual))
+	}
+	// the whole group is sent again for every repeat
+	for i, rr := range actual {
+		if rr.Request.Repeat != i/2 {
+			t.Errorf("incorrect repeat of the response %v: expected %v, got %v", i, i/2, rr.Request.Repeat)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// NoRedirects is the value of TargetConfig.MaxRedirects that disables following redirects.
+const NoRedirects = -1
+
+// defaultMaxRedirects is the same limit the default HTTP client has.
+const defaultMaxRedirects = 10
+
+// Redirect is a single hop of a redirect chain.
+type Redirect struct {
+	Status int `json:"status"`
+	// Location is relative if the redirect points to the same host, so the chains of different targets can be compared.
+	Location string `json:"location"`
+}
+
+// ParseRedirects converts the redirect policy to the maximum number of redirects.
+// The policy is either "follow" to follow the default number of redirects, "none" to not follow them, or a number.
+func ParseRedirects(policy string) (int, error) {
+	switch policy {
+	case "", "follow":
+		return 0, nil
+	case "none":
+		return NoRedirects, nil
+	}
+	n, err := strconv.Atoi(policy)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid redirect policy '%v', must be follow, none, or a number", policy)
+	}
+	if n == 0 {
+		return NoRedirects, nil
+	}
+	return n, nil
+}
+
+type redirectChainKey struct{}
+
+// redirectChain collects the redirects of a single attempt.
+type redirectChain struct {
+	hops []Redirect
+}
+
+func (c *redirectChain) trace(req *http.Request) *http.Request {
+	c.hops = nil
+	return req.WithContext(context.WithValue(req.Context(), redirectChainKey{}, c))
+}
+
+func (c *redirectChain) String() string {
+	if len(c.hops) == 0 {
+		return ""
+	}
+	bytes, err := json.Marshal(c.hops)
+	if err != nil {
+		log.Fatalln("cannot convert redirects to JSON:", err)
+	}
+	return string(bytes)
+}
+
+// checkRedirect returns a function that records the redirects and stops following them after the given number of hops.
+// When the limit is reached, the last redirect response is returned as is, so it can be compared with the other targets.
+func checkRedirect(maxRedirects int) func(req *http.Request, via []*http.Request) error {
+	if maxRedirects == 0 {
+		maxRedirects = defaultMaxRedirects
+	}
+	return func(req *http.Request, via []*http.Request) error {
+		// the response we return isn't a part of the chain, the chain only has the redirects we followed
+		if len(via) > maxRedirects {
+			return http.ErrUseLastResponse
+		}
+		if c, ok := req.Context().Value(redirectChainKey{}).(*redirectChain); ok {
+			c.hops = append(c.hops, Redirect{req.Response.StatusCode, redirectLocation(req, via[0])})
+		}
+		return nil
+	}
+}
+
+// redirectLocation returns the location the request is redirected to.
+// It's relative if the location is on the same host as the original request, since each target has its own host.
+func redirectLocation(req *http.Request, original *http.Request) string {
+	if req.URL.Scheme == original.URL.Scheme && req.URL.Host == original.URL.Host {
+		return req.URL.RequestURI()
+	}
+	return req.URL.String()
+}
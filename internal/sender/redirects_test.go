@@ -0,0 +1,78 @@
+package sender_test
+
+import (
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSendRequestsWithRedirects(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("ok"))
+	}))
+	defer other.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/old":
+			http.Redirect(rw, req, "/new?page=1", http.StatusFound)
+		case "/new":
+			http.Redirect(rw, req, other.URL+"/external", http.StatusMovedPermanently)
+		}
+	}))
+	defer server.Close()
+
+	data := []struct {
+		name              string
+		maxRedirects      int
+		expectedStatus    string
+		expectedRedirects string
+	}{
+		{"follow", 0, "200", `[{"status":302,"location":"/new?page=1"},{"status":301,"location":"` + other.URL + `/external"}]`},
+		{"none", sender.NoRedirects, "302", ""},
+		{"limited", 1, "301", `[{"status":302,"location":"/new?page=1"}]`},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			resp := sendOneRequest(server.URL+"/old", sender.TargetConfig{MaxRedirects: d.maxRedirects})
+
+			if resp.Status != d.expectedStatus || resp.Redirects != d.expectedRedirects {
+				t.Errorf("incorrect result: expected status %v with redirects '%v', got %v with '%v'",
+					d.expectedStatus, d.expectedRedirects, resp.Status, resp.Redirects)
+			}
+		})
+	}
+}
+
+func TestParseRedirects(t *testing.T) {
+	data := []struct {
+		policy   string
+		expected int
+	}{
+		{"", 0},
+		{"follow", 0},
+		{"none", sender.NoRedirects},
+		{"0", sender.NoRedirects},
+		{"3", 3},
+	}
+
+	for _, d := range data {
+		t.Run(d.policy, func(t *testing.T) {
+			actual, err := sender.ParseRedirects(d.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != d.expected {
+				t.Errorf("incorrect result: expected %v, got %v", d.expected, actual)
+			}
+		})
+	}
+
+	for _, policy := range []string{"always", "-1"} {
+		if _, err := sender.ParseRedirects(policy); err == nil {
+			t.Errorf("incorrect result: expected an error for '%v'", policy)
+		}
+	}
+}
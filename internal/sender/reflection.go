@@ -0,0 +1,92 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// reflectFiles asks the server for the file that defines the given service and all the files it depends on.
+func reflectFiles(ctx context.Context, conn *grpc.ClientConn, serviceName string) (*protoregistry.Files, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, reflectionError(err)
+	}
+
+	files := make(map[string]*descriptorpb.FileDescriptorProto)
+	var missing []string
+	// receive adds the files from the response and queues the dependencies we haven't got yet
+	receive := func(req *reflectionpb.ServerReflectionRequest) error {
+		if err := stream.Send(req); err != nil {
+			return reflectionError(err)
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			return reflectionError(err)
+		}
+		if e := resp.GetErrorResponse(); e != nil {
+			if codes.Code(e.ErrorCode) == codes.NotFound {
+				return fmt.Errorf("%w: cannot find the service %v: %v", errInvalidMethod, serviceName, e.ErrorMessage)
+			}
+			return reflectionError(status.Error(codes.Code(e.ErrorCode), e.ErrorMessage))
+		}
+		for _, data := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			var file descriptorpb.FileDescriptorProto
+			if err := proto.Unmarshal(data, &file); err != nil {
+				return fmt.Errorf("cannot parse the file descriptor from the server: %w", err)
+			}
+			files[file.GetName()] = &file
+			missing = append(missing, file.GetDependency()...)
+		}
+		return nil
+	}
+
+	err = receive(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: serviceName},
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the servers usually send the dependencies right away, but they don't have to
+	for len(missing) > 0 {
+		name := missing[0]
+		missing = missing[1:]
+		if _, ok := files[name]; ok {
+			continue
+		}
+		err := receive(&reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_FileByFilename{FileByFilename: name},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, file := range files {
+		set.File = append(set.File, file)
+	}
+	result, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file descriptors from the server: %w", err)
+	}
+	return result, nil
+}
+
+func reflectionError(err error) error {
+	if status.Code(err) == codes.Unimplemented {
+		return errors.New("the server doesn't support the reflection, the descriptors need to be provided in a descriptor set")
+	}
+	return fmt.Errorf("cannot get the descriptors through the reflection: %w", err)
+}
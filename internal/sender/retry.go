@@ -0,0 +1,78 @@
+package sender
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy describes when and how often a failed request should be retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, every next retry waits twice as long.
+	BaseDelay time.Duration
+	// MaxDelay limits the delay between two attempts, including the one requested by the Retry-After header.
+	MaxDelay time.Duration
+	// RetryStatuses is a set of response status codes that should be retried.
+	RetryStatuses []int
+}
+
+// DefaultRetryPolicy returns the retry policy that is used if a custom one is not provided.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   time.Second,
+		MaxDelay:    30 * time.Second,
+		RetryStatuses: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+func (p RetryPolicy) shouldRetryStatus(status int) bool {
+	return slices.Contains(p.RetryStatuses, status)
+}
+
+// delay calculates how long we need to wait after the given attempt.
+// It uses exponential backoff with jitter unless the server tells us when to come back.
+func (p RetryPolicy) delay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return min(d, p.MaxDelay)
+		}
+	}
+
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, p.MaxDelay)
+	if d <= 0 {
+		return 0
+	}
+
+	// the jitter spreads the retries of different workers, so they don't hit the server at the same moment
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
+
+// parseRetryAfter parses the value of the Retry-After header,
+// which can be either a number of seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
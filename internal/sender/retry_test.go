@@ -0,0 +1,54 @@
+package sender
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	data := []struct {
+		name     string
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"empty", "", 0, false},
+		{"seconds", "120", 2 * time.Minute, true},
+		{"date", now.Add(time.Minute).Format(http.TimeFormat), time.Minute, true},
+		{"past_date", now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"incorrect", "soon", 0, false},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			actual, ok := parseRetryAfter(d.value, now)
+			if actual != d.expected || ok != d.ok {
+				t.Errorf("incorrect result: expected (%v, %v), got (%v, %v)", d.expected, d.ok, actual, ok)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	data := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{5, time.Second},
+	}
+
+	for _, d := range data {
+		actual := policy.delay(d.attempt, nil)
+		if actual < d.expected/2 || actual > d.expected {
+			t.Errorf("incorrect result: expected the delay between %v and %v, got %v", d.expected/2, d.expected, actual)
+		}
+	}
+}
@@ -1,15 +1,17 @@
 package sender
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/nikitakuchur/testpoint/internal/secrets"
 	"io"
 	"log"
 	"net/http"
+	"net/http/httptrace"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
@@ -21,6 +23,10 @@ type Request struct {
 
 	UserUrl string
 	Hash    uint64
+	// Session is the ID of the session the request belongs to, the requests of the same session are sent in order.
+	Session string
+	// Repeat is the number of the repeat starting with zero, the requests are repeated only if the sender is told so.
+	Repeat int
 }
 
 func (r Request) String() string {
@@ -28,8 +34,42 @@ func (r Request) String() string {
 }
 
 type Response struct {
-	Status string
-	Body   string
+	Status   string
+	Body     string
+	Attempts int
+
+	// BodyEncoding is empty if the body is text, or BodyEncodingBase64 otherwise.
+	BodyEncoding string
+	// BodyTruncated is true if the body was longer than the limit, and only its beginning was kept.
+	BodyTruncated bool
+	// BodyDigest is the hex-encoded SHA-256 digest of the whole body.
+	BodyDigest string
+
+	// Headers contains the response headers in JSON format, the values of repeated headers are joined with commas.
+	Headers string
+	// RawHeaders are the original response headers, they keep all the values of the repeated headers, e.g. Set-Cookie.
+	// They are used when the response is passed on, and they aren't written to the output files.
+	RawHeaders http.Header
+	Proto      string
+	// Start is when the last attempt was started, or when the request failed if it wasn't sent at all.
+	// It isn't written to the output files.
+	Start           time.Time
+	TimeToFirstByte time.Duration
+	Duration        time.Duration
+	Size            int
+	ContentEncoding string
+	TLSVersion      string
+	TLSCipher       string
+	// Redirects contains the redirects that were followed in JSON format, it's empty if there were no redirects.
+	Redirects string
+	// Skew is how much later the request was dispatched than the first request of its group, it's only set in the lock-step mode.
+	Skew time.Duration
+	// Trailers contains the trailers of a gRPC response in the same format as the headers.
+	Trailers string
+
+	// Error is the kind of error that prevented us from getting the response, it's empty if there was no error.
+	Error        string
+	ErrorMessage string
 }
 
 type RequestResponse struct {
@@ -38,87 +78,291 @@ type RequestResponse struct {
 }
 
 type Sender struct {
-	client *http.Client
+	retryPolicy   RetryPolicy
+	targets       map[string]*target
+	defaultTarget *target
+	repeat        int
+	repeatDelay   time.Duration
+}
+
+// Option configures the sender.
+type Option func(s *Sender)
+
+// WithRetryPolicy sets the policy that decides when the requests need to be retried.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(s *Sender) {
+		s.retryPolicy = policy
+	}
+}
+
+// WithTargetConfig sets the configuration of the target with the given URL.
+// The requests are matched with their targets by the user URL.
+func WithTargetConfig(userUrl string, conf TargetConfig) Option {
+	return func(s *Sender) {
+		s.targets[userUrl] = newTarget(conf)
+	}
+}
+
+// WithDefaultTargetConfig sets the configuration of the requests whose user URL doesn't have its own configuration.
+func WithDefaultTargetConfig(conf TargetConfig) Option {
+	return func(s *Sender) {
+		s.defaultTarget = newTarget(conf)
+	}
+}
+
+// WithRepeat makes the sender send every request the given number of times one after another,
+// waiting for the delay between the repeats. All the responses are returned, so they can be checked for consistency.
+func WithRepeat(count int, delay time.Duration) Option {
+	return func(s *Sender) {
+		s.repeat = count
+		s.repeatDelay = delay
+	}
 }
 
-func NewSender() Sender {
-	return Sender{&http.Client{}}
+func NewSender(opts ...Option) Sender {
+	s := Sender{DefaultRetryPolicy(), make(map[string]*target), newTarget(TargetConfig{}), 1, 0}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
 }
 
 // SendRequests takes requests from the input channel, sends them to
 // the corresponding endpoint, and puts the result in the output channel.
-func (s Sender) SendRequests(input <-chan Request, workers int) <-chan RequestResponse {
-	output := make(chan RequestResponse)
+// Each target has its own queue and the given number of workers, so each target limits its own rate and number
+// of requests in flight, and a slow target doesn't hold back the others.
+// The requests of the same session are always sent by the same worker, so they keep their order.
+// The context is used for all the requests, so canceling it aborts the requests in flight.
+func (s Sender) SendRequests(ctx context.Context, input <-chan Request, workers int) <-chan RequestResponse {
+	return runTargetWorkers(ctx, input, workers, func(req Request) []RequestResponse {
+		return s.repeated(ctx, func(repeat int) []RequestResponse {
+			req.Repeat = repeat
+			return []RequestResponse{{req, s.sendRequest(ctx, req, &timing{})}}
+		})
+	})
+}
+
+// repeated calls the function for every repeat, and stops early if the context is canceled during the delay.
+func (s Sender) repeated(ctx context.Context, send func(repeat int) []RequestResponse) []RequestResponse {
+	var result []RequestResponse
+	for i := 0; i < max(s.repeat, 1); i++ {
+		if i > 0 && sleep(ctx, s.repeatDelay) != nil {
+			break
+		}
+		result = append(result, send(i)...)
+	}
+	return result
+}
+
+// SendRequest sends a single request and returns its response, it's used when the caller needs the response right away.
+func (s Sender) SendRequest(ctx context.Context, req Request) Response {
+	return s.sendRequest(ctx, req, &timing{})
+}
+
+// sendRequest sends the request and returns the response, the timing is filled in along the way.
+// If the request fails, the response describes the error instead, so it can be compared with the other targets.
+func (s Sender) sendRequest(ctx context.Context, req Request, tm *timing) Response {
+	resp := s.doSendRequest(ctx, req, tm)
+	resp.Start = tm.start
+	if resp.Start.IsZero() {
+		// the request has failed before it was sent, so it's timed by the failure
+		resp.Start = time.Now()
+	}
+	if resp.Error != "" && ctx.Err() == nil {
+		log.Print(secrets.MaskSecrets(fmt.Sprintf("%v: %v, the error was recorded", req, resp.ErrorMessage)))
+	}
+	return resp
+}
+
+func (s Sender) doSendRequest(ctx context.Context, req Request, tm *timing) Response {
+	if isGrpcUrl(req.Url) {
+		return s.doSendGrpcRequest(ctx, req, tm)
+	}
+	req.Url, _ = unixRequestUrl(req.Url)
+
+	// we need to make sure the request is valid before we start sending it
+	if _, err := newHttpRequest(ctx, req); err != nil {
+		return errorResponse(ErrorInvalidRequest, err, 0)
+	}
+	tm.dispatched = time.Now()
+
+	t := s.target(req.UserUrl)
+	if err := t.acquire(ctx); err != nil {
+		return errorResponse(classifyError(err), err, 0)
+	}
+	defer t.release()
+
+	var chain redirectChain
+	resp, attempts, err := s.doRequest(ctx, req, t, tm, &chain)
+	if err != nil {
+		return errorResponse(classifyError(err), err, attempts)
+	}
+	defer closeResponse(resp)
 
-	var wg sync.WaitGroup
-	for i := 0; i < workers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
+	b, err := readBody(resp.Body, t.maxBodySize)
+	if err != nil {
+		return errorResponse(classifyError(err), fmt.Errorf("cannot read an http body: %w", err), attempts)
+	}
+	body, bodyEncoding := b.encode(resp.Header.Get("Content-Type"))
+
+	status := strconv.FormatInt(int64(resp.StatusCode), 10)
+	tlsVersion, tlsCipher := tlsInfo(resp)
+	return Response{
+		Status:          status,
+		Body:            body,
+		Attempts:        attempts,
+		BodyEncoding:    bodyEncoding,
+		BodyTruncated:   b.truncated,
+		BodyDigest:      b.digest,
+		Headers:         HeadersToJson(resp.Header),
+		RawHeaders:      resp.Header,
+		Proto:           resp.Proto,
+		TimeToFirstByte: tm.firstByte.Sub(tm.start),
+		Duration:        time.Since(tm.start),
+		Size:            b.size,
+		ContentEncoding: contentEncoding(resp),
+		TLSVersion:      tlsVersion,
+		TLSCipher:       tlsCipher,
+		Redirects:       chain.String(),
+	}
+}
+
+// timing keeps track of when the request was dispatched, and when the last attempt was started
+// and got the first byte of the response.
+type timing struct {
+	// dispatched is when the first attempt was dispatched, before the limits of the target and the retries,
+	// so it shows how close the requests of the same group were sent by us
+	dispatched time.Time
+	start      time.Time
+	firstByte  time.Time
+}
+
+func (tm *timing) trace(req *http.Request) *http.Request {
+	tm.start = time.Now()
+	tm.firstByte = tm.start
+	trace := &httptrace.ClientTrace{
+		GotFirstResponseByte: func() {
+			tm.firstByte = time.Now()
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
 
-			for req := range input {
-				resp, err := s.sendRequest(req)
-				if err != nil {
-					log.Printf("%v: %v, request was skipped", req, err)
-					continue
-				}
-				output <- RequestResponse{req, resp}
-			}
-		}()
+// HeadersToJson converts the headers to the JSON object they're stored as, the repeated headers are joined with commas.
+func HeadersToJson(header http.Header) string {
+	if len(header) == 0 {
+		return ""
+	}
+	headersMap := make(map[string]string, len(header))
+	for k, v := range header {
+		headersMap[k] = strings.Join(v, ", ")
 	}
+	bytes, err := json.Marshal(headersMap)
+	if err != nil {
+		log.Fatalln("cannot convert headers to JSON:", err)
+	}
+	return string(bytes)
+}
 
-	// this goroutine closes the channel
-	go func() {
-		wg.Wait()
-		close(output)
-	}()
+// contentEncoding returns the encoding the response was sent with.
+// Go removes the Content-Encoding header when it decompresses the body by itself, so we need to check both.
+func contentEncoding(resp *http.Response) string {
+	if resp.Uncompressed {
+		return "gzip"
+	}
+	return resp.Header.Get("Content-Encoding")
+}
 
-	return output
+func errorResponse(kind string, err error, attempts int) Response {
+	return Response{
+		Attempts:     attempts,
+		Error:        kind,
+		ErrorMessage: secrets.MaskSecrets(err.Error()),
+	}
 }
 
-func (s Sender) sendRequest(req Request) (Response, error) {
-	httpReq, err := http.NewRequest(req.Method, req.Url, strings.NewReader(req.Body))
+// newHttpRequest creates a new HTTP request from the given request.
+// Every attempt needs its own HTTP request, since the body of the previous one has already been read.
+func newHttpRequest(ctx context.Context, req Request) (*http.Request, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, req.Url, strings.NewReader(req.Body))
 	if err != nil {
-		return Response{}, fmt.Errorf("cannot create an http request: %w", err)
+		return nil, fmt.Errorf("cannot create an http request: %w", err)
 	}
 
 	if req.Headers != "" {
 		headersMap := map[string]string{}
 		err = json.Unmarshal([]byte(req.Headers), &headersMap)
 		if err != nil {
-			return Response{}, errors.New("cannot convert headers to a map")
+			return nil, errors.New("cannot convert headers to a map")
 		}
 		for k, v := range headersMap {
 			httpReq.Header.Set(k, v)
 		}
 	}
-
-	resp, err := s.doRequest(httpReq, 5)
-	if err != nil {
-		return Response{}, err
+	// the Host header is taken from the request itself, not from the headers
+	if _, ok := unixSocket(httpReq.URL.Hostname()); ok {
+		httpReq.Host = unixHostHeader
 	}
-	defer closeResponse(resp)
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return Response{}, fmt.Errorf("cannot read an http body: %w", err)
-	}
-
-	status := strconv.FormatInt(int64(resp.StatusCode), 10)
-	return Response{status, string(body)}, nil
+	return httpReq, nil
 }
 
-func (s Sender) doRequest(req *http.Request, retries int) (*http.Response, error) {
-	for i := 0; i < retries; i++ {
-		resp, err := s.client.Do(req)
+// doRequest sends the request and retries it according to the retry policy.
+// It returns the response along with the number of attempts it took.
+func (s Sender) doRequest(ctx context.Context, req Request, t *target, tm *timing, chain *redirectChain) (*http.Response, int, error) {
+	policy := s.retryPolicy
+	maxAttempts := max(policy.MaxAttempts, 1)
+
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		resp, err := t.do(ctx, req, tm, chain)
+		if err == nil && (attempt == maxAttempts || !policy.shouldRetryStatus(resp.StatusCode)) {
+			return resp, attempt, nil
+		}
 		if err != nil {
-			log.Printf("%v, retry attempt=%v", err, i+1)
-			time.Sleep(2 * time.Second)
-			continue
+			lastErr = err
+		}
+		if attempt == maxAttempts {
+			break
 		}
-		return resp, nil
+		// there's no point in retrying the requests that were canceled, so we keep what we've got
+		if ctx.Err() != nil {
+			return resp, attempt, err
+		}
+
+		delay := policy.delay(attempt, resp)
+		if err != nil {
+			log.Print(secrets.MaskSecrets(fmt.Sprintf("%v, retry attempt=%v", err, attempt)))
+		} else {
+			log.Print(secrets.MaskSecrets(fmt.Sprintf("%v %v: status %v, retry attempt=%v", req.Method, req.Url, resp.StatusCode, attempt)))
+			// the body must be fully read before closing, otherwise the connection cannot be reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			closeResponse(resp)
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return nil, attempt, err
+		}
+	}
+	return nil, maxAttempts, fmt.Errorf("cannot send an http request after %v attempts: %w", maxAttempts, lastErr)
+}
+
+// sleep pauses for the given duration, unless the context is canceled earlier.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s Sender) target(userUrl string) *target {
+	if t, ok := s.targets[userUrl]; ok {
+		return t
 	}
-	return nil, errors.New("cannot send an http request")
+	return s.defaultTarget
 }
 
 func closeResponse(resp *http.Response) {
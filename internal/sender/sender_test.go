@@ -1,12 +1,33 @@
 package sender_test
 
 import (
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"fmt"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/nikitakuchur/testpoint/internal/auth"
 	"github.com/nikitakuchur/testpoint/internal/sender"
+	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+)
+
+// ignoreTimings skips the response fields that change from one run to another.
+var ignoreTimings = cmpopts.IgnoreFields(sender.Response{}, "Headers", "RawHeaders", "Start", "TimeToFirstByte", "Duration")
+
+// the SHA-256 digests of the bodies used in the tests
+const (
+	helloWorldDigest = "c0535e4be2b79ffd93291305436bf889314e4a3faec05ecffcbb7df31ad9e51a"
+	emptyDigest      = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
 )
 
 func TestSendRequestsWithNoRequests(t *testing.T) {
@@ -14,7 +35,7 @@ func TestSendRequestsWithNoRequests(t *testing.T) {
 	close(requests)
 
 	s := sender.NewSender()
-	responses := s.SendRequests(requests, 1)
+	responses := s.SendRequests(context.Background(), requests, 1)
 
 	actual := chanToSlice(responses)
 
@@ -34,7 +55,7 @@ func TestSendRequestsWithZeroWorkers(t *testing.T) {
 	}()
 
 	s := sender.NewSender()
-	responses := s.SendRequests(requests, 0)
+	responses := s.SendRequests(context.Background(), requests, 0)
 
 	actual := chanToSlice(responses)
 
@@ -43,6 +64,41 @@ func TestSendRequestsWithZeroWorkers(t *testing.T) {
 	}
 }
 
+func TestSendRequestsWithCanceledContext(t *testing.T) {
+	received := make(chan struct{}, 3)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	requests := make(chan sender.Request)
+	go func() {
+		defer close(requests)
+		for i := 0; i < 3; i++ {
+			requests <- sender.Request{Url: server.URL, Method: "GET"}
+		}
+	}()
+
+	s := sender.NewSender()
+	responses := s.SendRequests(ctx, requests, 1)
+
+	// the first request is in flight when the context is canceled, and the rest of them are never sent
+	<-received
+	cancel()
+
+	actual := chanToSlice(responses)
+	if len(actual) != 0 {
+		t.Error("incorrect result: expected the canceled requests to be dropped, got", actual)
+	}
+	if len(received) != 0 {
+		t.Error("incorrect result: expected the server to receive 1 request, got", len(received)+1)
+	}
+}
+
 func TestSendRequests(t *testing.T) {
 	handlerFunc := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		_, err := rw.Write([]byte("Hello world!"))
@@ -64,7 +120,7 @@ func TestSendRequests(t *testing.T) {
 	}()
 
 	s := sender.NewSender()
-	responses := s.SendRequests(requests, 1)
+	responses := s.SendRequests(context.Background(), requests, 1)
 
 	actual := chanToSlice(responses)
 
@@ -74,15 +130,45 @@ func TestSendRequests(t *testing.T) {
 	expected := []sender.RequestResponse{
 		{
 			sender.Request{Url: server.URL, Method: "GET", Headers: `{"myHeader":"foo"}`},
-			sender.Response{Status: "200", Body: "Hello world!"},
+			sender.Response{Status: "200", Body: "Hello world!", Attempts: 1, Proto: "HTTP/1.1", Size: 12, BodyDigest: helloWorldDigest},
 		},
 	}
 
-	if diff := cmp.Diff(expected, actual); diff != "" {
+	if diff := cmp.Diff(expected, actual, ignoreTimings); diff != "" {
 		t.Error(diff)
 	}
 }
 
+func TestSendRequestsWithRepeat(t *testing.T) {
+	var count atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(strconv.Itoa(int(count.Add(1)))))
+	}))
+	defer server.Close()
+
+	requests := make(chan sender.Request)
+	go func() {
+		requests <- sender.Request{Url: server.URL, Method: "GET", Hash: 42}
+		close(requests)
+	}()
+
+	s := sender.NewSender(sender.WithRepeat(3, 10*time.Millisecond))
+	start := time.Now()
+	actual := chanToSlice(s.SendRequests(context.Background(), requests, 1))
+
+	if len(actual) != 3 {
+		t.Fatal("incorrect result: expected number of responses is 3, got", len(actual))
+	}
+	for i, rr := range actual {
+		if rr.Request.Repeat != i || rr.Request.Hash != 42 || rr.Response.Body != strconv.Itoa(i+1) {
+			t.Errorf("incorrect response to the repeat %v: %v %v", i, rr.Request, rr.Response.Body)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("the repeats were sent without the delay: %v", elapsed)
+	}
+}
+
 func TestSendRequestsWithIncorrectRequest(t *testing.T) {
 	requests := make(chan sender.Request)
 	go func() {
@@ -94,12 +180,15 @@ func TestSendRequestsWithIncorrectRequest(t *testing.T) {
 	}()
 
 	s := sender.NewSender()
-	responses := s.SendRequests(requests, 1)
+	responses := s.SendRequests(context.Background(), requests, 1)
 
 	actual := chanToSlice(responses)
 
-	if len(actual) != 0 {
-		t.Error("incorrect result: expected number of responses is 0, got", len(actual))
+	if len(actual) != 1 {
+		t.Fatal("incorrect result: expected number of responses is 1, got", len(actual))
+	}
+	if actual[0].Response.Error != sender.ErrorInvalidRequest || actual[0].Response.Attempts != 0 {
+		t.Error("incorrect result: expected an invalid request error, got", actual[0].Response)
 	}
 }
 
@@ -115,12 +204,433 @@ func TestSendRequestsWithIncorrectHeaders(t *testing.T) {
 	}()
 
 	s := sender.NewSender()
-	responses := s.SendRequests(requests, 1)
+	responses := s.SendRequests(context.Background(), requests, 1)
 
 	actual := chanToSlice(responses)
 
-	if len(actual) != 0 {
-		t.Error("incorrect result: expected number of responses is 0, got", len(actual))
+	if len(actual) != 1 {
+		t.Fatal("incorrect result: expected number of responses is 1, got", len(actual))
+	}
+	if actual[0].Response.Error != sender.ErrorInvalidRequest || actual[0].Response.Attempts != 0 {
+		t.Error("incorrect result: expected an invalid request error, got", actual[0].Response)
+	}
+}
+
+var testRetryPolicy = sender.RetryPolicy{
+	MaxAttempts:   3,
+	BaseDelay:     time.Millisecond,
+	MaxDelay:      10 * time.Millisecond,
+	RetryStatuses: []int{http.StatusServiceUnavailable},
+}
+
+func TestSendRequestsWithRetries(t *testing.T) {
+	var attempts atomic.Int32
+	var bodies []string
+	handlerFunc := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		bodies = append(bodies, string(body))
+		if attempts.Add(1) < 3 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = rw.Write([]byte("Hello world!"))
+	})
+	server := httptest.NewServer(handlerFunc)
+	defer server.Close()
+
+	requests := make(chan sender.Request)
+	go func() {
+		requests <- sender.Request{Url: server.URL, Method: "POST", Body: "foo"}
+		close(requests)
+	}()
+
+	s := sender.NewSender(sender.WithRetryPolicy(testRetryPolicy))
+	actual := chanToSlice(s.SendRequests(context.Background(), requests, 1))
+
+	expected := []sender.RequestResponse{
+		{
+			sender.Request{Url: server.URL, Method: "POST", Body: "foo"},
+			sender.Response{Status: "200", Body: "Hello world!", Attempts: 3, Proto: "HTTP/1.1", Size: 12, BodyDigest: helloWorldDigest},
+		},
+	}
+	if diff := cmp.Diff(expected, actual, ignoreTimings); diff != "" {
+		t.Error(diff)
+	}
+
+	// every attempt must send the full body
+	if diff := cmp.Diff([]string{"foo", "foo", "foo"}, bodies); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestSendRequestsWithExhaustedRetries(t *testing.T) {
+	handlerFunc := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Retry-After", "0")
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	})
+	server := httptest.NewServer(handlerFunc)
+	defer server.Close()
+
+	requests := make(chan sender.Request)
+	go func() {
+		requests <- sender.Request{Url: server.URL, Method: "GET"}
+		close(requests)
+	}()
+
+	s := sender.NewSender(sender.WithRetryPolicy(testRetryPolicy))
+	actual := chanToSlice(s.SendRequests(context.Background(), requests, 1))
+
+	expected := []sender.RequestResponse{
+		{
+			sender.Request{Url: server.URL, Method: "GET"},
+			sender.Response{Status: "503", Attempts: 3, Proto: "HTTP/1.1", BodyDigest: emptyDigest},
+		},
+	}
+	if diff := cmp.Diff(expected, actual, ignoreTimings); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestSendRequestsWithNonRetryableStatus(t *testing.T) {
+	var attempts atomic.Int32
+	handlerFunc := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		attempts.Add(1)
+		rw.WriteHeader(http.StatusInternalServerError)
+	})
+	server := httptest.NewServer(handlerFunc)
+	defer server.Close()
+
+	requests := make(chan sender.Request)
+	go func() {
+		requests <- sender.Request{Url: server.URL, Method: "GET"}
+		close(requests)
+	}()
+
+	s := sender.NewSender(sender.WithRetryPolicy(testRetryPolicy))
+	actual := chanToSlice(s.SendRequests(context.Background(), requests, 1))
+
+	if len(actual) != 1 || actual[0].Response.Attempts != 1 || attempts.Load() != 1 {
+		t.Error("incorrect result: expected exactly one attempt, got", attempts.Load())
+	}
+}
+
+func TestSendRequestsWithMaxInFlight(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	handlerFunc := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	})
+	server := httptest.NewServer(handlerFunc)
+	defer server.Close()
+
+	requests := make(chan sender.Request)
+	go func() {
+		for i := 0; i < 10; i++ {
+			requests <- sender.Request{Url: server.URL, Method: "GET", UserUrl: server.URL}
+		}
+		close(requests)
+	}()
+
+	s := sender.NewSender(sender.WithTargetConfig(server.URL, sender.TargetConfig{MaxInFlight: 2}))
+	actual := chanToSlice(s.SendRequests(context.Background(), requests, 8))
+
+	if len(actual) != 10 {
+		t.Error("incorrect result: expected number of responses is 10, got", len(actual))
+	}
+	if maxInFlight.Load() > 2 {
+		t.Error("incorrect result: expected at most 2 requests in flight, got", maxInFlight.Load())
+	}
+}
+
+func TestSendRequestsWithRateLimit(t *testing.T) {
+	handlerFunc := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+	limitedServer := httptest.NewServer(handlerFunc)
+	defer limitedServer.Close()
+	server := httptest.NewServer(handlerFunc)
+	defer server.Close()
+
+	requests := make(chan sender.Request)
+	go func() {
+		for i := 0; i < 5; i++ {
+			requests <- sender.Request{Url: limitedServer.URL, Method: "GET", UserUrl: limitedServer.URL}
+			requests <- sender.Request{Url: server.URL, Method: "GET", UserUrl: server.URL}
+		}
+		close(requests)
+	}()
+
+	s := sender.NewSender(sender.WithTargetConfig(limitedServer.URL, sender.TargetConfig{RateLimit: 50}))
+
+	start := time.Now()
+	actual := chanToSlice(s.SendRequests(context.Background(), requests, 4))
+	elapsed := time.Since(start)
+
+	if len(actual) != 10 {
+		t.Error("incorrect result: expected number of responses is 10, got", len(actual))
+	}
+	// the first request is sent right away, and the next four have to wait 20ms each
+	if elapsed < 80*time.Millisecond {
+		t.Error("incorrect result: expected the requests to take at least 80ms, got", elapsed)
+	}
+}
+
+func TestSendRequestsWithSlowTarget(t *testing.T) {
+	slowServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer slowServer.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+
+	requests := make(chan sender.Request)
+	go func() {
+		for i := 0; i < 5; i++ {
+			requests <- sender.Request{Url: slowServer.URL, Method: "GET", UserUrl: slowServer.URL}
+			requests <- sender.Request{Url: server.URL, Method: "GET", UserUrl: server.URL}
+		}
+		close(requests)
+	}()
+
+	s := sender.NewSender(sender.WithTargetConfig(slowServer.URL, sender.TargetConfig{MaxInFlight: 1}))
+	actual := chanToSlice(s.SendRequests(context.Background(), requests, 2))
+
+	if len(actual) != 10 {
+		t.Fatal("incorrect result: expected number of responses is 10, got", len(actual))
+	}
+	// the slow target must not hold back the other one, so all of its responses come first
+	for i, rr := range actual[:5] {
+		if rr.Request.UserUrl != server.URL {
+			t.Errorf("incorrect result: expected response %v to be from %v, got %v", i, server.URL, rr.Request.UserUrl)
+		}
+	}
+}
+
+func TestSendRequestsWithTimeout(t *testing.T) {
+	handlerFunc := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	})
+	server := httptest.NewServer(handlerFunc)
+	defer server.Close()
+
+	requests := make(chan sender.Request)
+	go func() {
+		requests <- sender.Request{Url: server.URL, Method: "GET", UserUrl: server.URL}
+		close(requests)
+	}()
+
+	s := sender.NewSender(
+		sender.WithRetryPolicy(sender.RetryPolicy{MaxAttempts: 1}),
+		sender.WithTargetConfig(server.URL, sender.TargetConfig{
+			Timeouts: sender.Timeouts{Total: 50 * time.Millisecond},
+		}),
+	)
+	actual := chanToSlice(s.SendRequests(context.Background(), requests, 1))
+
+	if len(actual) != 1 {
+		t.Fatal("incorrect result: expected number of responses is 1, got", len(actual))
+	}
+	resp := actual[0].Response
+	if resp.Error != sender.ErrorTimeout || resp.ErrorMessage == "" || resp.Status != "" || resp.Attempts != 1 {
+		t.Error("incorrect result: expected a timeout, got", resp)
+	}
+}
+
+func TestSendRequestsWithMetadata(t *testing.T) {
+	handlerFunc := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(rw)
+		_, _ = gz.Write([]byte(`{"hello":"world"}`))
+		_ = gz.Close()
+	})
+	server := httptest.NewServer(handlerFunc)
+	defer server.Close()
+
+	requests := make(chan sender.Request)
+	go func() {
+		requests <- sender.Request{Url: server.URL, Method: "GET"}
+		close(requests)
+	}()
+
+	s := sender.NewSender()
+	actual := chanToSlice(s.SendRequests(context.Background(), requests, 1))
+
+	if len(actual) != 1 {
+		t.Fatal("incorrect result: expected number of responses is 1, got", len(actual))
+	}
+	resp := actual[0].Response
+
+	if resp.Body != `{"hello":"world"}` || resp.Size != len(resp.Body) {
+		t.Error("incorrect result: expected a decompressed body, got", resp.Body)
+	}
+	if resp.ContentEncoding != "gzip" || resp.Proto != "HTTP/1.1" {
+		t.Errorf("incorrect result: expected gzip over HTTP/1.1, got %v over %v", resp.ContentEncoding, resp.Proto)
+	}
+	if !strings.Contains(resp.Headers, `"Content-Type":"application/json"`) {
+		t.Error("incorrect result: expected the Content-Type header, got", resp.Headers)
+	}
+	if resp.TimeToFirstByte <= 0 || resp.TimeToFirstByte > resp.Duration {
+		t.Errorf("incorrect result: expected 0 < time to first byte <= duration, got %v and %v", resp.TimeToFirstByte, resp.Duration)
+	}
+}
+
+func TestSendRequestsWithConnectionError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	requests := make(chan sender.Request)
+	go func() {
+		requests <- sender.Request{Url: url, Method: "GET"}
+		close(requests)
+	}()
+
+	s := sender.NewSender(sender.WithRetryPolicy(sender.RetryPolicy{MaxAttempts: 2}))
+	actual := chanToSlice(s.SendRequests(context.Background(), requests, 1))
+
+	if len(actual) != 1 {
+		t.Fatal("incorrect result: expected number of responses is 1, got", len(actual))
+	}
+	resp := actual[0].Response
+	if resp.Error != sender.ErrorConnection || resp.ErrorMessage == "" || resp.Attempts != 2 {
+		t.Error("incorrect result: expected a connection error after 2 attempts, got", resp)
+	}
+}
+
+func TestSendRequestsWithProtocols(t *testing.T) {
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	cleartextServer := httptest.NewUnstartedServer(handler)
+	cleartextServer.Config.Protocols = &http.Protocols{}
+	cleartextServer.Config.Protocols.SetHTTP1(true)
+	cleartextServer.Config.Protocols.SetUnencryptedHTTP2(true)
+	cleartextServer.Start()
+	defer cleartextServer.Close()
+
+	tlsServer := httptest.NewUnstartedServer(handler)
+	tlsServer.EnableHTTP2 = true
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+
+	data := []struct {
+		name          string
+		url           string
+		protocol      sender.Protocol
+		expectedProto string
+	}{
+		{"cleartext_default", cleartextServer.URL, "", "HTTP/1.1"},
+		{"cleartext_h1", cleartextServer.URL, sender.ProtocolHTTP1, "HTTP/1.1"},
+		{"cleartext_h2c", cleartextServer.URL, sender.ProtocolH2C, "HTTP/2.0"},
+		{"tls_default", tlsServer.URL, "", "HTTP/2.0"},
+		{"tls_h1", tlsServer.URL, sender.ProtocolHTTP1, "HTTP/1.1"},
+		{"tls_h2", tlsServer.URL, sender.ProtocolHTTP2, "HTTP/2.0"},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			resp := sendOneRequest(d.url, sender.TargetConfig{
+				TLSConfig: &tls.Config{InsecureSkipVerify: true},
+				Protocol:  d.protocol,
+			})
+
+			if resp.Error != "" || resp.Proto != d.expectedProto {
+				t.Errorf("incorrect result: expected %v, got '%v' with error '%v'", d.expectedProto, resp.Proto, resp.ErrorMessage)
+			}
+		})
+	}
+}
+
+func TestParseProtocol(t *testing.T) {
+	if _, err := sender.ParseProtocol("h3"); err == nil {
+		t.Error("incorrect result: expected an error for an unknown protocol")
+	}
+}
+
+func TestSendRequestsWithProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("proxied " + req.URL.String()))
+	}))
+	defer proxy.Close()
+	proxyUrl, _ := url.Parse(proxy.URL)
+
+	resp := sendOneRequest("http://example.test/api", sender.TargetConfig{Proxy: proxyUrl})
+	if resp.Error != "" || resp.Body != "proxied http://example.test/api" {
+		t.Errorf("incorrect result: expected the request to go through the proxy, got '%v' with error '%v'", resp.Body, resp.ErrorMessage)
+	}
+
+	resp = sendOneRequest("http://example.test/api", sender.TargetConfig{Proxy: proxyUrl, NoProxy: "localhost,.test"})
+	if resp.Error == "" {
+		t.Errorf("incorrect result: expected the request to bypass the proxy, got '%v'", resp.Body)
+	}
+}
+
+func TestSendRequestsWithProxyFromEnvironment(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("proxied " + req.URL.String()))
+	}))
+	defer proxy.Close()
+	t.Setenv("HTTP_PROXY", proxy.URL)
+	t.Setenv("NO_PROXY", "")
+
+	resp := sendOneRequest("http://example.test/api", sender.TargetConfig{NoProxy: "localhost"})
+	if resp.Error != "" || resp.Body != "proxied http://example.test/api" {
+		t.Errorf("incorrect result: expected the request to go through the proxy, got '%v' with error '%v'", resp.Body, resp.ErrorMessage)
+	}
+
+	resp = sendOneRequest("http://example.test/api", sender.TargetConfig{NoProxy: ".test"})
+	if resp.Error == "" {
+		t.Errorf("incorrect result: expected the request to bypass the proxy, got '%v'", resp.Body)
+	}
+}
+
+func TestSendRequestsWithAuth(t *testing.T) {
+	var tokens atomic.Int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = fmt.Fprintf(rw, `{"access_token":"token-%v","expires_in":3600}`, tokens.Add(1))
+	}))
+	defer tokenServer.Close()
+
+	// the first token is revoked, so the target only accepts the second one
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer token-2" {
+			rw.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	resp := sendOneRequest(server.URL, sender.TargetConfig{
+		Auth: auth.NewOAuth2(auth.OAuth2Config{TokenUrl: tokenServer.URL, ClientId: "id"}),
+	})
+
+	if resp.Status != "200" || resp.Attempts != 1 || tokens.Load() != 2 {
+		t.Errorf("incorrect result: expected status 200 with a renewed token, got %v after %v token requests", resp, tokens.Load())
+	}
+}
+
+func TestSendRequestsWithFailedAuth(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer tokenServer.Close()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	resp := sendOneRequest(server.URL, sender.TargetConfig{
+		Auth: auth.NewOAuth2(auth.OAuth2Config{TokenUrl: tokenServer.URL, ClientId: "id"}),
+	})
+
+	if resp.Error != sender.ErrorAuth || requests.Load() != 0 {
+		t.Errorf("incorrect result: expected an auth error without sending the request, got %v", resp)
 	}
 }
 
@@ -0,0 +1,291 @@
+package sender
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/nikitakuchur/testpoint/internal/auth"
+	"golang.org/x/net/http/httpproxy"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// TargetConfig contains the settings that can be specified for each target separately.
+type TargetConfig struct {
+	// RateLimit is the maximum number of requests per second, zero means no limit.
+	RateLimit float64
+	// MaxInFlight is the maximum number of requests that can be sent at the same time, zero means no limit.
+	MaxInFlight int
+	// Timeouts limit how long each stage of a request can take.
+	Timeouts Timeouts
+	// TLSConfig is the configuration of TLS connections, nil means the default configuration.
+	TLSConfig *tls.Config
+	// Protocol is the HTTP protocol used to send the requests, empty means it's negotiated with the server.
+	Protocol Protocol
+	// Proxy is the URL of the proxy the requests are sent through, nil means the proxy is taken from the environment.
+	Proxy *url.URL
+	// NoProxy is a comma-separated list of hosts that are accessed without the proxy, in the NO_PROXY format.
+	// If it's empty, the NO_PROXY environment variable is used.
+	NoProxy string
+	// Auth adds credentials to the requests, nil means no authentication.
+	Auth auth.Provider
+	// Cookies enables the cookie jar, so the cookies set by the responses are sent with the following requests.
+	// The requests that belong to different sessions don't share cookies.
+	Cookies bool
+	// InitialCookies are put in every new cookie jar, they are usually loaded with LoadCookieFile.
+	InitialCookies []Cookie
+	// MaxRedirects is the maximum number of redirects to follow, zero means the default limit of 10.
+	// NoRedirects disables following redirects.
+	MaxRedirects int
+	// MaxBodySize is the maximum number of bytes of the response body we keep, zero means no limit.
+	MaxBodySize int
+	// Descriptors are used to call the gRPC methods, nil means they are requested from the server through the reflection.
+	Descriptors *protoregistry.Files
+	// UnixSocket is the path of the Unix socket all the connections are made to, empty means the address from the URL is used.
+	UnixSocket string
+	// Resolve maps host:port to the address the connections are made to, the Host header and SNI stay the same.
+	Resolve map[string]string
+}
+
+// Protocol is the HTTP protocol used to talk to a target.
+type Protocol string
+
+const (
+	ProtocolHTTP1 Protocol = "h1"
+	// ProtocolHTTP2 is HTTP/2 over TLS.
+	ProtocolHTTP2 Protocol = "h2"
+	// ProtocolH2C is HTTP/2 over cleartext TCP with prior knowledge, it's never upgraded from HTTP/1.1.
+	ProtocolH2C Protocol = "h2c"
+)
+
+// ParseProtocol converts the given name to a protocol, empty name means the protocol is negotiated.
+func ParseProtocol(name string) (Protocol, error) {
+	switch p := Protocol(name); p {
+	case "", ProtocolHTTP1, ProtocolHTTP2, ProtocolH2C:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown protocol '%v', must be h1, h2, or h2c", name)
+}
+
+// Timeouts contains the timeouts of different stages of a request, zero means the default value.
+type Timeouts struct {
+	// Connect limits the time it takes to establish a connection.
+	Connect time.Duration
+	// TLSHandshake limits the time it takes to perform the TLS handshake.
+	TLSHandshake time.Duration
+	// ResponseHeader limits the time we wait for the response headers after the request has been written.
+	ResponseHeader time.Duration
+	// Total limits the whole request, including reading the response body.
+	Total time.Duration
+}
+
+// target holds the state shared by all the requests sent to the same target.
+type target struct {
+	client  *http.Client
+	dialer  *dialer
+	auth    auth.Provider
+	cookies *cookieJars
+	// maxBodySize is the maximum number of bytes of the response body we keep, zero means no limit.
+	maxBodySize int
+	limiter     *rateLimiter
+	inFlight    chan struct{}
+	// grpc has the connections to the gRPC servers, the requests with the grpc and grpcs schemes go through them
+	grpc        *grpcClients
+	grpcTimeout time.Duration
+}
+
+func newTarget(conf TargetConfig) *target {
+	d := newDialer(conf)
+	t := &target{
+		client:      newClient(conf, d),
+		dialer:      d,
+		auth:        conf.Auth,
+		maxBodySize: conf.MaxBodySize,
+		grpc:        newGrpcClients(conf),
+		grpcTimeout: conf.Timeouts.Total,
+	}
+	if conf.Cookies {
+		t.cookies = newCookieJars(conf.InitialCookies)
+	}
+	if conf.RateLimit > 0 {
+		t.limiter = newRateLimiter(conf.RateLimit)
+	}
+	if conf.MaxInFlight > 0 {
+		t.inFlight = make(chan struct{}, conf.MaxInFlight)
+	}
+	return t
+}
+
+const (
+	defaultConnectTimeout = 30 * time.Second
+	defaultKeepAlive      = 30 * time.Second
+)
+
+func newClient(conf TargetConfig, dialer *dialer) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	transport.DialContext = dialer.DialContext
+
+	if conf.Timeouts.TLSHandshake > 0 {
+		transport.TLSHandshakeTimeout = conf.Timeouts.TLSHandshake
+	}
+	transport.ResponseHeaderTimeout = conf.Timeouts.ResponseHeader
+
+	if conf.TLSConfig != nil {
+		transport.TLSClientConfig = conf.TLSConfig
+	}
+
+	if conf.Protocol != "" {
+		transport.Protocols = protocols(conf.Protocol)
+	}
+
+	if conf.Proxy != nil || conf.NoProxy != "" {
+		transport.Proxy = proxyFunc(conf.Proxy, conf.NoProxy)
+	}
+	// the sockets and the resolved addresses are reached directly, a proxy would connect to the host from the URL
+	proxy := transport.Proxy
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		if dialer.redirected(canonicalAddr(req.URL)) || proxy == nil {
+			return nil, nil
+		}
+		return proxy(req)
+	}
+
+	return &http.Client{Transport: transport, Timeout: conf.Timeouts.Total, CheckRedirect: checkRedirect(conf.MaxRedirects)}
+}
+
+func protocols(p Protocol) *http.Protocols {
+	protocols := &http.Protocols{}
+	switch p {
+	case ProtocolHTTP1:
+		protocols.SetHTTP1(true)
+	case ProtocolHTTP2:
+		protocols.SetHTTP2(true)
+	case ProtocolH2C:
+		protocols.SetUnencryptedHTTP2(true)
+	}
+	return protocols
+}
+
+// proxyFunc returns a function that sends all the requests through the given proxy,
+// except for the ones to the hosts from the NO_PROXY list.
+// If the proxy or the list isn't set, it's taken from the environment.
+func proxyFunc(proxy *url.URL, noProxy string) func(*http.Request) (*url.URL, error) {
+	conf := httpproxy.FromEnvironment()
+	if proxy != nil {
+		conf.HTTPProxy = proxy.String()
+		conf.HTTPSProxy = proxy.String()
+	}
+	if noProxy != "" {
+		conf.NoProxy = noProxy
+	}
+	f := conf.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return f(req.URL)
+	}
+}
+
+// do sends the request to the target with its credentials.
+// If the target rejects the credentials, and they can be renewed, the request is sent once again with the new ones.
+func (t *target) do(ctx context.Context, req Request, tm *timing, chain *redirectChain) (*http.Response, error) {
+	for renewed := false; ; renewed = true {
+		httpReq, err := newHttpRequest(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		// the Host header of the request is only used if the connection doesn't go to the host from the URL,
+		// since the requests taken from the logs of another host would get the wrong virtual host otherwise
+		if host := httpReq.Header.Get("Host"); host != "" && t.dialer.redirected(canonicalAddr(httpReq.URL)) {
+			httpReq.Host = host
+		}
+		if t.auth != nil {
+			if err := t.auth.Apply(httpReq); err != nil {
+				return nil, fmt.Errorf("%w: %w", errAuth, err)
+			}
+		}
+
+		if err := t.wait(ctx); err != nil {
+			return nil, err
+		}
+		resp, err := t.sessionClient(req.Session).Do(chain.trace(tm.trace(httpReq)))
+		refresher, ok := t.auth.(auth.Refresher)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized || !ok || renewed {
+			return resp, err
+		}
+
+		_, _ = io.Copy(io.Discard, resp.Body)
+		closeResponse(resp)
+		refresher.Invalidate(httpReq)
+	}
+}
+
+// sessionClient returns the client with the cookie jar of the given session.
+func (t *target) sessionClient(session string) *http.Client {
+	if t.cookies == nil {
+		return t.client
+	}
+	// the copy shares the transport with the original client, so the connections are reused
+	client := *t.client
+	client.Jar = t.cookies.jar(session)
+	return &client
+}
+
+// acquire blocks until the target can accept one more request or the context is canceled.
+func (t *target) acquire(ctx context.Context) error {
+	if t.inFlight == nil {
+		return nil
+	}
+	select {
+	case t.inFlight <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *target) release() {
+	if t.inFlight != nil {
+		<-t.inFlight
+	}
+}
+
+// wait blocks until the rate limit allows us to send the next request.
+func (t *target) wait(ctx context.Context) error {
+	if t.limiter == nil {
+		return nil
+	}
+	return t.limiter.wait(ctx)
+}
+
+// rateLimiter is a token bucket that is refilled at a constant rate.
+// The bucket holds at most one token, so the requests are evenly spread over time.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	return &rateLimiter{rate: rate, tokens: 1, last: time.Now()}
+}
+
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.rate, 1)
+	l.last = now
+	// the token is taken right away, even if it's not there yet, so the next caller queues up behind us
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	return sleep(ctx, delay)
+}
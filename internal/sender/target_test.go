@@ -0,0 +1,37 @@
+package sender
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(100)
+
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		_ = limiter.wait(context.Background())
+	}
+	elapsed := time.Since(start)
+
+	// the first request is sent right away, and the next five have to wait 10ms each
+	if elapsed < 50*time.Millisecond {
+		t.Error("incorrect result: expected the requests to take at least 50ms, got", elapsed)
+	}
+}
+
+func TestTargetWithNoLimits(t *testing.T) {
+	target := newTarget(TargetConfig{})
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		_ = target.acquire(context.Background())
+		_ = target.wait(context.Background())
+	}
+	elapsed := time.Since(start)
+
+	if elapsed > 50*time.Millisecond {
+		t.Error("incorrect result: expected the requests not to be limited, got", elapsed)
+	}
+}
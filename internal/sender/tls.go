@@ -0,0 +1,90 @@
+package sender
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// TLSOptions describes how to establish TLS connections with a target.
+type TLSOptions struct {
+	// CACertFile is a PEM file with the certificates of the authorities we trust in addition to the system ones.
+	CACertFile string
+	// ClientCertFile and ClientKeyFile are PEM files with the client certificate and its key for mutual TLS.
+	ClientCertFile string
+	ClientKeyFile  string
+	// ServerName overrides the name used to verify the server certificate and sent in the SNI extension.
+	ServerName string
+	// MinVersion is the minimum TLS version we accept, for example, "1.2".
+	MinVersion string
+	// InsecureSkipVerify disables the verification of the server certificate.
+	// It should only be used for throwaway environments.
+	InsecureSkipVerify bool
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewTLSConfig creates a TLS configuration from the given options.
+// It returns nil if the options are empty, so the default configuration is used.
+func NewTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	if opts == (TLSOptions{}) {
+		return nil, nil
+	}
+
+	conf := &tls.Config{
+		ServerName:         opts.ServerName,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+
+	if opts.MinVersion != "" {
+		version, ok := tlsVersions[opts.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS version '%v'", opts.MinVersion)
+		}
+		conf.MinVersion = version
+	}
+
+	if opts.CACertFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(opts.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the CA certificates: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%v: no certificates found", opts.CACertFile)
+		}
+		conf.RootCAs = pool
+	}
+
+	if opts.ClientCertFile != "" || opts.ClientKeyFile != "" {
+		if opts.ClientCertFile == "" || opts.ClientKeyFile == "" {
+			return nil, errors.New("both the client certificate and the client key must be specified")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.ClientCertFile, opts.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load the client certificate: %w", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	return conf, nil
+}
+
+// tlsInfo returns the negotiated TLS version and cipher suite of the connection the response was received over.
+func tlsInfo(resp *http.Response) (string, string) {
+	if resp.TLS == nil {
+		return "", ""
+	}
+	return tls.VersionName(resp.TLS.Version), tls.CipherSuiteName(resp.TLS.CipherSuite)
+}
@@ -0,0 +1,146 @@
+package sender_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/nikitakuchur/testpoint/internal/sender"
+	testutils "github.com/nikitakuchur/testpoint/internal/utils/testing"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSendRequestsWithTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+
+	caFile := testutils.CreateTempFile(t.TempDir(), "ca.pem", string(pem.EncodeToMemory(&pem.Block{
+		Type: "CERTIFICATE", Bytes: server.Certificate().Raw,
+	})))
+
+	data := []struct {
+		name          string
+		opts          sender.TLSOptions
+		expectedError string
+	}{
+		{"default", sender.TLSOptions{}, sender.ErrorTLS},
+		{"ca_cert", sender.TLSOptions{CACertFile: caFile}, ""},
+		{"server_name", sender.TLSOptions{CACertFile: caFile, ServerName: "test.com"}, sender.ErrorTLS},
+		{"insecure", sender.TLSOptions{InsecureSkipVerify: true}, ""},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			tlsConfig, err := sender.NewTLSConfig(d.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp := sendOneRequest(server.URL, sender.TargetConfig{TLSConfig: tlsConfig})
+
+			if resp.Error != d.expectedError {
+				t.Errorf("incorrect result: expected error '%v', got '%v'", d.expectedError, resp.Error)
+			}
+			if d.expectedError == "" && (resp.TLSVersion != "TLS 1.3" || resp.TLSCipher == "") {
+				t.Errorf("incorrect result: expected TLS 1.3 with some cipher, got '%v' and '%v'", resp.TLSVersion, resp.TLSCipher)
+			}
+		})
+	}
+}
+
+func TestSendRequestsWithClientCertificate(t *testing.T) {
+	certPem, keyPem := generateCertificate(t)
+	tempDir := t.TempDir()
+	certFile := testutils.CreateTempFile(tempDir, "client.pem", certPem)
+	keyFile := testutils.CreateTempFile(tempDir, "client-key.pem", keyPem)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM([]byte(certPem))
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	withoutCert, _ := sender.NewTLSConfig(sender.TLSOptions{InsecureSkipVerify: true})
+	resp := sendOneRequest(server.URL, sender.TargetConfig{TLSConfig: withoutCert})
+	if resp.Error == "" {
+		t.Error("incorrect result: expected an error without the client certificate")
+	}
+
+	withCert, err := sender.NewTLSConfig(sender.TLSOptions{
+		InsecureSkipVerify: true, ClientCertFile: certFile, ClientKeyFile: keyFile, MinVersion: "1.2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = sendOneRequest(server.URL, sender.TargetConfig{TLSConfig: withCert})
+	if resp.Error != "" || resp.Status != "200" {
+		t.Errorf("incorrect result: expected status 200, got '%v' (%v)", resp.Status, resp.ErrorMessage)
+	}
+}
+
+func TestNewTLSConfigWithIncorrectOptions(t *testing.T) {
+	data := []sender.TLSOptions{
+		{MinVersion: "2.0"},
+		{ClientCertFile: "client.pem"},
+		{CACertFile: "/testpoint/missing/ca.pem"},
+	}
+	for _, opts := range data {
+		_, err := sender.NewTLSConfig(opts)
+		if err == nil {
+			t.Errorf("incorrect result: expected an error for %+v", opts)
+		}
+	}
+}
+
+func sendOneRequest(url string, conf sender.TargetConfig) sender.Response {
+	requests := make(chan sender.Request)
+	go func() {
+		requests <- sender.Request{Url: url, Method: "GET", UserUrl: url}
+		close(requests)
+	}()
+
+	s := sender.NewSender(
+		sender.WithRetryPolicy(sender.RetryPolicy{MaxAttempts: 1}),
+		sender.WithTargetConfig(url, conf),
+	)
+	return chanToSlice(s.SendRequests(context.Background(), requests, 1))[0].Response
+}
+
+// generateCertificate generates a self-signed client certificate and returns it along with its key in PEM format.
+func generateCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "testpoint"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	return string(certPem), string(keyPem)
+}
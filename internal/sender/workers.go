@@ -0,0 +1,208 @@
+package sender
+
+import (
+	"context"
+	"hash/fnv"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// targetQueueSize is the number of requests that can wait for a slow target,
+// so the other targets can get this much ahead of it without reading the whole input into memory.
+const targetQueueSize = 10000
+
+// runWorkers starts the workers that process the items from the input channel and put the results in the output channel.
+// The items without a session are shared by all the workers,
+// while the items of the same session always go to the same worker, so they are processed in order.
+// Once the context is canceled, the rest of the input is drained without processing,
+// and the requests that failed because of the cancellation are dropped, since they don't tell us anything about the targets.
+func runWorkers[T any](ctx context.Context, input <-chan T, workers int, session func(T) string, process func(T) []RequestResponse) <-chan RequestResponse {
+	output := make(chan RequestResponse)
+
+	if workers <= 0 {
+		close(output)
+		return output
+	}
+
+	var canceled atomic.Uint64
+	var wg sync.WaitGroup
+	startWorkers(ctx, input, workers, session, process, output, &canceled, &wg)
+	go closeWhenDone(output, &canceled, &wg)
+
+	return output
+}
+
+// runTargetWorkers works the same way as runWorkers, but each target gets its own queue and its own workers.
+// This way, the workers of a throttled target wait for it, while the other targets keep going at their own pace.
+// The input is read only while some of the targets are waiting for requests,
+// so the requests aren't read ahead when all the targets are busy.
+func runTargetWorkers(ctx context.Context, input <-chan Request, workers int, process func(Request) []RequestResponse) <-chan RequestResponse {
+	output := make(chan RequestResponse)
+
+	if workers <= 0 {
+		close(output)
+		return output
+	}
+
+	session := func(req Request) string {
+		return req.Session
+	}
+
+	var canceled atomic.Uint64
+	var wg sync.WaitGroup
+	// taken tells the dispatcher that one of the targets has taken a request from its queue
+	taken := make(chan struct{}, 1)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		queues := make(map[string]chan Request)
+		defer func() {
+			for _, queue := range queues {
+				close(queue)
+			}
+		}()
+
+		for req := range input {
+			queue, ok := queues[req.UserUrl]
+			if !ok {
+				queue = make(chan Request, targetQueueSize)
+				queues[req.UserUrl] = queue
+				startWorkers(ctx, notify(queue, taken), workers, session, process, output, &canceled, &wg)
+			}
+			queue <- req
+
+			for !waiting(queues) {
+				<-taken
+			}
+		}
+	}()
+	go closeWhenDone(output, &canceled, &wg)
+
+	return output
+}
+
+// notify passes on the requests from the queue and signals every time a request has been passed on.
+func notify(queue <-chan Request, taken chan<- struct{}) <-chan Request {
+	output := make(chan Request)
+
+	go func() {
+		defer close(output)
+
+		for req := range queue {
+			output <- req
+			select {
+			case taken <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return output
+}
+
+// waiting checks whether any of the targets has no requests in its queue.
+func waiting(queues map[string]chan Request) bool {
+	for _, queue := range queues {
+		if len(queue) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// startWorkers starts the workers that read the given input, the wait group is done when all of them are finished.
+func startWorkers[T any](ctx context.Context, input <-chan T, workers int, session func(T) string, process func(T) []RequestResponse,
+	output chan<- RequestResponse, canceled *atomic.Uint64, wg *sync.WaitGroup) {
+	shared, sessions := dispatch(input, workers, session)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(own <-chan T) {
+			defer wg.Done()
+
+			for item := range merge(shared, own) {
+				if ctx.Err() != nil {
+					canceled.Add(1)
+					continue
+				}
+				for _, rr := range process(item) {
+					if ctx.Err() != nil && rr.Response.Error != "" {
+						canceled.Add(1)
+						continue
+					}
+					output <- rr
+				}
+			}
+		}(sessions[i])
+	}
+}
+
+// closeWhenDone closes the output once all the workers are finished.
+func closeWhenDone(output chan<- RequestResponse, canceled *atomic.Uint64, wg *sync.WaitGroup) {
+	wg.Wait()
+	if n := canceled.Load(); n != 0 {
+		log.Printf("%v requests were canceled", n)
+	}
+	close(output)
+}
+
+// dispatch splits the input into the channel shared by all the workers and a channel for each worker.
+func dispatch[T any](input <-chan T, workers int, session func(T) string) (<-chan T, []chan T) {
+	shared := make(chan T)
+	sessions := make([]chan T, workers)
+	for i := range sessions {
+		sessions[i] = make(chan T)
+	}
+
+	go func() {
+		defer func() {
+			close(shared)
+			for _, ch := range sessions {
+				close(ch)
+			}
+		}()
+
+		for item := range input {
+			id := session(item)
+			if id == "" {
+				shared <- item
+				continue
+			}
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(id))
+			sessions[h.Sum32()%uint32(workers)] <- item
+		}
+	}()
+
+	return shared, sessions
+}
+
+// merge reads the items from both channels until they are closed.
+func merge[T any](a <-chan T, b <-chan T) <-chan T {
+	output := make(chan T)
+
+	go func() {
+		defer close(output)
+
+		for a != nil || b != nil {
+			select {
+			case item, ok := <-a:
+				if !ok {
+					a = nil
+					continue
+				}
+				output <- item
+			case item, ok := <-b:
+				if !ok {
+					b = nil
+					continue
+				}
+				output <- item
+			}
+		}
+	}()
+
+	return output
+}
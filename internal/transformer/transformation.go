@@ -1,13 +1,17 @@
 package transformer
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/dop251/goja"
+	"github.com/nikitakuchur/testpoint/internal/graphql"
 	"github.com/nikitakuchur/testpoint/internal/io/readers/reqreader"
 	"github.com/nikitakuchur/testpoint/internal/sender"
+	"net/http"
 	"net/url"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
@@ -61,6 +65,7 @@ func NewReqTransformation(script string) (ReqTransformation, error) {
 			Method:  readJsString(obj, "method"),
 			Headers: parsedHeaders,
 			Body:    readJsString(obj, "body"),
+			Session: readJsString(obj, "session"),
 		}, nil
 	}, nil
 }
@@ -123,11 +128,102 @@ func DefaultReqTransformation(userUrl string, rec reqreader.ReqRecord) (sender.R
 	}, nil
 }
 
+// GraphqlReqTransformation works the same way as DefaultReqTransformation, but the records with the query field
+// are turned into GraphQL requests, see graphqlRequest. It needs to be enabled explicitly,
+// since the query field can be an ordinary column in other inputs.
+func GraphqlReqTransformation(userUrl string, rec reqreader.ReqRecord) (sender.Request, error) {
+	req, err := DefaultReqTransformation(userUrl, rec)
+	if err != nil {
+		return sender.Request{}, err
+	}
+	params := createNamedParams(rec)
+	if _, ok := params["query"]; !ok {
+		return req, nil
+	}
+	return graphqlRequest(req.Url, params)
+}
+
+// graphqlRequest builds a GraphQL request from the query, operationName, and variables fields,
+// so the input files don't need to have the whole body as escaped JSON.
+// The request is sent with the POST method and the JSON content type, unless the record says otherwise.
+func graphqlRequest(url string, params map[string]string) (sender.Request, error) {
+	body, err := graphql.Body(params["query"], params["operationname"], params["variables"])
+	if err != nil {
+		return sender.Request{}, err
+	}
+
+	headers := map[string]string{}
+	if params["headers"] != "" {
+		if err := json.Unmarshal([]byte(params["headers"]), &headers); err != nil {
+			return sender.Request{}, fmt.Errorf("cannot convert headers to a map: %w", err)
+		}
+	}
+	if !hasHeader(headers, "Content-Type") {
+		headers["Content-Type"] = "application/json"
+	}
+	headersJson, err := json.Marshal(headers)
+	if err != nil {
+		return sender.Request{}, err
+	}
+
+	method := params["method"]
+	if method == "" {
+		method = http.MethodPost
+	}
+	return sender.Request{
+		Url:     url,
+		Method:  method,
+		Headers: string(headersJson),
+		Body:    body,
+	}, nil
+}
+
+// SessionTransformation wraps the given transformation, so the requests get their session ID from the given column.
+// The column is either a field name, if the CSV file has a header, or a zero-based index otherwise.
+func SessionTransformation(transformation ReqTransformation, column string) ReqTransformation {
+	return func(userUrl string, rec reqreader.ReqRecord) (sender.Request, error) {
+		req, err := transformation(userUrl, rec)
+		if err != nil {
+			return sender.Request{}, err
+		}
+
+		params := createNamedParams(rec)
+		if len(params) != 0 {
+			req.Session = params[strings.ToLower(column)]
+			return req, nil
+		}
+		i, err := strconv.Atoi(column)
+		if err != nil {
+			return sender.Request{}, fmt.Errorf("the session column must be an index if there's no header: %w", err)
+		}
+		req.Session = getValue(rec.Values, i)
+		return req, nil
+	}
+}
+
+func hasHeader(headers map[string]string, name string) bool {
+	for k := range headers {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+	return false
+}
+
 // mergeUrls merges request URLs from the input files with the user's URL.
 // For example, let's assume we have the following URL in the file: "http://test.com/api/old?param=123".
 // If the user's URL is "http://newtest.com", this function will return "http://newtest.com/api/old?param=123".
 // If the user's URL is "http://newtest.com/api/new", this function will return "http://newtest.com/api/new?param=123".
+// The unix URLs, e.g. "unix:///var/run/app.sock:/api", are merged the same way, and the socket path is kept.
 func mergeUrls(requestUrl string, userUrl string) (string, error) {
+	if socket, httpUrl, ok := sender.SplitUnixUrl(userUrl); ok {
+		merged, err := mergeUrls(requestUrl, httpUrl)
+		if err != nil {
+			return "", err
+		}
+		return sender.JoinUnixUrl(socket, merged)
+	}
+
 	parsedRequestUrl, err := url.Parse(requestUrl)
 	if err != nil {
 		return "", err
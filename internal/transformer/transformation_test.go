@@ -283,6 +283,62 @@ func TestDefaultTransformationWithEmptyRecord(t *testing.T) {
 	}
 }
 
+func TestGraphqlTransformation(t *testing.T) {
+	record := reqreader.ReqRecord{
+		Fields: []string{"query", "operationName", "variables"},
+		Values: []string{"query FindUser($id: ID!) { user(id: $id) { name } }", "FindUser", `{"id": "42"}`},
+	}
+
+	actual, err := transformer.GraphqlReqTransformation("http://test.com/graphql", record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := sender.Request{
+		Url:     "http://test.com/graphql",
+		Method:  "POST",
+		Headers: `{"Content-Type":"application/json"}`,
+		Body:    `{"operationName":"FindUser","query":"query FindUser($id: ID!) { user(id: $id) { name } }","variables":{"id":"42"}}`,
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestGraphqlTransformationWithHeaders(t *testing.T) {
+	record := reqreader.ReqRecord{
+		Fields: []string{"url", "headers", "query"},
+		Values: []string{"/api/graphql", `{"content-type":"application/graphql+json","X-Tenant":"acme"}`, "{ me { name } }"},
+	}
+
+	actual, err := transformer.GraphqlReqTransformation("http://test.com", record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := sender.Request{
+		Url:     "http://test.com/api/graphql",
+		Method:  "POST",
+		Headers: `{"X-Tenant":"acme","content-type":"application/graphql+json"}`,
+		Body:    `{"query":"{ me { name } }"}`,
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestGraphqlTransformationWithInvalidVariables(t *testing.T) {
+	record := reqreader.ReqRecord{
+		Fields: []string{"query", "variables"},
+		Values: []string{"{ me { name } }", "[1, 2]"},
+	}
+
+	_, err := transformer.GraphqlReqTransformation("http://test.com/graphql", record)
+	if err == nil {
+		t.Error("incorrect result: expected an error, got nil")
+	}
+}
+
 func TestDefaultTransformationWithUrlMerging(t *testing.T) {
 	data := []struct {
 		name    string
@@ -304,7 +360,7 @@ func TestDefaultTransformationWithUrlMerging(t *testing.T) {
 
 	for _, d := range data {
 		t.Run(d.name, func(t *testing.T) {
-			actual, _ := transformer.DefaultReqTransformation(d.userUrl, reqreader.ReqRecord{Values: []string{d.reqUrl}})
+			actual, _ := transformer.GraphqlReqTransformation(d.userUrl, reqreader.ReqRecord{Values: []string{d.reqUrl}})
 
 			expected := sender.Request{Url: "http://test.com/api/new?param=1&param=2"}
 			if diff := cmp.Diff(expected, actual); diff != "" {
@@ -314,6 +370,30 @@ func TestDefaultTransformationWithUrlMerging(t *testing.T) {
 	}
 }
 
+func TestDefaultTransformationWithUnixUrlMerging(t *testing.T) {
+	data := []struct {
+		name    string
+		userUrl string
+		reqUrl  string
+	}{
+		{"no_path", "unix:///var/run/app.sock", "/api/new?param=1&param=2"},
+		{"host", "unix:///var/run/app.sock:", "https://site.com/api/new?param=1&param=2"},
+		{"path", "unix:///var/run/app.sock:/api/new", "/api/old?param=1&param=2"},
+		{"path_with_slash", "unix:///var/run/app.sock:/api/new/", "https://site.com/api/old?param=1&param=2"},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			actual, _ := transformer.GraphqlReqTransformation(d.userUrl, reqreader.ReqRecord{Values: []string{d.reqUrl}})
+
+			expected := sender.Request{Url: "unix:///var/run/app.sock:/api/new?param=1&param=2"}
+			if diff := cmp.Diff(expected, actual); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
 func TestDefaultTransformationWithIncorrectUrls(t *testing.T) {
 	data := []struct {
 		name    string
@@ -328,10 +408,85 @@ func TestDefaultTransformationWithIncorrectUrls(t *testing.T) {
 
 	for _, d := range data {
 		t.Run(d.name, func(t *testing.T) {
-			_, err := transformer.DefaultReqTransformation(d.userUrl, reqreader.ReqRecord{Values: []string{d.reqUrl}})
+			_, err := transformer.GraphqlReqTransformation(d.userUrl, reqreader.ReqRecord{Values: []string{d.reqUrl}})
 			if err == nil {
 				t.Errorf("incorrect result: expected an error")
 			}
 		})
 	}
 }
+
+func TestSessionTransformation(t *testing.T) {
+	data := []struct {
+		name     string
+		column   string
+		record   reqreader.ReqRecord
+		expected sender.Request
+	}{
+		{"index", "4", reqreader.ReqRecord{
+			Values: []string{"/api/test", "GET", "", "", "user-1"},
+		}, sender.Request{Url: "http://test.com/api/test", Method: "GET", Session: "user-1"}},
+		{"field", "Session_ID", reqreader.ReqRecord{
+			Fields: []string{"url", "session_id"},
+			Values: []string{"/api/test", "user-1"},
+		}, sender.Request{Url: "http://test.com/api/test", Session: "user-1"}},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			transformation := transformer.SessionTransformation(transformer.DefaultReqTransformation, d.column)
+
+			actual, err := transformation("http://test.com", d.record)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(d.expected, actual); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestSessionTransformationWithIncorrectColumn(t *testing.T) {
+	transformation := transformer.SessionTransformation(transformer.DefaultReqTransformation, "session")
+
+	_, err := transformation("http://test.com", reqreader.ReqRecord{Values: []string{"/api/test", "GET"}})
+	if err == nil {
+		t.Error("incorrect result: expected an error")
+	}
+}
+
+func TestGraphqlTransformationWithoutQuery(t *testing.T) {
+	record := reqreader.ReqRecord{
+		Fields: []string{"url", "method", "body"},
+		Values: []string{"/api/users", "POST", "foo"},
+	}
+
+	actual, err := transformer.GraphqlReqTransformation("http://test.com", record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := sender.Request{Url: "http://test.com/api/users", Method: "POST", Body: "foo"}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestDefaultTransformationWithQueryColumn(t *testing.T) {
+	record := reqreader.ReqRecord{
+		Fields: []string{"url", "method", "query"},
+		Values: []string{"/api/search", "GET", "q=foo&page=2"},
+	}
+
+	actual, err := transformer.DefaultReqTransformation("http://test.com", record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := sender.Request{Url: "http://test.com/api/search", Method: "GET"}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -1,16 +1,48 @@
 package transformer
 
 import (
+	"context"
+	"encoding/json"
 	"github.com/nikitakuchur/testpoint/internal/io/readers/reqreader"
+	"github.com/nikitakuchur/testpoint/internal/secrets"
 	"github.com/nikitakuchur/testpoint/internal/sender"
 	"log"
 )
 
 // TransformRequests reads raw request data from the input channel,
 // transforms it into requests using the given transformation and sends it to the output channel.
-func TransformRequests(userUrls []string, input <-chan reqreader.ReqRecord, transformation ReqTransformation) <-chan sender.Request {
+// It stops when the context is canceled.
+func TransformRequests(ctx context.Context, userUrls []string, input <-chan reqreader.ReqRecord, transformation ReqTransformation) <-chan sender.Request {
+	return Flatten(ctx, TransformRequestGroups(ctx, userUrls, input, transformation))
+}
+
+// Flatten puts the requests of each group in the output channel one by one.
+// It stops when the context is canceled.
+func Flatten(ctx context.Context, input <-chan []sender.Request) <-chan sender.Request {
 	output := make(chan sender.Request)
 
+	go func() {
+		defer close(output)
+
+		for group := range input {
+			for _, req := range group {
+				select {
+				case output <- req:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return output
+}
+
+// TransformRequestGroups works the same way as TransformRequests,
+// but it puts the requests created from the same record for different user URLs in one group.
+func TransformRequestGroups(ctx context.Context, userUrls []string, input <-chan reqreader.ReqRecord, transformation ReqTransformation) <-chan []sender.Request {
+	output := make(chan []sender.Request)
+
 	go func() {
 		defer close(output)
 
@@ -19,23 +51,69 @@ func TransformRequests(userUrls []string, input <-chan reqreader.ReqRecord, tran
 		}
 
 		for rec := range input {
-			for _, url := range userUrls {
-				req, err := transformation(url, rec)
-				if err != nil {
-					log.Printf("%v, %v: %v, the record was skipped", url, rec, err)
-					continue
-				}
-
-				if req.Method == "" {
-					req.Method = "GET"
-				}
-				req.UserUrl = url
-				req.Hash = rec.Hash
-
-				output <- req
+			group := transformRecord(userUrls, rec, transformation)
+			if len(group) == 0 {
+				continue
+			}
+			select {
+			case output <- group:
+			case <-ctx.Done():
+				return
 			}
 		}
 	}()
 
 	return output
 }
+
+func transformRecord(userUrls []string, rec reqreader.ReqRecord, transformation ReqTransformation) []sender.Request {
+	var group []sender.Request
+	for _, url := range userUrls {
+		req, err := transformation(url, rec)
+		if err != nil {
+			log.Printf("%v, %v: %v, the record was skipped", url, rec, err)
+			continue
+		}
+
+		req, err = expandSecrets(req)
+		if err != nil {
+			log.Printf("%v, %v: %v, the record was skipped", url, rec, err)
+			continue
+		}
+
+		if req.Method == "" {
+			req.Method = "GET"
+		}
+		req.UserUrl = url
+		req.Hash = rec.Hash
+
+		group = append(group, req)
+	}
+	return group
+}
+
+// expandSecrets substitutes environment variables and secret files in the URL, headers and body of the request.
+func expandSecrets(req sender.Request) (sender.Request, error) {
+	var err error
+	if req.Url, err = secrets.Expand(req.Url); err != nil {
+		return sender.Request{}, err
+	}
+	// headers are stored as a JSON string, so the values must be escaped to keep it valid
+	if req.Headers, err = secrets.ExpandFunc(req.Headers, escapeJsonString); err != nil {
+		return sender.Request{}, err
+	}
+	// the same goes for JSON bodies, but the other bodies can have any content, so they're left as is
+	var bodyEscape func(string) string
+	if json.Valid([]byte(req.Body)) {
+		bodyEscape = escapeJsonString
+	}
+	if req.Body, err = secrets.ExpandFunc(req.Body, bodyEscape); err != nil {
+		return sender.Request{}, err
+	}
+	return req, nil
+}
+
+func escapeJsonString(s string) string {
+	bytes, _ := json.Marshal(s)
+	return string(bytes[1 : len(bytes)-1])
+}
@@ -1,6 +1,7 @@
 package transformer_test
 
 import (
+	"context"
 	"errors"
 	"github.com/google/go-cmp/cmp"
 	"github.com/nikitakuchur/testpoint/internal/io/readers/reqreader"
@@ -14,7 +15,7 @@ func TestTransformRequestsWithNoData(t *testing.T) {
 	records := make(chan reqreader.ReqRecord)
 	close(records)
 
-	requests := transformer.TransformRequests(nil, records, testTransformation)
+	requests := transformer.TransformRequests(context.Background(), nil, records, testTransformation)
 
 	var actual = testutils.ChanToSlice(requests)
 	if len(actual) != 0 {
@@ -30,7 +31,7 @@ func TestTransformRequests(t *testing.T) {
 		close(records)
 	}()
 
-	requests := transformer.TransformRequests([]string{"http://test1.com", "http://test2.com"}, records, testTransformation)
+	requests := transformer.TransformRequests(context.Background(), []string{"http://test1.com", "http://test2.com"}, records, testTransformation)
 
 	var actual = testutils.ChanToSlice(requests)
 	if len(actual) != 4 {
@@ -49,6 +50,34 @@ func TestTransformRequests(t *testing.T) {
 	}
 }
 
+func TestTransformRequestGroups(t *testing.T) {
+	records := make(chan reqreader.ReqRecord)
+	go func() {
+		records <- reqreader.ReqRecord{Values: []string{"/api/test1"}, Hash: 1}
+		records <- reqreader.ReqRecord{Values: []string{"/api/test2"}, Hash: 2}
+		close(records)
+	}()
+
+	groups := transformer.TransformRequestGroups(context.Background(), []string{"http://test1.com", "http://test2.com"}, records, testTransformation)
+
+	actual := testutils.ChanToSlice(groups)
+
+	expected := [][]sender.Request{
+		{
+			{Url: "http://test1.com/api/test1", Method: "GET", UserUrl: "http://test1.com", Hash: 1},
+			{Url: "http://test2.com/api/test1", Method: "GET", UserUrl: "http://test2.com", Hash: 1},
+		},
+		{
+			{Url: "http://test1.com/api/test2", Method: "GET", UserUrl: "http://test1.com", Hash: 2},
+			{Url: "http://test2.com/api/test2", Method: "GET", UserUrl: "http://test2.com", Hash: 2},
+		},
+	}
+
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}
+
 func TestTransformRequestsWithErrors(t *testing.T) {
 	records := make(chan reqreader.ReqRecord)
 	go func() {
@@ -57,7 +86,7 @@ func TestTransformRequestsWithErrors(t *testing.T) {
 		close(records)
 	}()
 
-	requests := transformer.TransformRequests([]string{"http://test1.com", "http://test2.com"}, records, errorTransformation)
+	requests := transformer.TransformRequests(context.Background(), []string{"http://test1.com", "http://test2.com"}, records, errorTransformation)
 
 	var actual = testutils.ChanToSlice(requests)
 	if len(actual) != 0 {
@@ -72,3 +101,41 @@ func testTransformation(host string, rec reqreader.ReqRecord) (sender.Request, e
 func errorTransformation(_ string, _ reqreader.ReqRecord) (sender.Request, error) {
 	return sender.Request{}, errors.New("error")
 }
+
+func TestTransformRequestsWithSecrets(t *testing.T) {
+	t.Setenv("TESTPOINT_TOKEN", `to"ken`)
+
+	records := make(chan reqreader.ReqRecord)
+	go func() {
+		records <- reqreader.ReqRecord{Values: []string{
+			"/api/${TESTPOINT_TOKEN}?key=${TESTPOINT_TOKEN}", "POST", `{"Authorization":"Bearer ${TESTPOINT_TOKEN}"}`, "${TESTPOINT_TOKEN}",
+		}}
+		records <- reqreader.ReqRecord{Values: []string{"/api/json", "POST", "", `{"token":"${TESTPOINT_TOKEN}"}`}}
+		records <- reqreader.ReqRecord{Values: []string{"/api/test?key=${TESTPOINT_MISSING_TOKEN}"}}
+		close(records)
+	}()
+
+	requests := transformer.TransformRequests(context.Background(), []string{"http://test.com"}, records, transformer.DefaultReqTransformation)
+
+	var actual = testutils.ChanToSlice(requests)
+	if len(actual) != 2 {
+		t.Error("incorrect result: expected number of requests is 2, got", len(actual))
+	}
+
+	expected := []sender.Request{{
+		Url:     `http://test.com/api/to"ken?key=to"ken`,
+		Method:  "POST",
+		Headers: `{"Authorization":"Bearer to\"ken"}`,
+		Body:    `to"ken`,
+		UserUrl: "http://test.com",
+	}, {
+		Url:     "http://test.com/api/json",
+		Method:  "POST",
+		Body:    `{"token":"to\"ken"}`,
+		UserUrl: "http://test.com",
+	}}
+
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}